@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"os"
@@ -8,9 +9,14 @@ import (
 
 	"github.com/benedictjohannes/crobe/director"
 	"github.com/benedictjohannes/crobe/internal/configsource"
+	"github.com/benedictjohannes/crobe/internal/encryption"
 	"github.com/benedictjohannes/crobe/internal/headerflags"
+	"github.com/benedictjohannes/crobe/internal/listflags"
 	"github.com/benedictjohannes/crobe/internal/reportwriter"
+	"github.com/benedictjohannes/crobe/internal/signing"
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
 	"github.com/benedictjohannes/crobe/internal/transpile"
+	"github.com/benedictjohannes/crobe/internal/varflags"
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
 )
@@ -25,7 +31,21 @@ func run(args []string) int {
 	preprocessFlag := flags.Bool("preprocess", false, "Preprocess a raw YAML into a baked playbook")
 	inputFlag := flags.String("input", "", "Input raw YAML file (for preprocess)")
 	outputFlag := flags.String("output", "playbook.yaml", "Output baked YAML file (for preprocess)")
+	signFlag := flags.String("sign", "", "Private key file (ed25519, PEM) to sign the baked playbook with, writing a detached <output>.sig (for preprocess)")
 	folderFlag := flags.String("folder", "", "Folder to write reports to (default \"reports\")")
+	parallelFlag := flags.Int("parallel", 0, "Number of assertions to execute concurrently (default: playbook concurrency, or 1)")
+	var tagsFlags, excludeTagsFlags, codeFlags, sectionFlags listflags.ListFlags
+	flags.Var(&tagsFlags, "tags", "Only run assertions with any of these tags (comma-separated, glob patterns allowed)")
+	flags.Var(&excludeTagsFlags, "exclude-tags", "Skip assertions with any of these tags (comma-separated, glob patterns allowed)")
+	flags.Var(&codeFlags, "code", "Only run assertions with these codes (comma-separated, glob patterns allowed, eg: 'SSH_*')")
+	flags.Var(&sectionFlags, "section", "Only run sections with these titles (comma-separated, glob patterns allowed)")
+	waiversFlag := flags.String("waivers", "", "Waiver file (local path or HTTPS URL) accepting the failure of listed assertions; waived failures do not fail the run")
+	varFileFlag := flags.String("var-file", "", "YAML or JSON file of playbook var overrides (takes precedence over CROBE_VAR_<name> environment variables)")
+	var varFlags varflags.VarFlags
+	flags.Var(&varFlags, "var", "Override a playbook var (eg: 'kernelRegex=^[6-9]\\.'). Takes precedence over --var-file. Specify multiple times for each var.")
+	tlsOptions := tlsconfig.AddFlags(flags)
+	var recipientFlags listflags.ListFlags
+	flags.Var(&recipientFlags, "recipient", "age public key (age1...) to encrypt report files to, replacing the playbook's reportRecipients (comma-separated, or specify multiple times)")
 	var headersFlags headerflags.HeaderFlags
 	flags.Var(&headersFlags, "H", "Custom header for remote playbook fetching (eg: 'Authorization: Bearer <TOKEN>'). Specify multiple times for each header you want to add.")
 
@@ -34,7 +54,23 @@ func run(args []string) int {
 	}
 
 	headers := headersFlags.ToMap()
+	selector := playbook.Selector{
+		Tags:        tagsFlags,
+		ExcludeTags: excludeTagsFlags,
+		Codes:       codeFlags,
+		Sections:    sectionFlags,
+	}
+	if err := selector.Validate(); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
 	reportwriter.DefaultReportsDir = *folderFlag
+	if _, err := tlsOptions.Config(); err != nil {
+		fmt.Printf("❌ TLS Error: %v\n", err)
+		return 1
+	}
+	configsource.TLS = *tlsOptions
+	reportwriter.TLS = *tlsOptions
 
 	if *schemaFlag {
 		schema, err := playbook.GenerateSchema()
@@ -51,7 +87,11 @@ func run(args []string) int {
 			fmt.Println("❌ Error: --input is required for --preprocess")
 			return 1
 		}
-		return runPreprocess(*inputFlag, *outputFlag)
+		return runPreprocess(*inputFlag, *outputFlag, *signFlag)
+	}
+	if *signFlag != "" {
+		fmt.Println("❌ Error: --sign only signs baked playbooks, use it with --preprocess")
+		return 1
 	}
 
 	// Default: Run Agent Report
@@ -67,36 +107,74 @@ func run(args []string) int {
 		return 1
 	}
 
+	if *parallelFlag > 0 {
+		config.Concurrency = *parallelFlag
+	}
+
+	// Var overrides are applied first, so that what they interpolate is validated too
+	if err := varflags.Apply(config, *varFileFlag, varFlags); err != nil {
+		fmt.Printf("❌ Variables Error: %v\n", err)
+		return 1
+	}
+
 	// Validate (builder allows funcFile)
 	if err := playbook.ValidateConfig(*config, false); err != nil {
 		fmt.Printf("❌ Validation Error: %v\n", err)
 		return 1
 	}
 
+	if reportwriter.Recipients, err = encryption.SelectRecipients(recipientFlags, config.ReportRecipients); err != nil {
+		fmt.Printf("❌ Encryption Error: %v\n", err)
+		return 1
+	}
+
+	waivers, err := configsource.LoadRunWaivers(*waiversFlag, headers)
+	if err != nil {
+		fmt.Printf("❌ Failed to load waivers %s: %v\n", *waiversFlag, err)
+		return 1
+	}
+
 	// Transpile in-memory for direct run
 	if err := transpile.Preprocess(config, filepath.Dir(configPath)); err != nil {
 		fmt.Printf("❌ Preprocessing Error: %v\n", err)
 		return 1
 	}
 
-	trace := director.Run(*config)
+	trace := director.Run(*config, director.Options{Selector: selector, Waivers: waivers})
 	result := report.GenerateReport(trace)
 	if err := reportwriter.DispatchReport(config, result); err != nil {
 		fmt.Printf("❌ Reporting Error: %v\n", err)
 		return 1
 	}
 
-	if result.Structured.Stats.Failed > 0 {
+	if result.Structured.Stats.Failed > 0 || result.Structured.Stats.Errored > 0 {
 		return 1
 	}
 
 	return 0
 }
-func runPreprocess(inputPath string, outputPath string) int {
+func runPreprocess(inputPath string, outputPath string, keyPath string) int {
+	// Load the key first, so a bad key fails before anything is written
+	var key ed25519.PrivateKey
+	if keyPath != "" {
+		var err error
+		if key, err = signing.LoadPrivateKey(keyPath); err != nil {
+			fmt.Printf("❌ Signing Key Error: %v\n", err)
+			return 1
+		}
+	}
 	if err := transpile.BakeFile(inputPath, outputPath); err != nil {
 		fmt.Printf("❌ Preprocessing Failed: %v\n", err)
 		return 1
 	}
 	fmt.Printf("🚀 Preprocessing Complete! Baked playbook saved to: %s\n", outputPath)
+	if key != nil {
+		sigPath, err := signing.SignFile(key, outputPath)
+		if err != nil {
+			fmt.Printf("❌ Signing Failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔏 Signed with key %s: %s\n", signing.Fingerprint(key.Public().(ed25519.PublicKey)), sigPath)
+	}
 	return 0
 }
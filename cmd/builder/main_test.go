@@ -1,9 +1,18 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/benedictjohannes/crobe/internal/signing"
+
+	"filippo.io/age"
 )
 
 func TestBuilderRun(t *testing.T) {
@@ -45,6 +54,36 @@ sections:
 		t.Errorf("Expected exit code 0 for --preprocess happy path, got %d", code)
 	}
 
+	// 5. Test --preprocess --sign
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	keyPath := filepath.Join(tmpDir, "signing.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signedPath := filepath.Join(tmpDir, "signed.yaml")
+	if code := run([]string{"--preprocess", "--input", inputPath, "--output", signedPath, "--sign", keyPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for --preprocess --sign, got %d", code)
+	}
+	baked, _ := os.ReadFile(signedPath)
+	sig, _ := os.ReadFile(signedPath + ".sig")
+	if err := signing.Verify(pub, baked, sig); err != nil {
+		t.Errorf("Expected a valid detached signature, got %v", err)
+	}
+	unwrittenPath := filepath.Join(tmpDir, "unwritten.yaml")
+	if code := run([]string{"--preprocess", "--input", inputPath, "--output", unwrittenPath, "--sign", inputPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid signing key, got %d", code)
+	}
+	if _, err := os.Stat(unwrittenPath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be baked with an invalid signing key, got %v", err)
+	}
+	if code := run([]string{"--sign", keyPath, inputPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for --sign without --preprocess, got %d", code)
+	}
+
 	// 6. Test --preprocess with invalid input file
 	if code := run([]string{"--preprocess", "--input", "non-existent.yaml", "--output", outputPath}); code != 1 {
 		t.Errorf("Expected exit code 1 for --preprocess with non-existent input, got %d", code)
@@ -81,6 +120,9 @@ sections:
 	if code := run([]string{"-folder", tmpDir, pbPath}); code != 0 {
 		t.Errorf("Expected exit code 0 for normal run, got %d", code)
 	}
+	if code := run([]string{"-folder", tmpDir, "-var", "undeclared=1", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an undeclared var, got %d", code)
+	}
 
 	// 9. Test --preprocess failure (invalid YAML)
 	invalidInputPath := filepath.Join(tmpDir, "invalid_raw.yaml")
@@ -155,5 +197,31 @@ sections:
 	if code := run([]string{"non-existent.yaml"}); code != 1 {
 		t.Errorf("Expected exit code 1 for non-existent playbook, got %d", code)
 	}
+
+	// 16. Test reportRecipients encrypting the reports
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedPbPath := filepath.Join(tmpDir, "encrypted.yaml")
+	os.WriteFile(encryptedPbPath, []byte(pbContent+"reportRecipients: ["+identity.Recipient().String()+"]\n"), 0644)
+	encryptedDir := filepath.Join(tmpDir, "encrypted")
+	if code := run([]string{"-folder", encryptedDir, encryptedPbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 with reportRecipients, got %d", code)
+	}
+	files, _ := os.ReadDir(encryptedDir)
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 report files, got %d", len(files))
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".age") {
+			t.Errorf("Expected %s to be encrypted", f.Name())
+		}
+	}
+	invalidRecipientPbPath := filepath.Join(tmpDir, "invalid_recipient.yaml")
+	os.WriteFile(invalidRecipientPbPath, []byte(pbContent+"reportRecipients: [age1invalid]\n"), 0644)
+	if code := run([]string{"-folder", tmpDir, invalidRecipientPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid report recipient, got %d", code)
+	}
 }
 
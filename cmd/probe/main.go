@@ -1,25 +1,82 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/benedictjohannes/crobe/director"
+	"github.com/benedictjohannes/crobe/executor"
 	"github.com/benedictjohannes/crobe/internal/configsource"
+	"github.com/benedictjohannes/crobe/internal/encryption"
 	"github.com/benedictjohannes/crobe/internal/headerflags"
+	"github.com/benedictjohannes/crobe/internal/listflags"
 	"github.com/benedictjohannes/crobe/internal/reportwriter"
+	"github.com/benedictjohannes/crobe/internal/signing"
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
+	"github.com/benedictjohannes/crobe/internal/varflags"
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
+	"github.com/benedictjohannes/crobe/submission"
+
+	"filippo.io/age"
 )
 
+// playbookPublicKey pins the key that playbooks must be signed with, embedded at build time
+// with -ldflags "-X main.playbookPublicKey=<base64 key>". When set, --pubkey is refused.
+var playbookPublicKey string
+
 func main() {
 	os.Exit(run(os.Args[1:]))
 }
 
 func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "flush-spool":
+			return runFlushSpool(args[1:])
+		case "enroll":
+			return runEnroll(args[1:])
+		case "decrypt":
+			return runDecrypt(args[1:])
+		case "convert":
+			return runConvert(args[1:])
+		}
+	}
+
 	flags := flag.NewFlagSet("crobe", flag.ContinueOnError)
 	folderFlag := flags.String("folder", "", "Folder to write reports to (default \"reports\")")
+	remediateFlag := flags.String("remediate", "", "Remediate failed assertions that define a remediation: 'dry-run' shows what would run, 'apply' runs it and re-verifies (default: never remediate)")
+	planFlag := flags.Bool("plan", false, "Print what each assertion would execute on this machine, without executing anything or dispatching a report")
+	planFormatFlag := flags.String("plan-format", "text", "Output format of --plan: 'text' or 'json'")
+	parallelFlag := flags.Int("parallel", 0, "Number of assertions to execute concurrently (default: playbook concurrency, or 1)")
+	var tagsFlags, excludeTagsFlags, codeFlags, sectionFlags listflags.ListFlags
+	flags.Var(&tagsFlags, "tags", "Only run assertions with any of these tags (comma-separated, glob patterns allowed)")
+	flags.Var(&excludeTagsFlags, "exclude-tags", "Skip assertions with any of these tags (comma-separated, glob patterns allowed)")
+	flags.Var(&codeFlags, "code", "Only run assertions with these codes (comma-separated, glob patterns allowed, eg: 'SSH_*')")
+	flags.Var(&sectionFlags, "section", "Only run sections with these titles (comma-separated, glob patterns allowed)")
+	waiversFlag := flags.String("waivers", "", "Waiver file (local path or HTTPS URL) accepting the failure of listed assertions; waived failures do not fail the run")
+	varFileFlag := flags.String("var-file", "", "YAML or JSON file of playbook var overrides (takes precedence over CROBE_VAR_<name> environment variables)")
+	var varFlags varflags.VarFlags
+	flags.Var(&varFlags, "var", "Override a playbook var (eg: 'kernelRegex=^[6-9]\\.'). Takes precedence over --var-file. Specify multiple times for each var.")
+	pubkeyFlag := flags.String("pubkey", "", "Public key file (ed25519, PEM) that the playbook must be signed with; unsigned or tampered playbooks are refused")
+	signatureFlag := flags.String("signature", "", "Location (local path or HTTPS URL) of the playbook's detached signature (default: playbook location + .sig)")
+	cacheDirFlag := flags.String("cache-dir", configsource.DefaultCacheDir(), "Folder caching the last good copy of remote playbooks, used when their server cannot be reached")
+	cacheMaxAgeFlag := flags.Duration("cache-max-age", configsource.DefaultCacheMaxAge, "Maximum age of a cached remote playbook used offline (0 disables the cache)")
+	tlsOptions := tlsconfig.AddFlags(flags)
+	deviceKeyFlag := flags.String("device-key", signing.DefaultDeviceKeyPath(), "Device key (ed25519 or ECDSA P-256, PEM) signing submitted reports, as created by 'crobe enroll'; reports are not signed when the default key does not exist")
+	var recipientFlags listflags.ListFlags
+	flags.Var(&recipientFlags, "recipient", "age public key (age1...) to encrypt report files to, replacing the playbook's reportRecipients (comma-separated, or specify multiple times)")
+	var outputFlags listflags.ListFlags
+	flags.Var(&outputFlags, "output", "Additional report output format (sarif, junit, html, oscal, xccdf, openmetrics), replacing the playbook's reportOutputs (comma-separated, or specify multiple times)")
+	newSpool := addSpoolFlags(flags)
 	var headersFlags headerflags.HeaderFlags
 	flags.Var(&headersFlags, "H", "Custom header for remote playbook fetching (eg: 'Authorization: Bearer <TOKEN>'). Specify multiple times for each header you want to add.")
 
@@ -28,8 +85,47 @@ func run(args []string) int {
 	}
 
 	headers := headersFlags.ToMap()
+	selector := playbook.Selector{
+		Tags:        tagsFlags,
+		ExcludeTags: excludeTagsFlags,
+		Codes:       codeFlags,
+		Sections:    sectionFlags,
+	}
+	if err := selector.Validate(); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	remediate := executor.RemediationMode(*remediateFlag)
+	switch remediate {
+	case executor.RemediateOff, executor.RemediateDryRun, executor.RemediateApply:
+	default:
+		fmt.Printf("❌ Error: invalid --remediate value '%s' (expected dry-run or apply)\n", *remediateFlag)
+		return 1
+	}
+
+	if *planFormatFlag != "text" && *planFormatFlag != "json" {
+		fmt.Printf("❌ Error: invalid --plan-format value '%s' (expected text or json)\n", *planFormatFlag)
+		return 1
+	}
 
 	reportwriter.DefaultReportsDir = *folderFlag
+	if _, err := tlsOptions.Config(); err != nil {
+		fmt.Printf("❌ TLS Error: %v\n", err)
+		return 1
+	}
+	configsource.TLS = *tlsOptions
+	reportwriter.TLS = *tlsOptions
+	deviceKey, err := loadDeviceKey(*deviceKeyFlag)
+	if err != nil {
+		fmt.Printf("❌ Device Key Error: %v\n", err)
+		return 1
+	}
+	reportwriter.DeviceKey = deviceKey
+	reportwriter.ReportSpool = newSpool()
+	configsource.RemoteCache = nil
+	if *cacheMaxAgeFlag > 0 && *cacheDirFlag != "" {
+		configsource.RemoteCache = configsource.NewCache(*cacheDirFlag, *cacheMaxAgeFlag)
+	}
 
 	configPath := flags.Arg(0)
 	if configPath == "" {
@@ -37,30 +133,403 @@ func run(args []string) int {
 		return 1
 	}
 
-	config, _, err := configsource.LoadConfig(configPath, headers)
+	publicKey, err := pinnedPublicKey(*pubkeyFlag)
 	if err != nil {
-		fmt.Printf("❌ Failed to load playbook %s: %v\n", configPath, err)
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	if publicKey == nil && *signatureFlag != "" {
+		fmt.Println("❌ Error: --signature requires a public key to verify it with (--pubkey)")
 		return 1
 	}
 
+	var config *playbook.Playbook
+	if publicKey != nil {
+		config, _, err = configsource.LoadSignedConfig(configPath, *signatureFlag, headers, publicKey)
+		if err != nil {
+			fmt.Printf("❌ Refusing to run playbook %s: %v\n", configPath, err)
+			return 1
+		}
+		fmt.Printf("🔏 Playbook signature verified (key %s)\n", signing.Fingerprint(publicKey))
+	} else {
+		config, _, err = configsource.LoadConfig(configPath, headers)
+		if err != nil {
+			fmt.Printf("❌ Failed to load playbook %s: %v\n", configPath, err)
+			return 1
+		}
+	}
+
+	if *parallelFlag > 0 {
+		config.Concurrency = *parallelFlag
+	}
+	if len(outputFlags) > 0 {
+		config.ReportOutputs = nil
+		for _, o := range outputFlags {
+			config.ReportOutputs = append(config.ReportOutputs, playbook.OutputFormat(o))
+		}
+	}
+
 	// TODO this line to below are not covered by tests
 
+	for _, cached := range config.CachedCopies {
+		fmt.Printf("🗄️ Offline: using the cached copy of %s fetched %s ago\n", cached.Location, time.Since(cached.FetchedAt).Round(time.Second))
+	}
+
+	// Var overrides are applied first, so that what they interpolate is validated too
+	if err := varflags.Apply(config, *varFileFlag, varFlags); err != nil {
+		fmt.Printf("❌ Variables Error: %v\n", err)
+		return 1
+	}
+
 	// Validate as Agent
 	if err := playbook.ValidateConfig(*config, true); err != nil {
 		fmt.Printf("❌ Validation Error: %v\n", err)
 		return 1
 	}
+	commitCache()
 
-	trace := director.Run(*config)
+	if reportwriter.Recipients, err = encryption.SelectRecipients(recipientFlags, config.ReportRecipients); err != nil {
+		fmt.Printf("❌ Encryption Error: %v\n", err)
+		return 1
+	}
+
+	waivers, err := configsource.LoadRunWaivers(*waiversFlag, headers)
+	if err != nil {
+		fmt.Printf("❌ Failed to load waivers %s: %v\n", *waiversFlag, err)
+		return 1
+	}
+	commitCache()
+
+	if *planFlag {
+		return printPlan(director.BuildPlan(*config, director.Options{Selector: selector, Remediate: remediate}), *planFormatFlag)
+	}
+
+	trace := director.Run(*config, director.Options{Selector: selector, Remediate: remediate, Waivers: waivers})
 	result := report.GenerateReport(trace)
 	if err := reportwriter.DispatchReport(config, result); err != nil {
 		fmt.Printf("❌ Reporting Error: %v\n", err)
 		return 1
 	}
+	if _, err := reportwriter.ReportSpool.Flush(false); err != nil {
+		fmt.Printf("⚠️ Failed to flush the report spool: %v\n", err)
+	}
+
+	if result.Structured.Stats.Failed > 0 || result.Structured.Stats.Errored > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// runFlushSpool retries every spooled report now, regardless of its backoff.
+func runFlushSpool(args []string) int {
+	flags := flag.NewFlagSet("crobe flush-spool", flag.ContinueOnError)
+	newSpool := addSpoolFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	spool := newSpool()
+	if spool == nil {
+		fmt.Println("❌ Error: the report spool is disabled (--spool-max-age 0 or no --spool-dir)")
+		return 1
+	}
+	result, err := spool.Flush(true)
+	if err != nil {
+		fmt.Printf("❌ Spool Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("📦 Spool flushed: %d delivered, %d pending, %d dropped\n", result.Delivered, result.Pending, result.Dropped)
+	if result.Pending > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runEnroll creates the device key signing submitted reports, unless it exists, prints its
+// public key and optionally registers it with a report server.
+func runEnroll(args []string) int {
+	flags := flag.NewFlagSet("crobe enroll", flag.ContinueOnError)
+	keyFlag := flags.String("key", signing.DefaultDeviceKeyPath(), "Device key file, created if it does not exist")
+	algorithmFlag := flags.String("algorithm", submission.AlgorithmEd25519, "Algorithm of a created device key: 'ed25519' or 'ecdsa-p256'")
+	urlFlag := flags.String("url", "", "HTTPS URL of the report server enrollment endpoint to post the public key to (default: only print it)")
+	tlsOptions := tlsconfig.AddFlags(flags)
+	var headersFlags headerflags.HeaderFlags
+	flags.Var(&headersFlags, "H", "Custom header for the enrollment request (eg: 'Authorization: Bearer <TOKEN>'). Specify multiple times for each header you want to add.")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if *keyFlag == "" {
+		fmt.Println("❌ Error: no device key path (--key)")
+		return 1
+	}
+
+	key, err := signing.LoadDeviceKey(*keyFlag)
+	if os.IsNotExist(err) {
+		if key, err = signing.GenerateDeviceKey(*algorithmFlag); err == nil {
+			err = signing.SaveDeviceKey(*keyFlag, key)
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to create device key: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔑 Created %s device key: %s\n", *algorithmFlag, *keyFlag)
+	} else if err != nil {
+		fmt.Printf("❌ Device Key Error: %v\n", err)
+		return 1
+	} else {
+		fmt.Printf("🔑 Using existing device key: %s\n", *keyFlag)
+	}
+
+	hostname, _ := os.Hostname()
+	enrollment, err := submission.NewEnrollment(key.Public(), hostname)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("🆔 Key ID: %s\n", enrollment.KeyID)
+	fmt.Print(enrollment.PublicKey)
+
+	if *urlFlag == "" {
+		return 0
+	}
+	if _, err := tlsOptions.Config(); err != nil {
+		fmt.Printf("❌ TLS Error: %v\n", err)
+		return 1
+	}
+	reportwriter.TLS = *tlsOptions
+	status, err := reportwriter.Enroll(*urlFlag, headersFlags.ToMap(), enrollment)
+	if err != nil {
+		fmt.Printf("❌ Enrollment Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("✅ Enrolled with %s (Status: %d)\n", *urlFlag, status)
+	return 0
+}
 
-	if result.Structured.Stats.Failed > 0 {
+// runDecrypt decrypts report files encrypted to the identity of the hub, or creates that
+// identity with --keygen.
+func runDecrypt(args []string) int {
+	flags := flag.NewFlagSet("crobe decrypt", flag.ContinueOnError)
+	identityFlag := flags.String("identity", "", "age identity file (AGE-SECRET-KEY-1..., as written by age-keygen) of a report recipient")
+	keygenFlag := flags.Bool("keygen", false, "Create the --identity file and print its public key, to be used as report recipient")
+	outFlag := flags.String("out", "", "Folder to write decrypted files to (default: next to each encrypted file)")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if *identityFlag == "" {
+		fmt.Println("❌ Error: no identity file (--identity)")
 		return 1
 	}
 
+	if *keygenFlag {
+		recipient, err := encryption.GenerateIdentity(*identityFlag)
+		if err != nil {
+			fmt.Printf("❌ Failed to create identity: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔑 Created identity: %s\n", *identityFlag)
+		fmt.Printf("🔒 Report recipient: %s\n", recipient)
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Println("❌ Error: no file to decrypt. Use 'crobe decrypt --identity <file> [report.json.age ...]', or '-' for stdin")
+		return 1
+	}
+	identities, err := encryption.LoadIdentities(*identityFlag)
+	if err != nil {
+		fmt.Printf("❌ Identity Error: %v\n", err)
+		return 1
+	}
+	// Messages go to stderr, keeping stdout for the content decrypted from stdin
+	failed := false
+	for _, path := range flags.Args() {
+		if err := decryptFile(path, *outFlag, identities); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
 	return 0
 }
+
+// decryptFile decrypts an encrypted report file into outDir (or its own folder), without its
+// encryption.Ext. "-" decrypts stdin to stdout.
+func decryptFile(path string, outDir string, identities []age.Identity) error {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		plaintext, err := encryption.Decrypt(data, identities)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(plaintext)
+		return err
+	}
+
+	if !strings.HasSuffix(path, encryption.Ext) {
+		return fmt.Errorf("not an encrypted report file (expected the %s extension)", encryption.Ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	plaintext, err := encryption.Decrypt(data, identities)
+	if err != nil {
+		return err
+	}
+	target := strings.TrimSuffix(path, encryption.Ext)
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0700); err != nil {
+			return err
+		}
+		target = filepath.Join(outDir, filepath.Base(target))
+	}
+	if err := os.WriteFile(target, plaintext, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "🔓 Decrypted: %s\n", target)
+	return nil
+}
+
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("crobe convert", flag.ContinueOnError)
+	formatFlag := flags.String("format", string(playbook.OutputOSCAL), "Format to convert JSON reports to: 'oscal', 'xccdf' or 'openmetrics'")
+	outFlag := flags.String("out", "", "Folder to write converted files to (default: next to each JSON report)")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() == 0 {
+		fmt.Println("❌ Error: no report to convert. Use 'crobe convert [--format oscal|xccdf|openmetrics] <report.json ...>', or '-' for stdin")
+		return 1
+	}
+	// Messages go to stderr, keeping stdout for the content converted from stdin
+	failed := false
+	for _, path := range flags.Args() {
+		if err := convertReport(path, *outFlag, playbook.OutputFormat(*formatFlag)); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// convertReport converts a JSON report into outDir (or its own folder), replacing its .json
+// extension with the one of the format. "-" converts stdin to stdout.
+func convertReport(path string, outDir string, format playbook.OutputFormat) error {
+	if strings.HasSuffix(path, encryption.Ext) {
+		return fmt.Errorf("encrypted report: decrypt it first with 'crobe decrypt'")
+	}
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	var res report.FinalReport
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("not a JSON report: %w", err)
+	}
+	out, err := report.ConvertReport(res, format)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(out.Content)
+		return err
+	}
+
+	target := strings.TrimSuffix(path, ".json") + out.Ext
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return err
+		}
+		target = filepath.Join(outDir, filepath.Base(target))
+	}
+	if err := os.WriteFile(target, out.Content, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "📄 Converted: %s\n", target)
+	return nil
+}
+
+// loadDeviceKey loads the key signing submitted reports. Without a key at the default path,
+// the device is not enrolled and reports are not signed.
+func loadDeviceKey(path string) (crypto.Signer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	key, err := signing.LoadDeviceKey(path)
+	if os.IsNotExist(err) && path == signing.DefaultDeviceKeyPath() {
+		return nil, nil
+	}
+	return key, err
+}
+
+// addSpoolFlags defines the report spool flags, and returns a function building the spool
+// they configure once parsed (nil when disabled).
+func addSpoolFlags(flags *flag.FlagSet) func() *reportwriter.Spool {
+	dir := flags.String("spool-dir", reportwriter.DefaultSpoolDir(), "Folder keeping the reports whose submission failed, to be retried on the next run or with 'crobe flush-spool'")
+	maxAge := flags.Duration("spool-max-age", reportwriter.DefaultSpoolMaxAge, "Maximum age of a spooled report before it is dropped (0 disables the spool)")
+	maxSize := flags.Int64("spool-max-size", reportwriter.DefaultSpoolMaxSize>>20, "Maximum total size of the spooled reports in MiB; the oldest are dropped beyond it")
+	return func() *reportwriter.Spool {
+		if *maxAge <= 0 || *dir == "" {
+			return nil
+		}
+		return reportwriter.NewSpool(*dir, *maxSize<<20, *maxAge)
+	}
+}
+
+func printPlan(plan director.Plan, format string) int {
+	if format == "json" {
+		out, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(plan.Text())
+	}
+	if plan.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+// commitCache saves validated remote files to the cache. Failing to cache is not fatal.
+func commitCache() {
+	if err := configsource.RemoteCache.Commit(); err != nil {
+		fmt.Printf("⚠️ Failed to cache remote files: %v\n", err)
+	}
+}
+
+// pinnedPublicKey returns the key playbooks must be signed with: the key embedded at build
+// time, or else the --pubkey file. It is nil when no key is pinned.
+func pinnedPublicKey(pubkeyPath string) (ed25519.PublicKey, error) {
+	if playbookPublicKey != "" {
+		if pubkeyPath != "" {
+			return nil, fmt.Errorf("this build has an embedded playbook public key; --pubkey cannot replace it")
+		}
+		key, err := signing.ParsePublicKey(playbookPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid embedded playbook public key: %w", err)
+		}
+		return key, nil
+	}
+	if pubkeyPath == "" {
+		return nil, nil
+	}
+	return signing.LoadPublicKey(pubkeyPath)
+}
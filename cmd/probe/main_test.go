@@ -1,9 +1,20 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/benedictjohannes/crobe/internal/encryption"
+	"github.com/benedictjohannes/crobe/internal/signing"
+
+	"filippo.io/age"
 )
 
 func TestProbeRun(t *testing.T) {
@@ -38,6 +49,244 @@ sections:
 	if code := run([]string{"-folder", tmpDir, pbPath}); code != 0 {
 		t.Errorf("Expected exit code 0 for happy path, got %d", code)
 	}
+	if code := run([]string{"-folder", tmpDir, "-parallel", "4", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for parallel run, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-code", "NO_MATCH_*", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a run with everything skipped, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-code", "[", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a malformed selection pattern, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-remediate", "yes", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid remediation mode, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-remediate", "dry-run", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a dry-run remediation, got %d", code)
+	}
+	varsPbPath := filepath.Join(tmpDir, "vars.yaml")
+	varsPbContent := `
+title: Test
+vars:
+  expected: hello
+sections:
+  - title: S1
+    assertions:
+      - code: T1
+        title: T1
+        cmds:
+          - exec:
+              script: echo hello
+            stdOutRule:
+              regex: ^${vars.expected}$
+`
+	if err := os.WriteFile(varsPbPath, []byte(varsPbContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"-folder", tmpDir, varsPbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 with var defaults, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-var", "expected=bye", varsPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 with an overridden var failing the rule, got %d", code)
+	}
+	t.Setenv("CROBE_VAR_expected", "bye")
+	if code := run([]string{"-folder", tmpDir, varsPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 with a var overridden from the environment, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-var", "expected=hello", varsPbPath}); code != 0 {
+		t.Errorf("Expected --var to take precedence over the environment, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-var", "unknown=1", varsPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an undeclared var, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-var", "novalue", varsPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a malformed --var, got %d", code)
+	}
+
+	planDir := filepath.Join(tmpDir, "plan")
+	if code := run([]string{"-folder", planDir, "-plan", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a plan, got %d", code)
+	}
+	if code := run([]string{"-folder", planDir, "-plan", "-plan-format", "json", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a JSON plan, got %d", code)
+	}
+	if _, err := os.Stat(planDir); !os.IsNotExist(err) {
+		t.Errorf("Expected a plan not to write any report, got %v", err)
+	}
+	if code := run([]string{"-folder", tmpDir, "-plan", "-plan-format", "yaml", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid plan format, got %d", code)
+	}
+
+	// Signed playbooks
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	pubPath := filepath.Join(tmpDir, "playbook.pub.pem")
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signing.SignFile(priv, pbPath); err != nil {
+		t.Fatal(err)
+	}
+	// Both would pass if run: only the signature check can refuse them
+	unsignedPbPath := filepath.Join(tmpDir, "unsigned.yaml")
+	tamperedPbPath := filepath.Join(tmpDir, "tampered.yaml")
+	if err := os.WriteFile(unsignedPbPath, []byte(pbContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tamperedPbPath, []byte(pbContent+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"-folder", tmpDir, "-pubkey", pubPath, pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a signed playbook, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-pubkey", pubPath, "-signature", pbPath + ".sig", tamperedPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a playbook not matching its signature, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-pubkey", pubPath, unsignedPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an unsigned playbook, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-signature", pbPath + ".sig", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for --signature without a public key, got %d", code)
+	}
+	playbookPublicKey = base64.StdEncoding.EncodeToString(pub)
+	if code := run([]string{"-folder", tmpDir, pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a playbook signed with the embedded key, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, unsignedPbPath}); code != 1 {
+		t.Errorf("Expected the embedded key to require a signature, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-pubkey", pubPath, pbPath}); code != 1 {
+		t.Errorf("Expected --pubkey to be refused with an embedded key, got %d", code)
+	}
+	playbookPublicKey = ""
+
+	// TLS options are checked before anything runs
+	if code := run([]string{"-folder", tmpDir, "-pin", "not-a-pin", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid pin, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-client-cert", pubPath, pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a client certificate without key, got %d", code)
+	}
+
+	// Device keys
+	deviceKeyPath := filepath.Join(tmpDir, "keys", "device.key")
+	if code := run([]string{"enroll", "-key", deviceKeyPath, "-algorithm", "ecdsa-p256"}); code != 0 {
+		t.Errorf("Expected exit code 0 for creating a device key, got %d", code)
+	}
+	if code := run([]string{"enroll", "-key", deviceKeyPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for reusing a device key, got %d", code)
+	}
+	if code := run([]string{"enroll", "-key", filepath.Join(tmpDir, "keys", "rsa.key"), "-algorithm", "rsa"}); code != 1 {
+		t.Errorf("Expected exit code 1 for an unknown algorithm, got %d", code)
+	}
+	if code := run([]string{"enroll", "-key", deviceKeyPath, "-url", "http://hub.example.com/enroll"}); code != 1 {
+		t.Errorf("Expected exit code 1 for an insecure enrollment URL, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-device-key", deviceKeyPath, pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 with a device key, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-device-key", filepath.Join(tmpDir, "missing.key"), pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a missing device key, got %d", code)
+	}
+
+	// Encrypted reports
+	hubKeyPath := filepath.Join(tmpDir, "keys", "hub.key")
+	if code := run([]string{"decrypt", "-identity", hubKeyPath, "-keygen"}); code != 0 {
+		t.Errorf("Expected exit code 0 for creating an identity, got %d", code)
+	}
+	identities, err := encryption.LoadIdentities(hubKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := identities[0].(*age.X25519Identity).Recipient().String()
+	encryptedDir := filepath.Join(tmpDir, "encrypted")
+	if code := run([]string{"-folder", encryptedDir, "-recipient", recipient, pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 with a report recipient, got %d", code)
+	}
+	if code := run([]string{"-folder", encryptedDir, "-recipient", "not-a-key", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid report recipient, got %d", code)
+	}
+	encrypted, _ := filepath.Glob(filepath.Join(encryptedDir, "*.report.json.age"))
+	if len(encrypted) != 1 {
+		t.Fatalf("Expected one encrypted JSON report, got %v", encrypted)
+	}
+	decryptedDir := filepath.Join(tmpDir, "decrypted")
+	if code := run([]string{"decrypt", "-identity", hubKeyPath, "-out", decryptedDir, encrypted[0]}); code != 0 {
+		t.Errorf("Expected exit code 0 for decrypting a report, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(decryptedDir, filepath.Base(strings.TrimSuffix(encrypted[0], ".age")))); err != nil {
+		t.Errorf("Expected the decrypted report, got %v", err)
+	}
+	if code := run([]string{"decrypt", "-identity", hubKeyPath, pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for decrypting a plain file, got %d", code)
+	}
+	if code := run([]string{"decrypt", "-identity", deviceKeyPath, encrypted[0]}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid identity file, got %d", code)
+	}
+
+	// Report outputs
+	outputsDir := filepath.Join(tmpDir, "outputs")
+	if code := run([]string{"-folder", outputsDir, "-output", "sarif,junit,html", pbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 with a SARIF output, got %d", code)
+	}
+	if sarif, _ := filepath.Glob(filepath.Join(outputsDir, "*.report.sarif")); len(sarif) != 1 {
+		t.Errorf("Expected one SARIF report, got %v", sarif)
+	}
+	if junit, _ := filepath.Glob(filepath.Join(outputsDir, "*.report.junit.xml")); len(junit) != 1 {
+		t.Errorf("Expected one JUnit report, got %v", junit)
+	}
+	if html, _ := filepath.Glob(filepath.Join(outputsDir, "*.report.html")); len(html) != 1 {
+		t.Errorf("Expected one HTML report, got %v", html)
+	}
+	jsonReports, _ := filepath.Glob(filepath.Join(outputsDir, "*.report.json"))
+	if len(jsonReports) != 1 {
+		t.Fatalf("Expected one JSON report, got %v", jsonReports)
+	}
+	convertedDir := filepath.Join(tmpDir, "converted")
+	if code := run([]string{"convert", "-out", convertedDir, jsonReports[0]}); code != 0 {
+		t.Errorf("Expected exit code 0 for converting a report to OSCAL, got %d", code)
+	}
+	if code := run([]string{"convert", "-format", "xccdf", "-out", convertedDir, jsonReports[0]}); code != 0 {
+		t.Errorf("Expected exit code 0 for converting a report to XCCDF, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(convertedDir, strings.TrimSuffix(filepath.Base(jsonReports[0]), ".json")+".oscal.json")); err != nil {
+		t.Errorf("Expected the OSCAL report, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(convertedDir, strings.TrimSuffix(filepath.Base(jsonReports[0]), ".json")+".xccdf.xml")); err != nil {
+		t.Errorf("Expected the XCCDF report, got %v", err)
+	}
+	if code := run([]string{"convert", "-format", "openmetrics", "-out", convertedDir, jsonReports[0]}); code != 0 {
+		t.Errorf("Expected exit code 0 for converting a report to OpenMetrics, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(convertedDir, strings.TrimSuffix(filepath.Base(jsonReports[0]), ".json")+".om.txt")); err != nil {
+		t.Errorf("Expected the OpenMetrics report, got %v", err)
+	}
+	if code := run([]string{"convert", "-format", "sarif", jsonReports[0]}); code != 1 {
+		t.Errorf("Expected exit code 1 for a format needing the execution trace, got %d", code)
+	}
+	if code := run([]string{"convert", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for converting a playbook, got %d", code)
+	}
+	if code := run([]string{"convert"}); code != 1 {
+		t.Errorf("Expected exit code 1 without a report to convert, got %d", code)
+	}
+	if code := run([]string{"-folder", outputsDir, "-output", "pdf", pbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an unknown report output, got %d", code)
+	}
+
+	// Report spool
+	if code := run([]string{"flush-spool", "-spool-dir", filepath.Join(tmpDir, "spool")}); code != 0 {
+		t.Errorf("Expected exit code 0 for flushing an empty spool, got %d", code)
+	}
+	if code := run([]string{"flush-spool", "-spool-max-age", "0"}); code != 1 {
+		t.Errorf("Expected exit code 1 for flushing a disabled spool, got %d", code)
+	}
+	if code := run([]string{"flush-spool", "--invalid-flag"}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid flush-spool flag, got %d", code)
+	}
 
 	// 4. Test missing playbook file
 	if code := run([]string{"-folder", tmpDir, "non-existent.yaml"}); code != 1 {
@@ -92,6 +341,23 @@ sections:
 	if code := run([]string{"-folder", tmpDir, failingPbPath}); code != 1 {
 		t.Errorf("Expected exit code 1 for failing assertion, got %d", code)
 	}
+	writeWaiver := func(name, expires string) string {
+		path := filepath.Join(tmpDir, name)
+		content := "waivers:\n  - codes: [F1]\n    justification: Accepted\n    approver: CISO\n    expires: \"" + expires + "\"\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if code := run([]string{"-folder", tmpDir, "-waivers", writeWaiver("active.yaml", "2999-12-31"), failingPbPath}); code != 0 {
+		t.Errorf("Expected exit code 0 for a waived failure, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-waivers", writeWaiver("expired.yaml", "2000-01-01"), failingPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a failure under an expired waiver, got %d", code)
+	}
+	if code := run([]string{"-folder", tmpDir, "-waivers", writeWaiver("invalid.yaml", "someday"), failingPbPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid waiver file, got %d", code)
+	}
 
 	// 8. Test DispatchReport failure
 	dispatchErrPbPath := filepath.Join(tmpDir, "dispatch_err.yaml")
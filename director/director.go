@@ -1,9 +1,13 @@
 package director
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/benedictjohannes/crobe/executor"
@@ -15,207 +19,563 @@ var (
 	goos    = runtime.GOOS
 )
 
-func Run(config playbook.Playbook) executor.ExecutionTrace {
+// Options tune a run without changing the playbook.
+type Options struct {
+	// Selector restricts the run to a subset of assertions. The others are reported as skipped.
+	Selector playbook.Selector
+	// Remediate enables the remediation of failed assertions. Remediations never run otherwise.
+	Remediate executor.RemediationMode
+	// Waivers accept the failure of matching assertions, which are then reported as waived.
+	Waivers []playbook.Waiver
+}
+
+func Run(config playbook.Playbook, opts Options) executor.ExecutionTrace {
 	now := time.Now()
+	osName := platformName()
 
-	osName := goos
-	if osName == "darwin" {
-		osName = "mac"
+	trace := executor.ExecutionTrace{
+		Playbook:  config,
+		Username:  currentUser(),
+		Hostname:  hostname(),
+		OS:        osName,
+		Arch:      runtime.GOARCH,
+		Selection: opts.Selector,
+		Remediate: opts.Remediate,
 	}
+	trace.Timestamps.Start = now
 
-	username := os.Getenv("USER")
-	if username == "" {
-		username = os.Getenv("USERNAME")
+	defaultTimeout := config.GetDefaultTimeout()
+	var deadline time.Time
+	if runTimeout := config.GetRunTimeout(); runTimeout > 0 {
+		deadline = now.Add(runTimeout)
 	}
-
-	trace := executor.ExecutionTrace{
-		Playbook: config,
-		Username: username,
-		OS:       osName,
-		Arch:     runtime.GOARCH,
+	runner := assertionRunner{
+		runTimed: func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+			return execWithTimeout(e, context, defaultTimeout, deadline, config.Vars)
+		},
+		vars:      config.Vars,
+		osName:    osName,
+		remediate: opts.Remediate,
+		waivers:   opts.Waivers,
+		hostname:  trace.Hostname,
+		username:  trace.Username,
+		now:       now,
 	}
-	trace.Timestamps.Start = now
 
-	totalPassed := 0
-	totalFailed := 0
+	// Every assertion owns a slot in the trace and a console buffer, so the trace and the
+	// console keep the playbook order however assertions complete.
+	trace.Sections = make([]executor.SectionContext, len(config.Sections))
+	sectionOutputs := make([]bytes.Buffer, len(config.Sections))
+	jobs := make([][]*assertionJob, len(config.Sections))
+	var pending []*assertionJob
 
-	for _, section := range config.Sections {
-		fmt.Printf("  Processing Section: %s\n", section.Title)
-		sectionCtx := executor.SectionContext{
+	for s, section := range config.Sections {
+		trace.Sections[s] = executor.SectionContext{
 			PlaybookSection: section,
+			Assertions:      make([]executor.AssertionContext, len(section.Assertions)),
 		}
+		fmt.Fprintf(&sectionOutputs[s], "  Processing Section: %s\n", section.Title)
 
-		for _, assertion := range section.Assertions {
-			start := time.Now()
-			context := make(map[string]interface{})
-			score := 0
+		// Section applicability is decided once, before any of its assertions run.
+		sectionApplies, sectionReason, sectionErr := checkApplicability(section.Platforms, section.When, osName, config.Vars)
+		if sectionErr != nil {
+			fmt.Fprintf(&sectionOutputs[s], "    ⚠️ Section When Error (%s): %v\n", section.Title, sectionErr)
+		}
 
-			assCtx := executor.AssertionContext{
-				PlaybookAssertion: assertion,
-				Context:           make(map[string]interface{}),
+		for i, assertion := range section.Assertions {
+			job := &assertionJob{
+				assertion: assertion,
+				result:    &trace.Sections[s].Assertions[i],
+				done:      make(chan struct{}),
 			}
+			jobs[s] = append(jobs[s], job)
 
-			// 1. Pre-Commands
-			for _, exec := range assertion.PreCmds {
-				res, err := runExec(&exec, context)
-				assCtx.PreCmdLogs = append(assCtx.PreCmdLogs, executor.CommandLog{
-					Exec:   exec,
-					Result: res,
-					Err:    err,
-				})
-				if err != nil {
-					fmt.Printf("      ⚠️ PreCmd Error (%s): %v\n", assertion.Code, err)
-				}
+			selected, selectReason := opts.Selector.Selects(section, assertion)
+			switch {
+			case !selected:
+				job.finish(notRunAssertion(assertion, executor.VerdictSkipped, selectReason, nil))
+			case sectionErr != nil:
+				job.finish(notRunAssertion(assertion, executor.VerdictError, "", []string{fmt.Sprintf("section when: %v", sectionErr)}))
+			case !sectionApplies:
+				job.finish(notRunAssertion(assertion, executor.VerdictNotApplicable, "section "+sectionReason, nil))
+			default:
+				pending = append(pending, job)
 			}
+		}
+	}
 
-			// 2. Main Commands
-			var outputs []string
-			for _, cmd := range assertion.Cmds {
-				res, err := runExec(&cmd.Exec, context)
-				assCtx.CmdLogs = append(assCtx.CmdLogs, executor.CommandLog{
-					Exec:   cmd.Exec,
-					Result: res,
-					Err:    err,
-				})
-
-				if err != nil {
-					score += cmd.GetFailScore()
-					continue
-				}
-
-				if cmd.Exec.ExcludeFromReport {
-					outputs = append(outputs, "[REDACTED]")
+	// Serial assertions take the write lock, so they run alone; all others share the read lock.
+	sem := make(chan struct{}, config.GetConcurrency())
+	var serial sync.RWMutex
+	go func() {
+		for _, job := range pending {
+			sem <- struct{}{}
+			go func(job *assertionJob) {
+				defer func() { <-sem }()
+				if job.assertion.Serial {
+					serial.Lock()
+					defer serial.Unlock()
 				} else {
-					if res.Stderr != "" {
-						if len(res.Stdout) > 0 {
-							outputs = append(outputs, "# --- STDOUT ---")
-							outputs = append(outputs, res.Stdout)
-						}
-						outputs = append(outputs, "# --- STDERR ---")
-						outputs = append(outputs, res.Stderr)
-					} else {
-						outputs = append(outputs, res.Stdout)
-					}
+					serial.RLock()
+					defer serial.RUnlock()
 				}
+				job.finish(runner.evaluateAssertion(job.assertion, &job.out))
+			}(job)
+		}
+	}()
 
-				// Evaluation
-				result := 0
-				foundRule := false
-				for _, rule := range cmd.ExitCodeRules {
-					match := true
-					if rule.Min != nil && res.ExitCode < *rule.Min {
-						match = false
-					}
-					if rule.Max != nil && res.ExitCode > *rule.Max {
-						match = false
-					}
-					if match {
-						result = rule.Result
-						foundRule = true
-						break
-					}
-				}
+	totals := make(map[executor.Verdict]int)
+	for s := range config.Sections {
+		fmt.Print(sectionOutputs[s].String())
+		for _, job := range jobs[s] {
+			<-job.done
+			fmt.Print(job.out.String())
+			totals[job.result.Verdict]++
+		}
+	}
 
-				if !foundRule {
-					if res.ExitCode == 0 {
-						result = 1
-					} else {
-						result = -1
-					}
-				}
+	trace.Timestamps.End = time.Now()
+	trace.TotalPassed = totals[executor.VerdictPass]
+	trace.TotalFailed = totals[executor.VerdictFail]
+	trace.TotalErrored = totals[executor.VerdictError]
+	trace.TotalSkipped = totals[executor.VerdictSkipped]
+	trace.TotalNotApplicable = totals[executor.VerdictNotApplicable]
+	trace.TotalWaived = totals[executor.VerdictWaived]
 
-				if cmd.StdOutRule.Regex != "" || cmd.StdOutRule.Func != "" {
-					verdict, _ := executor.EvaluateRule(cmd.StdOutRule, res, context)
-					if verdict != 0 {
-						result = verdict
-					}
-				}
-				if cmd.StdErrRule.Regex != "" || cmd.StdErrRule.Func != "" {
-					verdict, _ := executor.EvaluateRule(cmd.StdErrRule, res, context)
-					if verdict != 0 {
-						result = verdict
-					}
-				}
+	return trace
+}
 
-				switch result {
-				case 1:
-					score += cmd.GetPassScore()
-				case -1:
-					score += cmd.GetFailScore()
-				}
+// assertionRunner holds what every assertion of a run shares.
+type assertionRunner struct {
+	runTimed func(*playbook.Exec, map[string]interface{}) (executor.ExecutionResult, error)
+	// vars are the effective playbook vars, exposed to every JS function as vars.
+	vars      map[string]interface{}
+	osName    string
+	remediate executor.RemediationMode
+	waivers   []playbook.Waiver
+	hostname  string
+	username  string
+	// now is the start of the run; waivers are checked for expiry against it.
+	now time.Time
+}
+
+// assertionJob is an assertion scheduled for execution, with its console output buffered
+// until it is printed in playbook order.
+type assertionJob struct {
+	assertion playbook.Assertion
+	result    *executor.AssertionContext
+	out       bytes.Buffer
+	done      chan struct{}
+}
+
+func (j *assertionJob) finish(assCtx executor.AssertionContext) {
+	*j.result = assCtx
+	if assCtx.Verdict == executor.VerdictNotApplicable || assCtx.Verdict == executor.VerdictSkipped {
+		fmt.Fprintf(&j.out, "    - %s: %s (%s)\n", j.assertion.Title, verdictStatus(assCtx.Verdict), assCtx.Reason)
+	} else if rem := assCtx.Remediation; rem != nil && rem.Mode == executor.RemediateApply && rem.Error == "" {
+		fmt.Fprintf(&j.out, "    - %s: %s after remediation, was %s (Score: %d/%d)\n", j.assertion.Title, verdictStatus(assCtx.Verdict), verdictStatus(rem.VerdictBefore), assCtx.Score, assCtx.MinScore)
+	} else {
+		fmt.Fprintf(&j.out, "    - %s: %s (Score: %d/%d)\n", j.assertion.Title, verdictStatus(assCtx.Verdict), assCtx.Score, assCtx.MinScore)
+	}
+	close(j.done)
+}
+
+// evaluateAssertion checks the applicability of an assertion and runs it when it applies.
+func (r assertionRunner) evaluateAssertion(assertion playbook.Assertion, out io.Writer) executor.AssertionContext {
+	applies, reason, err := checkApplicability(assertion.Platforms, assertion.When, r.osName, r.vars)
+	if err != nil {
+		fmt.Fprintf(out, "      ⚠️ When Error (%s): %v\n", assertion.Code, err)
+		return notRunAssertion(assertion, executor.VerdictError, "", []string{fmt.Sprintf("when: %v", err)})
+	}
+	if !applies {
+		return notRunAssertion(assertion, executor.VerdictNotApplicable, reason, nil)
+	}
+	assCtx := r.runAssertion(assertion, out)
+	r.applyWaiver(&assCtx, out)
+	return assCtx
+}
+
+// applyWaiver waives a failed assertion covered by an active waiver. A matching expired
+// waiver is recorded and flagged, but the assertion stays failed.
+func (r assertionRunner) applyWaiver(assCtx *executor.AssertionContext, out io.Writer) {
+	if assCtx.Verdict != executor.VerdictFail {
+		return
+	}
+	code := assCtx.PlaybookAssertion.Code
+	waiver, expired := playbook.FindWaiver(r.waivers, code, r.hostname, r.username, r.now)
+	if waiver == nil {
+		return
+	}
+	assCtx.Waiver = &executor.WaiverTrace{Waiver: *waiver, Expired: expired}
+	if expired {
+		fmt.Fprintf(out, "      🚨 EXPIRED WAIVER (%s): %s expired on %s, the failure counts\n", code, waiver.Describe(), waiver.Expires)
+		return
+	}
+	assCtx.Verdict = executor.VerdictWaived
+	fmt.Fprintf(out, "      📝 Waived (%s) until %s by %s: %s\n", code, waiver.Expires, waiver.Approver, waiver.Justification)
+}
+
+// runAssertion executes an applicable assertion (preCmds, cmds, postCmds) and scores it.
+func (r assertionRunner) runAssertion(assertion playbook.Assertion, out io.Writer) executor.AssertionContext {
+	start := time.Now()
+	context := make(map[string]interface{})
+
+	assCtx := executor.AssertionContext{
+		PlaybookAssertion: assertion,
+		Context:           make(map[string]interface{}),
+	}
+
+	var errs []string
+
+	// 1. Pre-Commands
+	for i, exec := range assertion.PreCmds {
+		res, err := r.runTimed(&exec, context)
+		assCtx.PreCmdLogs = append(assCtx.PreCmdLogs, executor.CommandLog{
+			Exec:   exec,
+			Result: res,
+			Err:    err,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "      ⚠️ PreCmd Error (%s): %v\n", assertion.Code, err)
+			errs = append(errs, fmt.Sprintf("preCmd #%d: %v", i+1, err))
+		}
+		if res.TimedOut {
+			fmt.Fprintf(out, "      ⏱️ PreCmd Timed Out (%s) after %s\n", assertion.Code, exec.Timeout)
+		}
+	}
+
+	// 2. Main Commands
+	score, cmdLogs, outputs, cmdErrs := r.runCmds(assertion, context, out)
+	assCtx.CmdLogs = cmdLogs
+	assCtx.Outputs = outputs
+	errs = append(errs, cmdErrs...)
+
+	// 3. Post-Commands
+	for i, exec := range assertion.PostCmds {
+		res, err := r.runTimed(&exec, context)
+		assCtx.PostCmdLogs = append(assCtx.PostCmdLogs, executor.CommandLog{
+			Exec:   exec,
+			Result: res,
+			Err:    err,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "      ⚠️ PostCmd Error (%s): %v\n", assertion.Code, err)
+			errs = append(errs, fmt.Sprintf("postCmd #%d: %v", i+1, err))
+		}
+		if res.TimedOut {
+			fmt.Fprintf(out, "      ⏱️ PostCmd Timed Out (%s) after %s\n", assertion.Code, exec.Timeout)
+		}
+	}
+
+	assCtx.Verdict = scoreVerdict(errs, score, assertion.GetMinPassingScore())
+	assCtx.Errors = errs
+	assCtx.Score = score
+	assCtx.MinScore = assertion.GetMinPassingScore()
+
+	// 4. Remediation, only ever when explicitly requested
+	if assCtx.Verdict == executor.VerdictFail && assertion.Remediation != nil && r.remediate != executor.RemediateOff {
+		r.runRemediation(&assCtx, context, out)
+	}
+
+	assCtx.Timestamps.Start = start
+	assCtx.Timestamps.End = time.Now()
+
+	// Determine which keys to exclude from report
+	excludedKeys := make(map[string]bool)
+	for _, exec := range assertion.PreCmds {
+		for _, g := range exec.Gather {
+			if g.ExcludeFromReport {
+				excludedKeys[g.Key] = true
 			}
-			assCtx.Outputs = outputs
-
-			// 3. Post-Commands
-			for _, exec := range assertion.PostCmds {
-				res, err := runExec(&exec, context)
-				assCtx.PostCmdLogs = append(assCtx.PostCmdLogs, executor.CommandLog{
-					Exec:   exec,
-					Result: res,
-					Err:    err,
-				})
-				if err != nil {
-					fmt.Printf("      ⚠️ PostCmd Error (%s): %v\n", assertion.Code, err)
-				}
+		}
+	}
+	for _, cmd := range assertion.Cmds {
+		for _, g := range cmd.Exec.Gather {
+			if g.ExcludeFromReport {
+				excludedKeys[g.Key] = true
+			}
+		}
+	}
+	for _, exec := range assertion.PostCmds {
+		for _, g := range exec.Gather {
+			if g.ExcludeFromReport {
+				excludedKeys[g.Key] = true
 			}
+		}
+	}
+	if assertion.Remediation != nil {
+		for _, g := range assertion.Remediation.Exec.Gather {
+			if g.ExcludeFromReport {
+				excludedKeys[g.Key] = true
+			}
+		}
+	}
 
-			passed := score >= assertion.GetMinPassingScore()
-			if passed {
-				totalPassed++
-			} else {
-				totalFailed++
+	for k, v := range context {
+		if !excludedKeys[k] {
+			assCtx.Context[k] = v
+		}
+	}
+
+	return assCtx
+}
+
+// scoreVerdict decides the verdict of an assertion that ran: any error makes it an error,
+// otherwise it passes once score reaches minScore.
+func scoreVerdict(errs []string, score int, minScore int) executor.Verdict {
+	if len(errs) > 0 {
+		return executor.VerdictError
+	}
+	if score >= minScore {
+		return executor.VerdictPass
+	}
+	return executor.VerdictFail
+}
+
+// runRemediation resolves (dry-run) or applies the remediation of a failed assertion. Once
+// applied, the assertion's cmds are re-run to verify the fix and assCtx takes their outcome.
+func (r assertionRunner) runRemediation(assCtx *executor.AssertionContext, context map[string]interface{}, out io.Writer) {
+	assertion := assCtx.PlaybookAssertion
+	remediation := assertion.Remediation
+	trace := &executor.RemediationTrace{
+		Mode:          r.remediate,
+		VerdictBefore: assCtx.Verdict,
+		ScoreBefore:   assCtx.Score,
+	}
+	assCtx.Remediation = trace
+
+	if r.remediate == executor.RemediateDryRun {
+		script, _, err := executor.ResolveExec(remediation.Exec, context, r.vars)
+		if err != nil {
+			fmt.Fprintf(out, "      ⚠️ Remediation Error (%s): %v\n", assertion.Code, err)
+			trace.Error = err.Error()
+			return
+		}
+		trace.Script = script
+		fmt.Fprintf(out, "      🔧 Remediation (%s) would run: %s\n", assertion.Code, remediation.Description)
+		return
+	}
+
+	fmt.Fprintf(out, "      🔧 Applying Remediation (%s): %s\n", assertion.Code, remediation.Description)
+	exec := remediation.Exec
+	res, err := r.runTimed(&exec, context)
+	trace.Script = exec.Script
+	trace.Log = &executor.CommandLog{Exec: exec, Result: res, Err: err}
+	if err != nil {
+		fmt.Fprintf(out, "      ⚠️ Remediation Error (%s): %v\n", assertion.Code, err)
+		trace.Error = err.Error()
+		return
+	}
+	if res.TimedOut {
+		fmt.Fprintf(out, "      ⏱️ Remediation Timed Out (%s) after %s\n", assertion.Code, exec.Timeout)
+		trace.Error = fmt.Sprintf("timed out after %s", exec.Timeout)
+		return
+	}
+
+	// Verify the fix
+	trace.CmdLogsBefore = assCtx.CmdLogs
+	trace.OutputsBefore = assCtx.Outputs
+	score, cmdLogs, outputs, errs := r.runCmds(assertion, context, out)
+	assCtx.CmdLogs = cmdLogs
+	assCtx.Outputs = outputs
+	assCtx.Errors = errs
+	assCtx.Score = score
+	assCtx.Verdict = scoreVerdict(errs, score, assCtx.MinScore)
+}
+
+// runCmds runs the main commands of an assertion and scores them.
+func (r assertionRunner) runCmds(assertion playbook.Assertion, context map[string]interface{}, out io.Writer) (score int, logs []executor.CommandLog, outputs []string, errs []string) {
+	for i, cmd := range assertion.Cmds {
+		res, err := r.runTimed(&cmd.Exec, context)
+		logs = append(logs, executor.CommandLog{
+			Exec:   cmd.Exec,
+			Result: res,
+			Err:    err,
+		})
+
+		if err != nil {
+			fmt.Fprintf(out, "      ⚠️ Cmd Error (%s): %v\n", assertion.Code, err)
+			errs = append(errs, fmt.Sprintf("cmd #%d: %v", i+1, err))
+			score += cmd.GetFailScore()
+			continue
+		}
+
+		if res.TimedOut {
+			fmt.Fprintf(out, "      ⏱️ Cmd Timed Out (%s) after %s\n", assertion.Code, cmd.Exec.Timeout)
+			if !cmd.Exec.ExcludeFromReport {
+				outputs = append(outputs, fmt.Sprintf("# --- TIMED OUT after %s ---", cmd.Exec.Timeout))
 			}
+			score += cmd.GetFailScore()
+			continue
+		}
 
-			assCtx.Passed = passed
-			assCtx.Score = score
-			assCtx.MinScore = assertion.GetMinPassingScore()
-			assCtx.Timestamps.Start = start
-			assCtx.Timestamps.End = time.Now()
-
-			// Determine which keys to exclude from report
-			excludedKeys := make(map[string]bool)
-			for _, exec := range assertion.PreCmds {
-				for _, g := range exec.Gather {
-					if g.ExcludeFromReport {
-						excludedKeys[g.Key] = true
-					}
+		if cmd.Exec.ExcludeFromReport {
+			outputs = append(outputs, "[REDACTED]")
+		} else {
+			if res.Stderr != "" {
+				if len(res.Stdout) > 0 {
+					outputs = append(outputs, "# --- STDOUT ---")
+					outputs = append(outputs, res.Stdout)
 				}
+				outputs = append(outputs, "# --- STDERR ---")
+				outputs = append(outputs, res.Stderr)
+			} else {
+				outputs = append(outputs, res.Stdout)
 			}
-			for _, cmd := range assertion.Cmds {
-				for _, g := range cmd.Exec.Gather {
-					if g.ExcludeFromReport {
-						excludedKeys[g.Key] = true
-					}
-				}
+		}
+
+		// Evaluation
+		result := 0
+		foundRule := false
+		for _, rule := range cmd.ExitCodeRules {
+			match := true
+			if rule.Min != nil && res.ExitCode < *rule.Min {
+				match = false
 			}
-			for _, exec := range assertion.PostCmds {
-				for _, g := range exec.Gather {
-					if g.ExcludeFromReport {
-						excludedKeys[g.Key] = true
-					}
-				}
+			if rule.Max != nil && res.ExitCode > *rule.Max {
+				match = false
+			}
+			if match {
+				result = rule.Result
+				foundRule = true
+				break
 			}
+		}
 
-			for k, v := range context {
-				if !excludedKeys[k] {
-					assCtx.Context[k] = v
-				}
+		if !foundRule {
+			if res.ExitCode == 0 {
+				result = 1
+			} else {
+				result = -1
 			}
+		}
 
-			status := "✅ PASS"
-			if !passed {
-				status = "❌ FAIL"
+		if cmd.StdOutRule.Regex != "" || cmd.StdOutRule.Func != "" {
+			verdict, err := executor.EvaluateRule(cmd.StdOutRule, res, context, r.vars)
+			if err != nil {
+				fmt.Fprintf(out, "      ⚠️ StdOutRule Error (%s): %v\n", assertion.Code, err)
+				errs = append(errs, fmt.Sprintf("stdOutRule of cmd #%d: %v", i+1, err))
+			} else if verdict != 0 {
+				result = verdict
 			}
-			fmt.Printf("    - %s: %s (Score: %d/%d)\n", assertion.Title, status, score, assertion.GetMinPassingScore())
+		}
+		if cmd.StdErrRule.Regex != "" || cmd.StdErrRule.Func != "" {
+			verdict, err := executor.EvaluateRule(cmd.StdErrRule, res, context, r.vars)
+			if err != nil {
+				fmt.Fprintf(out, "      ⚠️ StdErrRule Error (%s): %v\n", assertion.Code, err)
+				errs = append(errs, fmt.Sprintf("stdErrRule of cmd #%d: %v", i+1, err))
+			} else if verdict != 0 {
+				result = verdict
+			}
+		}
 
-			sectionCtx.Assertions = append(sectionCtx.Assertions, assCtx)
+		switch result {
+		case 1:
+			score += cmd.GetPassScore()
+		case -1:
+			score += cmd.GetFailScore()
 		}
-		trace.Sections = append(trace.Sections, sectionCtx)
 	}
+	return score, logs, outputs, errs
+}
 
-	trace.Timestamps.End = time.Now()
-	trace.TotalPassed = totalPassed
-	trace.TotalFailed = totalFailed
+// notRunAssertion records an assertion that was decided without running any of its executions.
+func notRunAssertion(assertion playbook.Assertion, verdict executor.Verdict, reason string, errs []string) executor.AssertionContext {
+	assCtx := executor.AssertionContext{
+		PlaybookAssertion: assertion,
+		Context:           make(map[string]interface{}),
+		Verdict:           verdict,
+		Reason:            reason,
+		Errors:            errs,
+		MinScore:          assertion.GetMinPassingScore(),
+	}
+	assCtx.Timestamps.Start = time.Now()
+	assCtx.Timestamps.End = assCtx.Timestamps.Start
+	return assCtx
+}
 
-	return trace
+// checkApplicability evaluates platform filters, then the when predicate. When not
+// applicable, reason explains why.
+func checkApplicability(platforms []playbook.Platform, when string, osName string, vars map[string]interface{}) (bool, string, error) {
+	if !playbook.AppliesTo(platforms, osName) {
+		names := make([]string, len(platforms))
+		for i, p := range platforms {
+			names[i] = string(p)
+		}
+		return false, fmt.Sprintf("not applicable on %s (platforms: %s)", osName, strings.Join(names, ", ")), nil
+	}
+	if when != "" {
+		applies, err := executor.EvaluateWhen(when, make(map[string]interface{}), vars)
+		if err != nil {
+			return false, "", err
+		}
+		if !applies {
+			return false, "when predicate is false", nil
+		}
+	}
+	return true, "", nil
+}
+
+// platformName returns the running OS as named in playbooks (linux, mac, windows).
+func platformName() string {
+	if goos == "darwin" {
+		return "mac"
+	}
+	return goos
+}
+
+func currentUser() string {
+	username := os.Getenv("USER")
+	if username == "" {
+		username = os.Getenv("USERNAME")
+	}
+	return username
+}
+
+// hostname returns the name of this machine, which waivers match against.
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+func verdictStatus(v executor.Verdict) string {
+	switch v {
+	case executor.VerdictPass:
+		return "✅ PASS"
+	case executor.VerdictFail:
+		return "❌ FAIL"
+	case executor.VerdictError:
+		return "⚠️ ERROR"
+	case executor.VerdictSkipped:
+		return "⏭️ SKIPPED"
+	case executor.VerdictNotApplicable:
+		return "➖ N/A"
+	case executor.VerdictWaived:
+		return "📝 WAIVED"
+	}
+	return string(v)
+}
+
+// execWithTimeout resolves the effective timeout of an execution (its own timeout, or the
+// playbook default, capped by the run deadline) into e.Timeout before running it.
+// Once the run deadline has passed, executions are not started and report as timed out.
+func execWithTimeout(e *playbook.Exec, context map[string]interface{}, defaultTimeout time.Duration, deadline time.Time, vars map[string]interface{}) (executor.ExecutionResult, error) {
+	timeout := e.GetTimeout()
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline).Round(time.Millisecond)
+		if remaining <= 0 {
+			e.Timeout = "0s"
+			return executor.ExecutionResult{ExitCode: -1, TimedOut: true}, nil
+		}
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	if timeout > 0 {
+		e.Timeout = timeout.String()
+	}
+	return runExec(e, context, vars)
 }
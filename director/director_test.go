@@ -3,7 +3,9 @@ package director
 import (
 	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/benedictjohannes/crobe/executor"
 	"github.com/benedictjohannes/crobe/playbook"
@@ -40,7 +42,7 @@ func TestDirectorScoring(t *testing.T) {
 
 	// Mock execution: first succeeds, second fails
 	callIdx := 0
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		callIdx++
 		if callIdx == 1 {
 			return executor.ExecutionResult{ExitCode: 0, Success: true, Stdout: "ok"}, nil
@@ -48,23 +50,23 @@ func TestDirectorScoring(t *testing.T) {
 		return executor.ExecutionResult{ExitCode: 1, Success: false, Stdout: "fail"}, nil
 	}
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	
 	ass := trace.Sections[0].Assertions[0]
-	if ass.Passed {
-		t.Errorf("Assertion passed with score %d; expected fail (min 2)", ass.Score)
+	if ass.Verdict != executor.VerdictFail {
+		t.Errorf("Assertion verdict %s with score %d; expected fail (min 2)", ass.Verdict, ass.Score)
 	}
 	if ass.Score != 0 {
 		t.Errorf("Assertion score = %d; want 0", ass.Score)
 	}
 
 	// Now try a passing case
-	mockExecPass := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExecPass := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
 	}
 	runExec = mockExecPass
-	trace2 := Run(config)
-	if !trace2.Sections[0].Assertions[0].Passed {
+	trace2 := Run(config, Options{})
+	if trace2.Sections[0].Assertions[0].Verdict != executor.VerdictPass {
 		t.Errorf("Assertion failed with score %d; expected pass (min 2)", trace2.Sections[0].Assertions[0].Score)
 	}
 }
@@ -106,7 +108,7 @@ func TestExcludeFromReport(t *testing.T) {
 		},
 	}
 
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		out := "sensitive_data"
 		res := executor.ExecutionResult{Stdout: out, Success: true, ExitCode: 0}
 		for _, g := range e.Gather {
@@ -116,7 +118,7 @@ func TestExcludeFromReport(t *testing.T) {
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 
 	ass := trace.Sections[0].Assertions[0]
 	if _, exists := ass.Context["sensitive"]; exists {
@@ -166,7 +168,7 @@ func TestDirector_Advanced(t *testing.T) {
 		},
 	}
 
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		if e.Script == "pre-cmd" {
 			context["pre"] = "pre-val"
 			return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
@@ -178,7 +180,7 @@ func TestDirector_Advanced(t *testing.T) {
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	
 	ass := trace.Sections[0].Assertions[0]
 	if ass.Context["pre"] != "pre-val" {
@@ -211,7 +213,7 @@ func TestDirector_ErrorCases(t *testing.T) {
 		},
 	}
 
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		if e.Script == "pre-fail" || e.Script == "post-fail" {
 			return executor.ExecutionResult{}, fmt.Errorf("error in command")
 		}
@@ -222,11 +224,66 @@ func TestDirector_ErrorCases(t *testing.T) {
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	ass := trace.Sections[0].Assertions[0]
 	if ass.Score != -1 {
 		t.Errorf("Score = %d; want -1 (from main error)", ass.Score)
 	}
+	if ass.Verdict != executor.VerdictError {
+		t.Errorf("Verdict = %s; want error", ass.Verdict)
+	}
+	if len(ass.Errors) != 3 {
+		t.Errorf("Errors = %v; want preCmd, cmd and postCmd errors", ass.Errors)
+	}
+	if trace.TotalErrored != 1 || trace.TotalFailed != 0 {
+		t.Errorf("TotalErrored = %d, TotalFailed = %d; want 1, 0", trace.TotalErrored, trace.TotalFailed)
+	}
+}
+
+func TestDirector_RuleErrors(t *testing.T) {
+	config := playbook.Playbook{
+		Title: "Rule Error Test",
+		Sections: []playbook.Section{
+			{
+				Title: "S1",
+				Assertions: []playbook.Assertion{
+					{
+						Code: "RULE_01",
+						Cmds: []playbook.Cmd{
+							{
+								Exec:       playbook.Exec{Script: "ok"},
+								StdOutRule: playbook.EvaluationRule{Func: "() => { throw new Error('broken rule') }"},
+							},
+						},
+					},
+					{
+						Code: "RULE_02",
+						Cmds: []playbook.Cmd{
+							{
+								Exec:       playbook.Exec{Script: "ok"},
+								StdErrRule: playbook.EvaluationRule{Regex: "[["},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		return executor.ExecutionResult{ExitCode: 0, Success: true, Stdout: "ok"}, nil
+	}
+	trace := Run(config, Options{})
+
+	for i, code := range []string{"RULE_01", "RULE_02"} {
+		ass := trace.Sections[0].Assertions[i]
+		if ass.Verdict != executor.VerdictError {
+			t.Errorf("%s verdict = %s; want error instead of a swallowed rule error", code, ass.Verdict)
+		}
+		if len(ass.Errors) != 1 {
+			t.Errorf("%s errors = %v; want exactly one rule error", code, ass.Errors)
+		}
+	}
 }
 
 func TestDirector_EnvUsage(t *testing.T) {
@@ -248,12 +305,12 @@ func TestDirector_EnvUsage(t *testing.T) {
 			},
 		},
 	}
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		return executor.ExecutionResult{Stdout: "ok", Stderr: "some error"}, nil
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	
 	if trace.Username != "testuser" {
 		t.Errorf("Username = %s; want testuser", trace.Username)
@@ -282,7 +339,7 @@ func TestDirector_DefaultExitCode(t *testing.T) {
 		},
 	}
 
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		if e.Script == "ok" {
 			return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
 		}
@@ -290,12 +347,12 @@ func TestDirector_DefaultExitCode(t *testing.T) {
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	
-	if !trace.Sections[0].Assertions[0].Passed {
+	if trace.Sections[0].Assertions[0].Verdict != executor.VerdictPass {
 		t.Errorf("E_PASS should have passed")
 	}
-	if trace.Sections[0].Assertions[1].Passed {
+	if trace.Sections[0].Assertions[1].Verdict != executor.VerdictFail {
 		t.Errorf("E_FAIL should have failed")
 	}
 }
@@ -344,7 +401,7 @@ func TestDirector_CoverageBoost(t *testing.T) {
 		},
 	}
 
-	mockExec := func(e *playbook.Exec, context map[string]interface{}) (executor.ExecutionResult, error) {
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
 		if e.Script == "pre-gather" {
 			context["pre_secret"] = "pre-secret-val"
 			return executor.ExecutionResult{Success: true}, nil
@@ -373,7 +430,7 @@ func TestDirector_CoverageBoost(t *testing.T) {
 	}
 
 	runExec = mockExec
-	trace := Run(config)
+	trace := Run(config, Options{})
 	
 	if trace.OS != "mac" {
 		t.Errorf("OS = %s; want mac", trace.OS)
@@ -387,3 +444,500 @@ func TestDirector_CoverageBoost(t *testing.T) {
 		t.Errorf("post_secret should be excluded from report context")
 	}
 }
+
+func TestDirector_Timeouts(t *testing.T) {
+	config := playbook.Playbook{
+		Title:          "Timeout Test",
+		DefaultTimeout: "2m",
+		Sections: []playbook.Section{
+			{
+				Title: "S1",
+				Assertions: []playbook.Assertion{
+					{
+						Code: "T_01",
+						Cmds: []playbook.Cmd{
+							{Exec: playbook.Exec{Script: "hang", Timeout: "5s"}, FailScore: func(i int) *int { return &i }(-3)},
+							{Exec: playbook.Exec{Script: "default"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	timeouts := map[string]string{}
+	mockExec := func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		timeouts[e.Script] = e.Timeout
+		if e.Script == "hang" {
+			return executor.ExecutionResult{ExitCode: -1, TimedOut: true}, nil
+		}
+		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+	}
+
+	runExec = mockExec
+	trace := Run(config, Options{})
+
+	if timeouts["hang"] != "5s" {
+		t.Errorf("hang timeout = %q; want own timeout 5s", timeouts["hang"])
+	}
+	if timeouts["default"] != "2m0s" {
+		t.Errorf("default timeout = %q; want playbook default 2m0s", timeouts["default"])
+	}
+
+	ass := trace.Sections[0].Assertions[0]
+	if ass.Score != -2 {
+		t.Errorf("Score = %d; want -2 (failScore of timed out cmd + passScore)", ass.Score)
+	}
+	if ass.Verdict != executor.VerdictFail {
+		t.Errorf("Assertion with timed out cmd should fail, got %s", ass.Verdict)
+	}
+	if !ass.CmdLogs[0].Result.TimedOut || ass.CmdLogs[0].Exec.Timeout != "5s" {
+		t.Errorf("CmdLog should record the timeout outcome, got %+v", ass.CmdLogs[0])
+	}
+}
+
+func TestDirector_RunDeadline(t *testing.T) {
+	executed := 0
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		executed++
+		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+	}
+
+	// Deadline already passed: nothing is started
+	res, err := execWithTimeout(&playbook.Exec{Script: "late"}, nil, 0, time.Now().Add(-time.Second), nil)
+	if err != nil || !res.TimedOut {
+		t.Errorf("execWithTimeout(past deadline) = %+v, %v; want TimedOut: true", res, err)
+	}
+	if executed != 0 {
+		t.Errorf("execution should not start after the run deadline")
+	}
+
+	// Remaining time caps a longer timeout
+	e := &playbook.Exec{Script: "capped", Timeout: "1h"}
+	execWithTimeout(e, nil, 0, time.Now().Add(time.Minute), nil)
+	if d := e.GetTimeout(); d <= 0 || d > time.Minute {
+		t.Errorf("timeout = %v; want capped to the remaining run time", d)
+	}
+
+	// No timeout configured at all
+	e2 := &playbook.Exec{Script: "free"}
+	execWithTimeout(e2, nil, 0, time.Time{}, nil)
+	if e2.Timeout != "" {
+		t.Errorf("timeout = %q; want none", e2.Timeout)
+	}
+}
+
+func TestDirector_Applicability(t *testing.T) {
+	oldOS := goos
+	goos = "linux"
+	defer func() { goos = oldOS }()
+
+	config := playbook.Playbook{
+		Title: "Applicability Test",
+		Sections: []playbook.Section{
+			{
+				Title: "Mixed",
+				Assertions: []playbook.Assertion{
+					{Code: "ANY", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "any"}}}},
+					{Code: "LINUX", Platforms: []playbook.Platform{playbook.PlatformLinux}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "linux"}}}},
+					{Code: "WIN", Platforms: []playbook.Platform{playbook.PlatformWindows}, PreCmds: []playbook.Exec{{Script: "win-pre"}}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "win"}}}},
+					{Code: "WHEN_FALSE", When: "() => false", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "when-false"}}}},
+					{Code: "WHEN_TRUE", When: "({ os }) => os.length > 0", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "when-true"}}}},
+					{Code: "WHEN_ERR", When: "() => { throw new Error('boom') }", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "when-err"}}}},
+				},
+			},
+			{
+				Title:     "Mac Only",
+				Platforms: []playbook.Platform{playbook.PlatformMac},
+				Assertions: []playbook.Assertion{
+					{Code: "MAC_01", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "mac1"}}}},
+					{Code: "MAC_02", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "mac2"}}}},
+				},
+			},
+			{
+				Title: "Disabled",
+				When:  "false",
+				Assertions: []playbook.Assertion{
+					{Code: "DIS_01", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "dis1"}}}},
+				},
+			},
+		},
+	}
+
+	executed := map[string]bool{}
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		executed[e.Script] = true
+		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+	}
+	trace := Run(config, Options{})
+
+	verdicts := map[string]executor.AssertionContext{}
+	for _, s := range trace.Sections {
+		for _, a := range s.Assertions {
+			verdicts[a.PlaybookAssertion.Code] = a
+		}
+	}
+
+	tests := []struct {
+		code    string
+		verdict executor.Verdict
+		script  string
+	}{
+		{"ANY", executor.VerdictPass, "any"},
+		{"LINUX", executor.VerdictPass, "linux"},
+		{"WIN", executor.VerdictNotApplicable, "win"},
+		{"WHEN_FALSE", executor.VerdictNotApplicable, "when-false"},
+		{"WHEN_TRUE", executor.VerdictPass, "when-true"},
+		{"WHEN_ERR", executor.VerdictError, "when-err"},
+		{"MAC_01", executor.VerdictNotApplicable, "mac1"},
+		{"MAC_02", executor.VerdictNotApplicable, "mac2"},
+		{"DIS_01", executor.VerdictNotApplicable, "dis1"},
+	}
+	for _, tt := range tests {
+		a := verdicts[tt.code]
+		if a.Verdict != tt.verdict {
+			t.Errorf("%s verdict = %s; want %s", tt.code, a.Verdict, tt.verdict)
+		}
+		if ran := executed[tt.script]; ran != (tt.verdict == executor.VerdictPass) {
+			t.Errorf("%s executed = %v", tt.code, ran)
+		}
+	}
+	if executed["win-pre"] {
+		t.Errorf("preCmds of a not applicable assertion should not run")
+	}
+	if r := verdicts["WIN"].Reason; r != "not applicable on linux (platforms: windows)" {
+		t.Errorf("WIN reason = %q", r)
+	}
+	if r := verdicts["MAC_01"].Reason; r != "section not applicable on linux (platforms: mac)" {
+		t.Errorf("MAC_01 reason = %q", r)
+	}
+	if r := verdicts["WHEN_FALSE"].Reason; r != "when predicate is false" {
+		t.Errorf("WHEN_FALSE reason = %q", r)
+	}
+	if errs := verdicts["WHEN_ERR"].Errors; len(errs) != 1 {
+		t.Errorf("WHEN_ERR errors = %v; want the predicate error", errs)
+	}
+
+	if trace.TotalPassed != 3 || trace.TotalNotApplicable != 5 || trace.TotalErrored != 1 || trace.TotalFailed != 0 {
+		t.Errorf("unexpected totals: passed %d, n/a %d, errored %d, failed %d", trace.TotalPassed, trace.TotalNotApplicable, trace.TotalErrored, trace.TotalFailed)
+	}
+}
+
+func TestDirector_Parallel(t *testing.T) {
+	var assertions []playbook.Assertion
+	for i := 0; i < 8; i++ {
+		code := fmt.Sprintf("P_%02d", i)
+		assertions = append(assertions, playbook.Assertion{
+			Code:   code,
+			Title:  code,
+			Serial: i == 5,
+			Cmds:   []playbook.Cmd{{Exec: playbook.Exec{Script: code}}},
+		})
+	}
+	config := playbook.Playbook{
+		Title:       "Parallel Test",
+		Concurrency: 4,
+		Sections: []playbook.Section{
+			{Title: "S1", Assertions: assertions[:4]},
+			{Title: "S2", Assertions: assertions[4:]},
+		},
+	}
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	activeDuringSerial := -1
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		if e.Script == "P_05" {
+			activeDuringSerial = active
+		}
+		mu.Unlock()
+
+		// Assertions must not see each other's context
+		if _, ok := context["seen"]; ok {
+			t.Errorf("%s: context leaked from another assertion", e.Script)
+		}
+		context["seen"] = e.Script
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return executor.ExecutionResult{ExitCode: 0, Success: true, Stdout: e.Script}, nil
+	}
+	trace := Run(config, Options{})
+
+	if maxActive < 2 || maxActive > 4 {
+		t.Errorf("max concurrent executions = %d; want between 2 and 4", maxActive)
+	}
+	if activeDuringSerial != 1 {
+		t.Errorf("serial assertion ran alongside %d other executions", activeDuringSerial-1)
+	}
+
+	i := 0
+	for _, s := range trace.Sections {
+		for _, a := range s.Assertions {
+			want := fmt.Sprintf("P_%02d", i)
+			if a.PlaybookAssertion.Code != want || a.Context["seen"] != want {
+				t.Errorf("trace position %d holds %s (context %v); want %s", i, a.PlaybookAssertion.Code, a.Context["seen"], want)
+			}
+			i++
+		}
+	}
+	if trace.TotalPassed != 8 {
+		t.Errorf("TotalPassed = %d; want 8", trace.TotalPassed)
+	}
+}
+
+func TestDirector_Selection(t *testing.T) {
+	config := playbook.Playbook{
+		Title: "Selection Test",
+		Sections: []playbook.Section{
+			{
+				Title: "Network",
+				Assertions: []playbook.Assertion{
+					{Code: "SSH_ROOT", Tags: []string{"ssh"}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "ssh-root"}}}},
+					{Code: "SSH_SCAN", Tags: []string{"ssh", "slow"}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "ssh-scan"}}}},
+					{Code: "DNS", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "dns"}}}},
+				},
+			},
+			{
+				Title: "Filesystem",
+				Assertions: []playbook.Assertion{
+					{Code: "FS_PERMS", Tags: []string{"ssh"}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "fs-perms"}}}},
+				},
+			},
+		},
+	}
+
+	executed := map[string]bool{}
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		executed[e.Script] = true
+		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+	}
+	selector := playbook.Selector{Tags: []string{"ssh"}, ExcludeTags: []string{"slow"}, Sections: []string{"Net*"}}
+	trace := Run(config, Options{Selector: selector})
+
+	if !executed["ssh-root"] || executed["ssh-scan"] || executed["dns"] || executed["fs-perms"] {
+		t.Errorf("unexpected executions: %v", executed)
+	}
+	reasons := map[string]string{}
+	for _, s := range trace.Sections {
+		for _, a := range s.Assertions {
+			if a.Verdict == executor.VerdictSkipped {
+				reasons[a.PlaybookAssertion.Code] = a.Reason
+			}
+		}
+	}
+	want := map[string]string{
+		"SSH_SCAN": "excluded tag 'slow'",
+		"DNS":      "no selected tag",
+		"FS_PERMS": "section not selected",
+	}
+	for code, reason := range want {
+		if reasons[code] != reason {
+			t.Errorf("%s skip reason = %q; want %q", code, reasons[code], reason)
+		}
+	}
+	if trace.TotalPassed != 1 || trace.TotalSkipped != 3 {
+		t.Errorf("TotalPassed = %d, TotalSkipped = %d; want 1 and 3", trace.TotalPassed, trace.TotalSkipped)
+	}
+	if trace.Selection.String() != selector.String() {
+		t.Errorf("trace selection = %q; want %q", trace.Selection, selector)
+	}
+}
+
+func TestDirector_Remediation(t *testing.T) {
+	config := playbook.Playbook{
+		Title: "Remediation Test",
+		Sections: []playbook.Section{
+			{
+				Title: "S1",
+				Assertions: []playbook.Assertion{
+					{
+						Code: "PERMS",
+						Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "check-perms"}}},
+						Remediation: &playbook.Remediation{
+							Description: "Restrict permissions",
+							Exec:        playbook.Exec{Func: "({ assertionContext }) => 'chmod 600 ' + assertionContext.file"},
+						},
+						PreCmds: []playbook.Exec{{Script: "find-file", Gather: []playbook.GatherSpec{{Key: "file"}}}},
+					},
+					{
+						Code:        "PASSING",
+						Cmds:        []playbook.Cmd{{Exec: playbook.Exec{Script: "ok"}}},
+						Remediation: &playbook.Remediation{Description: "Never needed", Exec: playbook.Exec{Script: "never"}},
+					},
+					{
+						Code: "BROKEN_FIX",
+						Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "check-broken"}}},
+						Remediation: &playbook.Remediation{
+							Description: "Fix that cannot start",
+							Exec:        playbook.Exec{Script: "fix-broken"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var executed []string
+	fixed := false
+	setup := func() {
+		executed = nil
+		fixed = false
+		runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+			if e.Func != "" {
+				script, _, err := executor.ResolveExec(*e, context, nil)
+				if err != nil {
+					return executor.ExecutionResult{}, err
+				}
+				e.Script = script
+			}
+			executed = append(executed, e.Script)
+			switch e.Script {
+			case "find-file":
+				context["file"] = "/etc/app.key"
+			case "chmod 600 /etc/app.key":
+				fixed = true
+			case "check-perms":
+				if !fixed {
+					return executor.ExecutionResult{ExitCode: 1}, nil
+				}
+			case "check-broken":
+				return executor.ExecutionResult{ExitCode: 1}, nil
+			case "fix-broken":
+				return executor.ExecutionResult{ExitCode: -1}, fmt.Errorf("failed to start command: not found")
+			}
+			return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+		}
+	}
+	contains := func(scripts []string, script string) bool {
+		for _, s := range scripts {
+			if s == script {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Off by default: nothing is remediated
+	setup()
+	trace := Run(config, Options{})
+	if contains(executed, "chmod 600 /etc/app.key") || contains(executed, "fix-broken") {
+		t.Errorf("remediation ran without being requested: %v", executed)
+	}
+	if trace.Sections[0].Assertions[0].Remediation != nil {
+		t.Errorf("no remediation should be recorded without --remediate")
+	}
+
+	// Dry run: resolved, never executed
+	setup()
+	trace = Run(config, Options{Remediate: executor.RemediateDryRun})
+	if contains(executed, "chmod 600 /etc/app.key") {
+		t.Errorf("dry-run executed the remediation: %v", executed)
+	}
+	perms := trace.Sections[0].Assertions[0]
+	if perms.Verdict != executor.VerdictFail || perms.Remediation == nil || perms.Remediation.Script != "chmod 600 /etc/app.key" || perms.Remediation.Log != nil {
+		t.Errorf("dry-run remediation = %+v, verdict %s", perms.Remediation, perms.Verdict)
+	}
+	if trace.Remediate != executor.RemediateDryRun {
+		t.Errorf("trace remediation mode = %q", trace.Remediate)
+	}
+
+	// Apply: remediated, then verified
+	setup()
+	trace = Run(config, Options{Remediate: executor.RemediateApply})
+	perms = trace.Sections[0].Assertions[0]
+	if perms.Verdict != executor.VerdictPass || perms.Remediation.VerdictBefore != executor.VerdictFail {
+		t.Errorf("PERMS verdict = %s (before %s); want pass after remediation", perms.Verdict, perms.Remediation.VerdictBefore)
+	}
+	if perms.Remediation.Log == nil || perms.Remediation.Log.Exec.Script != "chmod 600 /etc/app.key" {
+		t.Errorf("remediation log = %+v", perms.Remediation.Log)
+	}
+	if len(perms.Remediation.CmdLogsBefore) != 1 || perms.Remediation.CmdLogsBefore[0].Result.ExitCode != 1 {
+		t.Errorf("cmd logs before remediation should be kept, got %+v", perms.Remediation.CmdLogsBefore)
+	}
+	if passing := trace.Sections[0].Assertions[1]; passing.Remediation != nil || contains(executed, "never") {
+		t.Errorf("passing assertions must not be remediated")
+	}
+	broken := trace.Sections[0].Assertions[2]
+	if broken.Verdict != executor.VerdictFail || broken.Remediation.Error == "" {
+		t.Errorf("BROKEN_FIX = %s, remediation %+v; want fail with the remediation error", broken.Verdict, broken.Remediation)
+	}
+	if trace.TotalPassed != 2 || trace.TotalFailed != 1 {
+		t.Errorf("totals after remediation: passed %d, failed %d; want 2 and 1", trace.TotalPassed, trace.TotalFailed)
+	}
+}
+
+func TestDirector_Waivers(t *testing.T) {
+	config := playbook.Playbook{
+		Title: "Waiver Test",
+		Sections: []playbook.Section{
+			{
+				Title: "S1",
+				Assertions: []playbook.Assertion{
+					{Code: "WAIVED", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "fail"}}}},
+					{Code: "LAPSED", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "fail"}}}},
+					{Code: "OTHER_HOST", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "fail"}}}},
+					{Code: "BROKEN", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "broken"}}}},
+					{Code: "PASSING", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "ok"}}}},
+				},
+			},
+		},
+	}
+	waiver := func(code string, expires string) playbook.Waiver {
+		return playbook.Waiver{Codes: []string{code}, Justification: "Accepted", Approver: "CISO", Expires: expires}
+	}
+	otherHost := waiver("OTHER_HOST", "2999-12-31")
+	otherHost.Hosts = []string{"no-such-host-*"}
+	waivers := []playbook.Waiver{
+		waiver("WAIVED", "2999-12-31"),
+		waiver("LAPSED", "2000-01-01"),
+		otherHost,
+		waiver("BROKEN", "2999-12-31"),
+		waiver("PASSING", "2999-12-31"),
+	}
+
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		switch e.Script {
+		case "fail":
+			return executor.ExecutionResult{ExitCode: 1}, nil
+		case "broken":
+			return executor.ExecutionResult{}, fmt.Errorf("cannot start")
+		}
+		return executor.ExecutionResult{ExitCode: 0, Success: true}, nil
+	}
+	trace := Run(config, Options{Waivers: waivers})
+
+	got := map[string]executor.AssertionContext{}
+	for _, a := range trace.Sections[0].Assertions {
+		got[a.PlaybookAssertion.Code] = a
+	}
+	if a := got["WAIVED"]; a.Verdict != executor.VerdictWaived || a.Waiver == nil || a.Waiver.Expired {
+		t.Errorf("WAIVED = %s, waiver %+v; want waived by an active waiver", a.Verdict, a.Waiver)
+	}
+	if a := got["LAPSED"]; a.Verdict != executor.VerdictFail || a.Waiver == nil || !a.Waiver.Expired {
+		t.Errorf("LAPSED = %s, waiver %+v; want fail with the expired waiver recorded", a.Verdict, a.Waiver)
+	}
+	if a := got["OTHER_HOST"]; a.Verdict != executor.VerdictFail || a.Waiver != nil {
+		t.Errorf("OTHER_HOST = %s, waiver %+v; want fail without waiver", a.Verdict, a.Waiver)
+	}
+	if a := got["BROKEN"]; a.Verdict != executor.VerdictError || a.Waiver != nil {
+		t.Errorf("BROKEN = %s, waiver %+v; errors must not be waived", a.Verdict, a.Waiver)
+	}
+	if a := got["PASSING"]; a.Verdict != executor.VerdictPass || a.Waiver != nil {
+		t.Errorf("PASSING = %s, waiver %+v; want pass without waiver", a.Verdict, a.Waiver)
+	}
+	if trace.TotalWaived != 1 || trace.TotalFailed != 2 || trace.TotalErrored != 1 || trace.TotalPassed != 1 {
+		t.Errorf("totals: waived %d, failed %d, errored %d, passed %d; want 1, 2, 1, 1",
+			trace.TotalWaived, trace.TotalFailed, trace.TotalErrored, trace.TotalPassed)
+	}
+	if trace.Hostname == "" {
+		t.Error("trace should record the hostname")
+	}
+}
@@ -0,0 +1,233 @@
+package director
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// Plan describes what a run would execute on this machine, without executing anything.
+type Plan struct {
+	Title     string                 `json:"title"`
+	OS        string                 `json:"os"`
+	Arch      string                 `json:"arch"`
+	Username  string                 `json:"username"`
+	Vars      map[string]interface{} `json:"vars,omitempty"`
+	Selection *playbook.Selector     `json:"selection,omitempty"`
+	Sections  []PlanSection          `json:"sections"`
+}
+
+type PlanSection struct {
+	Title      string          `json:"title"`
+	Assertions []PlanAssertion `json:"assertions"`
+}
+
+// Plan statuses of an assertion.
+const (
+	PlanRun           = "run"
+	PlanSkipped       = string(executor.VerdictSkipped)
+	PlanNotApplicable = string(executor.VerdictNotApplicable)
+	PlanError         = string(executor.VerdictError)
+)
+
+type PlanAssertion struct {
+	Code   string     `json:"code"`
+	Title  string     `json:"title"`
+	Status string     `json:"status"`
+	Reason string     `json:"reason,omitempty"`
+	Steps  []PlanStep `json:"steps,omitempty"`
+}
+
+// PlanStep is a single execution of an assertion, resolved against this machine.
+type PlanStep struct {
+	// Stage is one of preCmd, cmd, postCmd or remediation.
+	Stage string `json:"stage"`
+	Index int    `json:"index"`
+	// Shell and Extension are empty when there is nothing to run.
+	Shell     string `json:"shell,omitempty"`
+	Extension string `json:"extension,omitempty"`
+	Script    string `json:"script"`
+	// Generated is set when the script or shell is produced by JS (func, shellFunc).
+	Generated bool     `json:"generated,omitempty"`
+	Timeout   string   `json:"timeout,omitempty"`
+	Gathers   []string `json:"gathers,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// gatherPlaceholder stands in for a value that would only be known after running a step.
+func gatherPlaceholder(key string) string {
+	return "<gathered:" + key + ">"
+}
+
+// BuildPlan walks the playbook like Run, but only resolves what each execution would run:
+// platform filters, when predicates and JS generators are evaluated against this machine,
+// with values gathered at runtime replaced by placeholders. Nothing is executed.
+func BuildPlan(config playbook.Playbook, opts Options) Plan {
+	osName := platformName()
+	plan := Plan{
+		Title:    config.Title,
+		OS:       osName,
+		Arch:     runtime.GOARCH,
+		Username: currentUser(),
+		Vars:     config.Vars,
+	}
+	if !opts.Selector.IsEmpty() {
+		selection := opts.Selector
+		plan.Selection = &selection
+	}
+	defaultTimeout := config.GetDefaultTimeout()
+
+	for _, section := range config.Sections {
+		planSection := PlanSection{Title: section.Title}
+		sectionApplies, sectionReason, sectionErr := checkApplicability(section.Platforms, section.When, osName, config.Vars)
+
+		for _, assertion := range section.Assertions {
+			planned := PlanAssertion{Code: assertion.Code, Title: assertion.Title, Status: PlanRun}
+			selected, selectReason := opts.Selector.Selects(section, assertion)
+			switch {
+			case !selected:
+				planned.Status, planned.Reason = PlanSkipped, selectReason
+			case sectionErr != nil:
+				planned.Status, planned.Reason = PlanError, fmt.Sprintf("section when: %v", sectionErr)
+			case !sectionApplies:
+				planned.Status, planned.Reason = PlanNotApplicable, "section "+sectionReason
+			default:
+				applies, reason, err := checkApplicability(assertion.Platforms, assertion.When, osName, config.Vars)
+				if err != nil {
+					planned.Status, planned.Reason = PlanError, fmt.Sprintf("when: %v", err)
+				} else if !applies {
+					planned.Status, planned.Reason = PlanNotApplicable, reason
+				} else {
+					planned.Steps = planSteps(assertion, defaultTimeout, config.Vars)
+				}
+			}
+			planSection.Assertions = append(planSection.Assertions, planned)
+		}
+		plan.Sections = append(plan.Sections, planSection)
+	}
+	return plan
+}
+
+func planSteps(assertion playbook.Assertion, defaultTimeout time.Duration, vars map[string]interface{}) []PlanStep {
+	context := make(map[string]interface{})
+	var steps []PlanStep
+	add := func(stage string, index int, e playbook.Exec) {
+		step := PlanStep{
+			Stage:     stage,
+			Index:     index,
+			Generated: e.Func != "" || e.ShellFunc != "",
+		}
+		if e.Timeout != "" {
+			step.Timeout = e.Timeout
+		} else if defaultTimeout > 0 {
+			step.Timeout = defaultTimeout.String()
+		}
+		script, shell, err := executor.ResolveExec(e, context, vars)
+		if err != nil {
+			step.Error = err.Error()
+		} else if script != "" {
+			step.Script = script
+			step.Shell, step.Extension = executor.ResolveShell(shell, e.ScriptFileExtension)
+		}
+		for _, g := range e.Gather {
+			step.Gathers = append(step.Gathers, g.Key)
+			context[g.Key] = gatherPlaceholder(g.Key)
+		}
+		steps = append(steps, step)
+	}
+
+	for i, e := range assertion.PreCmds {
+		add("preCmd", i, e)
+	}
+	for i, cmd := range assertion.Cmds {
+		add("cmd", i, cmd.Exec)
+	}
+	for i, e := range assertion.PostCmds {
+		add("postCmd", i, e)
+	}
+	if assertion.Remediation != nil {
+		add("remediation", 0, assertion.Remediation.Exec)
+	}
+	return steps
+}
+
+// HasErrors reports whether any assertion or step could not be resolved.
+func (p Plan) HasErrors() bool {
+	for _, section := range p.Sections {
+		for _, a := range section.Assertions {
+			if a.Status == PlanError {
+				return true
+			}
+			for _, step := range a.Steps {
+				if step.Error != "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Text renders the plan for human review.
+func (p Plan) Text() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📋 Plan: %s (%s/%s, user %s)\n", p.Title, p.OS, p.Arch, p.Username))
+	if p.Selection != nil {
+		b.WriteString(fmt.Sprintf("🔎 Selection: %s\n", p.Selection))
+	}
+	if len(p.Vars) > 0 {
+		b.WriteString(fmt.Sprintf("🔧 Vars: %s\n", playbook.FormatVars(p.Vars)))
+	}
+	b.WriteString("Nothing below has been executed. Values gathered at runtime are shown as <gathered:key>.\n")
+
+	for _, section := range p.Sections {
+		b.WriteString(fmt.Sprintf("\n== %s ==\n", section.Title))
+		for _, a := range section.Assertions {
+			switch a.Status {
+			case PlanRun:
+				b.WriteString(fmt.Sprintf("\n  [%s] %s\n", a.Code, a.Title))
+			case PlanError:
+				b.WriteString(fmt.Sprintf("\n  [%s] %s: ⚠️ ERROR (%s)\n", a.Code, a.Title, a.Reason))
+			default:
+				b.WriteString(fmt.Sprintf("\n  [%s] %s: %s (%s)\n", a.Code, a.Title, verdictStatus(executor.Verdict(a.Status)), a.Reason))
+			}
+			for _, step := range a.Steps {
+				b.WriteString("    " + step.header() + "\n")
+				for _, line := range strings.Split(strings.TrimRight(step.Script, "\n"), "\n") {
+					if line != "" {
+						b.WriteString("      | " + line + "\n")
+					}
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+func (s PlanStep) header() string {
+	header := fmt.Sprintf("%s #%d", s.Stage, s.Index+1)
+	if s.Error != "" {
+		return header + ": ⚠️ " + s.Error
+	}
+	if s.Script == "" {
+		return header + ": (nothing to run)"
+	}
+	details := []string{s.Shell}
+	if s.Extension != "" {
+		details = append(details, s.Extension)
+	}
+	if s.Timeout != "" {
+		details = append(details, "timeout "+s.Timeout)
+	}
+	if s.Generated {
+		details = append(details, "generated by JS")
+	}
+	if len(s.Gathers) > 0 {
+		details = append(details, "gathers "+strings.Join(s.Gathers, ", "))
+	}
+	return fmt.Sprintf("%s (%s)", header, strings.Join(details, ", "))
+}
@@ -0,0 +1,123 @@
+package director
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestBuildPlan(t *testing.T) {
+	oldOS := goos
+	goos = "linux"
+	defer func() { goos = oldOS }()
+	runExec = func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (executor.ExecutionResult, error) {
+		t.Fatalf("plan must not execute anything, got %q", e.Script)
+		return executor.ExecutionResult{}, nil
+	}
+
+	config := playbook.Playbook{
+		Title:          "Plan",
+		DefaultTimeout: "30s",
+		Sections: []playbook.Section{
+			{
+				Title: "S1",
+				Assertions: []playbook.Assertion{
+					{
+						Code: "GEN",
+						PreCmds: []playbook.Exec{
+							{Script: "id -u", Gather: []playbook.GatherSpec{{Key: "uid"}}},
+						},
+						Cmds: []playbook.Cmd{
+							{Exec: playbook.Exec{Func: "(ctx) => 'check ' + ctx.assertionContext.uid", Timeout: "5s"}},
+							{Exec: playbook.Exec{Func: "(ctx) => ''"}},
+						},
+						Remediation: &playbook.Remediation{Description: "fix", Exec: playbook.Exec{Script: "fix-it"}},
+					},
+					{Code: "WIN", Platforms: []playbook.Platform{playbook.PlatformWindows}, Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "dir"}}}},
+					{Code: "BAD", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Func: "(ctx) => { throw new Error('boom') }"}}}},
+					{Code: "OTHER", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Script: "true"}}}},
+				},
+			},
+		},
+	}
+
+	plan := BuildPlan(config, Options{Selector: playbook.Selector{Codes: []string{"GEN", "WIN", "BAD"}}})
+	if plan.OS != "linux" || plan.Selection == nil {
+		t.Fatalf("unexpected plan header: %+v", plan)
+	}
+	assertions := plan.Sections[0].Assertions
+
+	gen := assertions[0]
+	if gen.Status != PlanRun || len(gen.Steps) != 4 {
+		t.Fatalf("expected GEN to run 4 steps, got %+v", gen)
+	}
+	if pre := gen.Steps[0]; pre.Script != "id -u" || pre.Timeout != "30s" || pre.Gathers[0] != "uid" || pre.Generated {
+		t.Errorf("unexpected preCmd step: %+v", pre)
+	}
+	if cmd := gen.Steps[1]; cmd.Script != "check <gathered:uid>" || cmd.Timeout != "5s" || !cmd.Generated || cmd.Shell == "" {
+		t.Errorf("unexpected generated cmd step: %+v", cmd)
+	}
+	if empty := gen.Steps[2]; empty.Script != "" || empty.Shell != "" {
+		t.Errorf("expected an empty script to resolve no shell, got %+v", empty)
+	}
+	if rem := gen.Steps[3]; rem.Stage != "remediation" || rem.Script != "fix-it" {
+		t.Errorf("unexpected remediation step: %+v", rem)
+	}
+
+	if win := assertions[1]; win.Status != PlanNotApplicable || len(win.Steps) != 0 {
+		t.Errorf("expected WIN to be not applicable, got %+v", win)
+	}
+	if bad := assertions[2]; bad.Status != PlanRun || bad.Steps[0].Error == "" {
+		t.Errorf("expected BAD to carry a step error, got %+v", bad)
+	}
+	if other := assertions[3]; other.Status != PlanSkipped || other.Reason != "code not selected" {
+		t.Errorf("expected OTHER to be skipped, got %+v", other)
+	}
+	if !plan.HasErrors() {
+		t.Error("expected plan to report errors")
+	}
+
+	text := plan.Text()
+	for _, want := range []string{"📋 Plan: Plan (linux/", "[GEN]", "| check <gathered:uid>", "(nothing to run)", "generated by JS", "code not selected"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected plan text to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestBuildPlan_ConcurrentVars(t *testing.T) {
+	plan := func(path string) playbook.Playbook {
+		return playbook.Playbook{
+			Title: "Vars",
+			Vars:  map[string]interface{}{"path": path},
+			Sections: []playbook.Section{{
+				Title: "S1",
+				When:  "vars.path !== ''",
+				Assertions: []playbook.Assertion{
+					{Code: "CAT", Cmds: []playbook.Cmd{{Exec: playbook.Exec{Func: "({ vars }) => 'cat ' + vars.path"}}}},
+				},
+			}},
+		}
+	}
+
+	// Every plan sees its own vars, however many are built at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				steps := BuildPlan(plan(path), Options{}).Sections[0].Assertions[0].Steps
+				if len(steps) != 1 || steps[0].Script != "cat "+path {
+					t.Errorf("expected 'cat %s', got %+v", path, steps)
+					return
+				}
+			}
+		}(fmt.Sprintf("/etc/app%d.env", i))
+	}
+	wg.Wait()
+}
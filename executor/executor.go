@@ -2,6 +2,8 @@ package executor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +11,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/benedictjohannes/crobe/playbook"
@@ -17,51 +20,46 @@ import (
 	"github.com/dop251/goja"
 )
 
-type RunExecer func(e *playbook.Exec, context map[string]interface{}) (ExecutionResult, error)
+type RunExecer func(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (ExecutionResult, error)
 
 type ExecutionResult struct {
 	Stdout   string
 	Stderr   string
 	ExitCode int
 	Success  bool
+	TimedOut bool
+	// StartErr is set when the command could not be started at all (eg: missing binary).
+	StartErr error
 }
 
-func RunExec(e *playbook.Exec, context map[string]interface{}) (ExecutionResult, error) {
-	script := e.Script
+// killWaitDelay bounds how long a terminated command may keep its output pipes open
+// (eg: through orphaned grandchildren) before Wait gives up on them.
+var killWaitDelay = 2 * time.Second
 
-	// If Func is provided, it wins and generates the script
-	if e.Func != "" {
-		jsScript, err := RunJS(e.Func, context)
-		if err != nil {
-			return ExecutionResult{}, fmt.Errorf("JS error in Exec.Func: %v", err)
-		}
-		if jsScript == "" {
-			return ExecutionResult{Success: true}, nil // Skip execution if JS returns empty
-		}
-		script = jsScript
+// RunExec runs an execution, exposing the effective playbook vars to its JS functions as vars.
+func RunExec(e *playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (ExecutionResult, error) {
+	script, shell, err := ResolveExec(*e, context, vars)
+	if err != nil {
+		return ExecutionResult{}, err
 	}
 	e.Script = script
 
 	if script == "" {
-		return ExecutionResult{Success: true}, nil
+		return ExecutionResult{Success: true}, nil // Skip execution if there is nothing to run
 	}
 
-	shell := e.Shell
-	if e.ShellFunc != "" {
-		jsShell, err := RunJS(e.ShellFunc, context)
-		if err != nil {
-			return ExecutionResult{}, fmt.Errorf("JS error in Exec.ShellFunc: %v", err)
-		}
-		if jsShell != "" {
-			shell = jsShell
-		}
+	res := RunShellWithTimeout(script, shell, e.ScriptFileExtension, e.GetTimeout())
+	if res.StartErr != nil {
+		return res, fmt.Errorf("failed to start command: %v", res.StartErr)
+	}
+	if res.TimedOut {
+		// Output of a terminated command is partial, so nothing is gathered from it
+		return res, nil
 	}
-
-	res := RunShell(script, shell, e.ScriptFileExtension)
 
 	// Handle Gathering
 	for _, g := range e.Gather {
-		val, err := PerformGather(g, res, context)
+		val, err := PerformGather(g, res, context, vars)
 		if err != nil {
 			return res, fmt.Errorf("gather error for key %s: %v", g.Key, err)
 		}
@@ -71,13 +69,49 @@ func RunExec(e *playbook.Exec, context map[string]interface{}) (ExecutionResult,
 	return res, nil
 }
 
+// ResolveExec returns the script and shell an execution would run with, evaluating Func and
+// ShellFunc against context without running anything. An empty script means nothing would run.
+func ResolveExec(e playbook.Exec, context map[string]interface{}, vars map[string]interface{}) (script string, shell string, err error) {
+	script = e.Script
+
+	// If Func is provided, it wins and generates the script
+	if e.Func != "" {
+		script, err = RunJS(e.Func, context, vars)
+		if err != nil {
+			return "", "", fmt.Errorf("JS error in Exec.Func: %v", err)
+		}
+	}
+	if script == "" {
+		return "", "", nil
+	}
+
+	shell = e.Shell
+	if e.ShellFunc != "" {
+		jsShell, err := RunJS(e.ShellFunc, context, vars)
+		if err != nil {
+			return "", "", fmt.Errorf("JS error in Exec.ShellFunc: %v", err)
+		}
+		if jsShell != "" {
+			shell = jsShell
+		}
+	}
+	return script, shell, nil
+}
+
 func RunShell(command string, shell string, extension string) ExecutionResult {
-	var name string
-	var args []string
+	return RunShellWithTimeout(command, shell, extension, 0)
+}
 
-	tmpDir := os.TempDir()
-	var tmpFile string
+// tempScriptSeq keeps temporary script names unique when executions run concurrently.
+var tempScriptSeq atomic.Uint64
 
+func tempScriptPath(dir string, extension string) string {
+	return filepath.Join(dir, fmt.Sprintf("cp_%d_%d%s", time.Now().UnixNano(), tempScriptSeq.Add(1), extension))
+}
+
+// ResolveShell returns the shell a script runs with (the platform default when shell is
+// empty) and the extension of its temporary script file, including the leading dot.
+func ResolveShell(shell string, extension string) (string, string) {
 	if shell == "" {
 		switch runtime.GOOS {
 		case "windows":
@@ -96,6 +130,33 @@ func RunShell(command string, shell string, extension string) ExecutionResult {
 	if extension != "" && !strings.HasPrefix(extension, ".") {
 		extension = "." + extension
 	}
+	if extension == "" {
+		switch shell {
+		case "powershell", "pwsh":
+			extension = ".ps1"
+		case "bash", "sh":
+			extension = ".sh"
+		case "zsh":
+			extension = ".zsh"
+		}
+	}
+	if shell == "!" {
+		// Direct execution does not use a script file
+		extension = ""
+	}
+	return shell, extension
+}
+
+// RunShellWithTimeout behaves like RunShell, but terminates the command together with
+// every process it spawned once timeout elapses. A timeout of 0 means no limit.
+func RunShellWithTimeout(command string, shell string, extension string, timeout time.Duration) ExecutionResult {
+	var name string
+	var args []string
+
+	tmpDir := os.TempDir()
+	var tmpFile string
+
+	shell, extension = ResolveShell(shell, extension)
 
 	switch shell {
 	case "!":
@@ -108,26 +169,15 @@ func RunShell(command string, shell string, extension string) ExecutionResult {
 		args = segments[1:]
 	case "powershell", "pwsh":
 		name = shell
-		ext := ".ps1"
-		if extension != "" {
-			ext = extension
-		}
-		tmpFile = filepath.Join(tmpDir, fmt.Sprintf("cp_%d%s", time.Now().UnixNano(), ext))
+		tmpFile = tempScriptPath(tmpDir, extension)
 		script := fmt.Sprintf("$ErrorActionPreference = 'Stop'\n%s\n", command)
 		os.WriteFile(tmpFile, []byte(script), 0644)
 		args = []string{"-ExecutionPolicy", "Bypass", "-File", tmpFile}
 	case "bash", "sh", "zsh":
 		name = shell
 		base := filepath.Base(shell)
-		
-		ext := ".sh"
-		if extension != "" {
-			ext = extension
-		} else if base == "zsh" {
-			ext = ".zsh"
-		}
-		
-		tmpFile = filepath.Join(tmpDir, fmt.Sprintf("cp_%d%s", time.Now().UnixNano(), ext))
+
+		tmpFile = tempScriptPath(tmpDir, extension)
 		var script string
 		if base == "bash" || base == "zsh" {
 			script = fmt.Sprintf("set -o pipefail\n%s\n", command)
@@ -141,7 +191,7 @@ func RunShell(command string, shell string, extension string) ExecutionResult {
 		// and the script is appended as a temporary file.
 		shellSegments := strings.Fields(shell)
 		name = shellSegments[0]
-		tmpFile = filepath.Join(tmpDir, fmt.Sprintf("cp_%d%s", time.Now().UnixNano(), extension))
+		tmpFile = tempScriptPath(tmpDir, extension)
 		os.WriteFile(tmpFile, []byte(command), 0755)
 
 		args = append(shellSegments[1:], tmpFile)
@@ -151,20 +201,40 @@ func RunShell(command string, shell string, extension string) ExecutionResult {
 		defer os.Remove(tmpFile)
 	}
 
-	cmd := exec.Command(name, args...)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Env = append(os.Environ(), "TERM=dumb", "NO_COLOR=1", "LANG=en_US.UTF-8")
+	if timeout > 0 {
+		// Run in its own process group so the whole tree can be killed on timeout
+		setProcessGroup(cmd)
+		cmd.Cancel = func() error {
+			return killProcessTree(cmd)
+		}
+		cmd.WaitDelay = killWaitDelay
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	err := cmd.Run()
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
 	exitCode := 0
+	var startErr error
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
+		if exitError, ok := err.(*exec.ExitError); ok && !timedOut {
 			exitCode = exitError.ExitCode()
 		} else {
 			exitCode = -1
+			if !ok && !timedOut {
+				startErr = err
+			}
 		}
 	}
 
@@ -172,11 +242,38 @@ func RunShell(command string, shell string, extension string) ExecutionResult {
 		Stdout:   CleanupOutput(stdout.String()),
 		Stderr:   CleanupOutput(stderr.String()),
 		ExitCode: exitCode,
-		Success:  err == nil,
+		Success:  err == nil && !timedOut,
+		TimedOut: timedOut,
+		StartErr: startErr,
 	}
 }
 
-func RunJS(code string, context map[string]interface{}) (string, error) {
+func RunJS(code string, context map[string]interface{}, vars map[string]interface{}) (string, error) {
+	val, err := runScriptJS(code, context, vars)
+	if err != nil {
+		return "", err
+	}
+
+	if goja.IsUndefined(val) || goja.IsNull(val) {
+		return "", nil
+	}
+
+	return val.String(), nil
+}
+
+// EvaluateWhen runs a `when` predicate and reports whether its result is truthy.
+// It receives the same inputs as Exec.Func.
+func EvaluateWhen(code string, context map[string]interface{}, vars map[string]interface{}) (bool, error) {
+	val, err := runScriptJS(code, context, vars)
+	if err != nil {
+		return false, err
+	}
+	return val.ToBoolean(), nil
+}
+
+// runScriptJS evaluates code with the script inputs exposed as globals. If the result is a
+// function, it is called with ({ assertionContext, env, os, arch, user, cwd, vars }).
+func runScriptJS(code string, context map[string]interface{}, playbookVars map[string]interface{}) (goja.Value, error) {
 	vm := goja.New()
 
 	// Inject Context
@@ -207,14 +304,16 @@ func RunJS(code string, context map[string]interface{}) (string, error) {
 	vm.Set("user", user)
 	cwd, _ := os.Getwd()
 	vm.Set("cwd", cwd)
+	vars := jsVars(playbookVars)
+	vm.Set("vars", vars)
 
 	// Run code
 	val, err := vm.RunString(code)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Signature: ({ assertionContext, env, os, arch, user, cwd }) => string
+	// Signature: ({ assertionContext, env, os, arch, user, cwd, vars }) => any
 	if fn, ok := goja.AssertFunction(val); ok {
 		params := vm.NewObject()
 		params.Set("assertionContext", context)
@@ -223,22 +322,25 @@ func RunJS(code string, context map[string]interface{}) (string, error) {
 		params.Set("arch", runtime.GOARCH)
 		params.Set("user", user)
 		params.Set("cwd", cwd)
+		params.Set("vars", vars)
 
-		res, err := fn(goja.Undefined(), params)
-		if err != nil {
-			return "", err
-		}
-		return res.String(), nil
+		return fn(goja.Undefined(), params)
 	}
 
-	if goja.IsUndefined(val) || goja.IsNull(val) {
-		return "", nil
-	}
+	return val, nil
+}
 
-	return val.String(), nil
+// jsVars returns a copy of the playbook vars, so that a script modifying vars cannot affect
+// other scripts running concurrently.
+func jsVars(vars map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return copied
 }
 
-func PerformGather(g playbook.GatherSpec, res ExecutionResult, context map[string]interface{}) (string, error) {
+func PerformGather(g playbook.GatherSpec, res ExecutionResult, context map[string]interface{}, vars map[string]interface{}) (string, error) {
 	input := res.Stdout
 	if g.GetIncludeStdErr() && input == "" {
 		input = res.Stderr
@@ -250,6 +352,7 @@ func PerformGather(g playbook.GatherSpec, res ExecutionResult, context map[strin
 		vm.Set("stdout", res.Stdout)
 		vm.Set("stderr", res.Stderr)
 		vm.Set("assertionContext", context)
+		vm.Set("vars", jsVars(vars))
 
 		val, err := vm.RunString(g.Func)
 		if err != nil {
@@ -257,8 +360,8 @@ func PerformGather(g playbook.GatherSpec, res ExecutionResult, context map[strin
 		}
 
 		if fn, ok := goja.AssertFunction(val); ok {
-			// Signature: (stdout, stderr, assertionContext) => string
-			res, err := fn(goja.Undefined(), vm.ToValue(res.Stdout), vm.ToValue(res.Stderr), vm.ToValue(context))
+			// Signature: (stdout, stderr, assertionContext, vars) => string
+			res, err := fn(goja.Undefined(), vm.ToValue(res.Stdout), vm.ToValue(res.Stderr), vm.ToValue(context), vm.ToValue(jsVars(vars)))
 			if err != nil {
 				return "", err
 			}
@@ -285,7 +388,7 @@ func PerformGather(g playbook.GatherSpec, res ExecutionResult, context map[strin
 	return "", nil
 }
 
-func EvaluateRule(rule playbook.EvaluationRule, res ExecutionResult, context map[string]interface{}) (int, error) {
+func EvaluateRule(rule playbook.EvaluationRule, res ExecutionResult, context map[string]interface{}, vars map[string]interface{}) (int, error) {
 	input := res.Stdout
 	if rule.GetIncludeStdErr() && input == "" {
 		input = res.Stderr
@@ -296,6 +399,7 @@ func EvaluateRule(rule playbook.EvaluationRule, res ExecutionResult, context map
 		vm.Set("stdout", res.Stdout)
 		vm.Set("stderr", res.Stderr)
 		vm.Set("assertionContext", context)
+		vm.Set("vars", jsVars(vars))
 
 		val, err := vm.RunString(rule.Func)
 		if err != nil {
@@ -303,8 +407,8 @@ func EvaluateRule(rule playbook.EvaluationRule, res ExecutionResult, context map
 		}
 
 		if fn, ok := goja.AssertFunction(val); ok {
-			// Signature: (stdout, stderr, assertionContext) => -1 | 0 | 1
-			out, err := fn(goja.Undefined(), vm.ToValue(res.Stdout), vm.ToValue(res.Stderr), vm.ToValue(context))
+			// Signature: (stdout, stderr, assertionContext, vars) => -1 | 0 | 1
+			out, err := fn(goja.Undefined(), vm.ToValue(res.Stdout), vm.ToValue(res.Stderr), vm.ToValue(context), vm.ToValue(jsVars(vars)))
 			if err != nil {
 				return 0, err
 			}
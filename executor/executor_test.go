@@ -4,6 +4,7 @@ import (
 	"os/exec"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/benedictjohannes/crobe/playbook"
 )
@@ -70,7 +71,7 @@ func TestPerformGather(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := PerformGather(tt.spec, tt.res, make(map[string]interface{}))
+			got, err := PerformGather(tt.spec, tt.res, make(map[string]interface{}), nil)
 			if err != nil {
 				t.Fatalf("PerformGather() error: %v", err)
 			}
@@ -84,7 +85,7 @@ func TestPerformGather(t *testing.T) {
 	isTrue := true
 	spec := playbook.GatherSpec{Key: "err", Regex: "FATAL: (.*)", IncludeStdErr: &isTrue}
 	res := ExecutionResult{Stdout: "", Stderr: "FATAL: system crash"}
-	got, _ := PerformGather(spec, res, nil)
+	got, _ := PerformGather(spec, res, nil, nil)
 	if got != "system crash" {
 		t.Errorf("PerformGather(stderr) = %q; want \"system crash\"", got)
 	}
@@ -92,7 +93,7 @@ func TestPerformGather(t *testing.T) {
 	// Test Regex with no capture group
 	spec2 := playbook.GatherSpec{Key: "all", Regex: "Product v123"}
 	res2 := ExecutionResult{Stdout: "Product v123"}
-	got, _ = PerformGather(spec2, res2, nil)
+	got, _ = PerformGather(spec2, res2, nil, nil)
 	if got != "Product v123" {
 		t.Errorf("PerformGather(no-group) = %q; want \"Product v123\"", got)
 	}
@@ -100,42 +101,42 @@ func TestPerformGather(t *testing.T) {
 	// Test No match
 	spec3 := playbook.GatherSpec{Key: "none", Regex: "MISSING"}
 	res3 := ExecutionResult{Stdout: "nothing here"}
-	got, _ = PerformGather(spec3, res3, nil)
+	got, _ = PerformGather(spec3, res3, nil, nil)
 	if got != "" {
 		t.Errorf("PerformGather(no-match) = %q; want \"\"", got)
 	}
 
 	// Test invalid regex
 	spec4 := playbook.GatherSpec{Key: "err", Regex: "[["}
-	_, err := PerformGather(spec4, res3, nil)
+	_, err := PerformGather(spec4, res3, nil, nil)
 	if err == nil {
 		t.Error("PerformGather should return error on invalid regex")
 	}
 
 	// Test JS error
 	spec5 := playbook.GatherSpec{Key: "jserr", Func: "() => { throw new Error('ops') }"}
-	_, err = PerformGather(spec5, res3, nil)
+	_, err = PerformGather(spec5, res3, nil, nil)
 	if err == nil {
 		t.Error("PerformGather should return error on JS error")
 	}
 
 	// Test JS returning undefined
 	spec6 := playbook.GatherSpec{Key: "v", Func: "() => undefined"}
-	got, err = PerformGather(spec6, res, nil)
+	got, err = PerformGather(spec6, res, nil, nil)
 	if err != nil || got != "undefined" { // goja.Value.String() for undefined is "undefined"
 		t.Errorf("PerformGather(undefined) = %q, %v", got, err)
 	}
 
 	// Test JS returning number
 	spec7 := playbook.GatherSpec{Key: "v", Func: "() => 123"}
-	got, err = PerformGather(spec7, res, nil)
+	got, err = PerformGather(spec7, res, nil, nil)
 	if err != nil || got != "123" {
 		t.Errorf("PerformGather(123) = %q, %v", got, err)
 	}
 
 	// Test JS returning direct value
 	spec8 := playbook.GatherSpec{Key: "v", Func: "'direct value'"}
-	got, err = PerformGather(spec8, res, nil)
+	got, err = PerformGather(spec8, res, nil, nil)
 	if err != nil || got != "direct value" {
 		t.Errorf("PerformGather(direct) = %q, %v", got, err)
 	}
@@ -144,7 +145,7 @@ func TestPerformGather(t *testing.T) {
 func TestRunJS(t *testing.T) {
 	context := map[string]interface{}{"foo": "bar"}
 	code := "({ assertionContext }) => assertionContext.foo + 'baz'"
-	got, err := RunJS(code, context)
+	got, err := RunJS(code, context, nil)
 	if err != nil {
 		t.Fatalf("RunJS() error: %v", err)
 	}
@@ -153,7 +154,7 @@ func TestRunJS(t *testing.T) {
 	}
 
 	// Test direct code returning value
-	got, err = RunJS("'hello ' + os", context)
+	got, err = RunJS("'hello ' + os", context, nil)
 	if err != nil {
 		t.Fatalf("RunJS(direct) error: %v", err)
 	}
@@ -162,30 +163,145 @@ func TestRunJS(t *testing.T) {
 	}
 
 	// Test returning empty/null
-	got, err = RunJS("null", context)
+	got, err = RunJS("null", context, nil)
 	if err != nil || got != "" {
 		t.Errorf("RunJS(null) = %q, %v; want \"\", nil", got, err)
 	}
 
 	// Test syntax error
-	_, err = RunJS("this is not valid js", context)
+	_, err = RunJS("this is not valid js", context, nil)
 	if err == nil {
 		t.Error("RunJS should return error on syntax error")
 	}
 
 	// Test execution error within function
-	_, err = RunJS("() => { nonExistent() }", context)
+	_, err = RunJS("() => { nonExistent() }", context, nil)
 	if err == nil {
 		t.Error("RunJS should return error on JS execution error")
 	}
 }
 
+func TestVarsInJS(t *testing.T) {
+	vars := map[string]interface{}{"minVersion": 6, "path": "/etc/app.env"}
+	context := map[string]interface{}{}
+
+	got, err := RunJS("({ vars }) => 'cat ' + vars.path", context, vars)
+	if err != nil || got != "cat /etc/app.env" {
+		t.Errorf("RunJS(vars param) = %q, %v", got, err)
+	}
+	if got, err := RunJS("vars.minVersion + 1", context, vars); err != nil || got != "7" {
+		t.Errorf("RunJS(vars global) = %q, %v", got, err)
+	}
+	if _, err := RunJS("({ vars }) => { vars.path = 'changed' }", context, vars); err != nil {
+		t.Fatalf("RunJS(mutation) error: %v", err)
+	}
+	if vars["path"] != "/etc/app.env" {
+		t.Errorf("a script must not modify the playbook vars, got %v", vars["path"])
+	}
+
+	res := ExecutionResult{Stdout: "6.1.0"}
+	rule := playbook.EvaluationRule{Func: "(stdout, stderr, ctx, vars) => parseInt(stdout) >= vars.minVersion ? 1 : -1"}
+	if score, err := EvaluateRule(rule, res, context, vars); err != nil || score != 1 {
+		t.Errorf("EvaluateRule(vars) = %d, %v; want 1", score, err)
+	}
+	gather := playbook.GatherSpec{Key: "k", Func: "(stdout) => stdout + '@' + vars.path"}
+	if val, err := PerformGather(gather, res, context, vars); err != nil || val != "6.1.0@/etc/app.env" {
+		t.Errorf("PerformGather(vars) = %q, %v", val, err)
+	}
+}
+
+func TestResolveExec(t *testing.T) {
+	context := map[string]interface{}{"user": "alice"}
+	tests := []struct {
+		name       string
+		exec       playbook.Exec
+		wantScript string
+		wantShell  string
+		wantErr    bool
+	}{
+		{"Script", playbook.Exec{Script: "echo hi", Shell: "sh"}, "echo hi", "sh", false},
+		{"Func wins", playbook.Exec{Script: "echo hi", Func: "({ assertionContext }) => 'id ' + assertionContext.user"}, "id alice", "", false},
+		{"ShellFunc", playbook.Exec{Script: "echo hi", Shell: "sh", ShellFunc: "() => 'bash'"}, "echo hi", "bash", false},
+		{"Empty Func", playbook.Exec{Func: "() => ''", Shell: "sh"}, "", "", false},
+		{"Func error", playbook.Exec{Func: "() => { throw new Error('x') }"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, shell, err := ResolveExec(tt.exec, context, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveExec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if script != tt.wantScript || shell != tt.wantShell {
+				t.Errorf("ResolveExec() = %q, %q; want %q, %q", script, shell, tt.wantScript, tt.wantShell)
+			}
+		})
+	}
+}
+
+func TestResolveShell(t *testing.T) {
+	tests := []struct {
+		shell, extension   string
+		wantShell, wantExt string
+	}{
+		{"bash", "", "bash", ".sh"},
+		{"sh", "", "sh", ".sh"},
+		{"zsh", "", "zsh", ".zsh"},
+		{"pwsh", "", "pwsh", ".ps1"},
+		{"bash", "bash", "bash", ".bash"},
+		{"python3", "py", "python3", ".py"},
+		{"node", "", "node", ""},
+		{"!", "sh", "!", ""},
+	}
+	for _, tt := range tests {
+		shell, ext := ResolveShell(tt.shell, tt.extension)
+		if shell != tt.wantShell || ext != tt.wantExt {
+			t.Errorf("ResolveShell(%q, %q) = %q, %q; want %q, %q", tt.shell, tt.extension, shell, ext, tt.wantShell, tt.wantExt)
+		}
+	}
+
+	if shell, _ := ResolveShell("", ""); shell == "" {
+		t.Errorf("ResolveShell should default to the platform shell")
+	}
+}
+
+func TestEvaluateWhen(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    bool
+		wantErr bool
+	}{
+		{"true", true, false},
+		{"false", false, false},
+		{"() => true", true, false},
+		{"() => 0", false, false},
+		{"() => 'yes'", true, false},
+		{"() => undefined", false, false},
+		{"() => null", false, false},
+		{"({ os, arch }) => os.length > 0 && arch.length > 0", true, false},
+		{"({ env }) => env.CROBE_WHEN_TEST === '1'", true, false},
+		{"() => { throw new Error('boom') }", false, true},
+		{"not valid js", false, true},
+	}
+
+	t.Setenv("CROBE_WHEN_TEST", "1")
+	for _, tt := range tests {
+		got, err := EvaluateWhen(tt.code, map[string]interface{}{}, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("EvaluateWhen(%q) error = %v; wantErr %v", tt.code, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("EvaluateWhen(%q) = %v; want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
 func TestRunExec(t *testing.T) {
 	context := make(map[string]interface{})
 
 	// 1. Simple Script
 	e := &playbook.Exec{Script: "echo hello world"}
-	res, err := RunExec(e, context)
+	res, err := RunExec(e, context, nil)
 	if err != nil {
 		t.Fatalf("RunExec(simple) error: %v", err)
 	}
@@ -195,7 +311,7 @@ func TestRunExec(t *testing.T) {
 
 	// 2. JS Func generates script
 	e2 := &playbook.Exec{Func: "() => 'echo js script'"}
-	res, err = RunExec(e2, context)
+	res, err = RunExec(e2, context, nil)
 	if err != nil {
 		t.Fatalf("RunExec(js-func) error: %v", err)
 	}
@@ -210,7 +326,7 @@ func TestRunExec(t *testing.T) {
 			{Key: "test_key", Regex: "(\\d+)"},
 		},
 	}
-	res, err = RunExec(e3, context)
+	res, err = RunExec(e3, context, nil)
 	if err != nil {
 		t.Fatalf("RunExec(gather) error: %v", err)
 	}
@@ -220,21 +336,21 @@ func TestRunExec(t *testing.T) {
 
 	// 4. Empty script
 	e4 := &playbook.Exec{Script: ""}
-	res, err = RunExec(e4, context)
+	res, err = RunExec(e4, context, nil)
 	if err != nil || !res.Success {
 		t.Errorf("RunExec(empty) = %+v, %v; want Success: true, nil err", res, err)
 	}
 
 	// 5. JS error
 	e5 := &playbook.Exec{Func: "() => { throw new Error('ops') }"}
-	_, err = RunExec(e5, context)
+	_, err = RunExec(e5, context, nil)
 	if err == nil {
 		t.Error("RunExec should fail on JS error")
 	}
 
 	// 6. JS returns empty string
 	e6 := &playbook.Exec{Func: "() => ''"}
-	res, err = RunExec(e6, context)
+	res, err = RunExec(e6, context, nil)
 	if err != nil || !res.Success {
 		t.Errorf("RunExec(js-empty) = %+v, %v; want Success: true, nil err", res, err)
 	}
@@ -244,7 +360,7 @@ func TestRunExec(t *testing.T) {
 		ShellFunc: "() => '!'",
 		Script:    "echo shell_func_test",
 	}
-	res, err = RunExec(e7, context)
+	res, err = RunExec(e7, context, nil)
 	if err != nil {
 		t.Fatalf("RunExec(shell-func) error: %v", err)
 	}
@@ -257,7 +373,7 @@ func TestRunExec(t *testing.T) {
 		ShellFunc: "() => { throw new Error('shell error') }",
 		Script:    "echo fail",
 	}
-	_, err = RunExec(e8, context)
+	_, err = RunExec(e8, context, nil)
 	if err == nil {
 		t.Error("RunExec should fail on ShellFunc JS error")
 	}
@@ -268,19 +384,26 @@ func TestRunExec(t *testing.T) {
 		Shell:     "sh",
 		Script:    "echo hello",
 	}
-	res, err = RunExec(e9, context)
+	res, err = RunExec(e9, context, nil)
 	if err != nil || res.Stdout != "hello" {
 		t.Errorf("RunExec(ShellFunc empty) = %+v, %v; want \"hello\"", res, err)
 	}
 
-	// 10. PerformGather error in RunExec
+	// 10. Missing interpreter is an error, not just a failing exit code
+	e11 := &playbook.Exec{Shell: "/non/existent/shell/cp", Script: "echo hello"}
+	res, err = RunExec(e11, context, nil)
+	if err == nil || res.StartErr == nil {
+		t.Errorf("RunExec(missing shell) = %+v, %v; want start error", res, err)
+	}
+
+	// 11. PerformGather error in RunExec
 	e10 := &playbook.Exec{
 		Script: "echo hello",
 		Gather: []playbook.GatherSpec{
 			{Key: "fail", Regex: "[["},
 		},
 	}
-	_, err = RunExec(e10, context)
+	_, err = RunExec(e10, context, nil)
 	if err == nil {
 		t.Error("RunExec should fail on gather error")
 	}
@@ -315,7 +438,7 @@ func TestEvaluateRule(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := EvaluateRule(tt.rule, tt.res, make(map[string]interface{}))
+			got, err := EvaluateRule(tt.rule, tt.res, make(map[string]interface{}), nil)
 			if err != nil {
 				t.Fatalf("EvaluateRule() error: %v", err)
 			}
@@ -329,49 +452,49 @@ func TestEvaluateRule(t *testing.T) {
 	rule := playbook.EvaluationRule{Regex: "ERROR"}
 	res := ExecutionResult{Stdout: "", Stderr: "ERROR: something happened"}
 	// Without IncludeStdErr, it shouldn't find it if Stdout is empty
-	got, _ := EvaluateRule(rule, res, nil)
+	got, _ := EvaluateRule(rule, res, nil, nil)
 	if got == 1 {
 		t.Error("EvaluateRule should not match stderr by default if stdout is empty")
 	}
 
 	isTrue := true
 	rule.IncludeStdErr = &isTrue
-	got, _ = EvaluateRule(rule, res, nil)
+	got, _ = EvaluateRule(rule, res, nil, nil)
 	if got != 1 {
 		t.Error("EvaluateRule should match stderr when IncludeStdErr is true")
 	}
 
 	// Test invalid regex
 	rule2 := playbook.EvaluationRule{Regex: "[["}
-	_, err := EvaluateRule(rule2, res, nil)
+	_, err := EvaluateRule(rule2, res, nil, nil)
 	if err == nil {
 		t.Error("EvaluateRule should return error on invalid regex")
 	}
 
 	// Test no rule
 	rule3 := playbook.EvaluationRule{}
-	got, _ = EvaluateRule(rule3, res, nil)
+	got, _ = EvaluateRule(rule3, res, nil, nil)
 	if got != 0 {
 		t.Errorf("EvaluateRule(no-rule) = %d; want 0", got)
 	}
 
 	// Test JS error
 	rule4 := playbook.EvaluationRule{Func: "() => { throw new Error('ops') }"}
-	_, err = EvaluateRule(rule4, res, nil)
+	_, err = EvaluateRule(rule4, res, nil, nil)
 	if err == nil {
 		t.Error("EvaluateRule should return error on JS error")
 	}
 
 	// Test JS returning number directly
 	rule5 := playbook.EvaluationRule{Func: "1"}
-	got, _ = EvaluateRule(rule5, res, nil)
+	got, _ = EvaluateRule(rule5, res, nil, nil)
 	if got != 1 {
 		t.Errorf("EvaluateRule(direct-1) = %d; want 1", got)
 	}
 
 	// Test JS returning -1
 	rule6 := playbook.EvaluationRule{Func: "-1"}
-	got, _ = EvaluateRule(rule6, res, nil)
+	got, _ = EvaluateRule(rule6, res, nil, nil)
 	if got != -1 {
 		t.Errorf("EvaluateRule(direct--1) = %d; want -1", got)
 	}
@@ -486,3 +609,50 @@ func TestRunShell(t *testing.T) {
 		t.Errorf("RunShell(cat .txt) = %+v", res)
 	}
 }
+
+func TestRunShellWithTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process tree test uses a POSIX shell")
+	}
+
+	// No timeout hit
+	res := RunShellWithTimeout("echo quick", "sh", "", 5*time.Second)
+	if !res.Success || res.TimedOut || res.Stdout != "quick" {
+		t.Errorf("RunShellWithTimeout(quick) = %+v; want Success: true, TimedOut: false", res)
+	}
+
+	// The background sleep keeps stdout open: unless the whole process group is killed,
+	// Wait would block until killWaitDelay expires.
+	start := time.Now()
+	res = RunShellWithTimeout("sleep 30 &\nsleep 30", "sh", "", 200*time.Millisecond)
+	elapsed := time.Since(start)
+	if !res.TimedOut || res.Success || res.ExitCode != -1 {
+		t.Errorf("RunShellWithTimeout(hung) = %+v; want TimedOut: true, Success: false, ExitCode: -1", res)
+	}
+	if elapsed >= killWaitDelay {
+		t.Errorf("RunShellWithTimeout(hung) took %v; process tree was not terminated", elapsed)
+	}
+}
+
+func TestRunExec_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("timeout test uses a POSIX shell")
+	}
+	context := make(map[string]interface{})
+	e := &playbook.Exec{
+		Script:  "echo partial; sleep 30",
+		Shell:   "sh",
+		Timeout: "200ms",
+		Gather:  []playbook.GatherSpec{{Key: "out", Regex: "(.*)"}},
+	}
+	res, err := RunExec(e, context, nil)
+	if err != nil {
+		t.Fatalf("RunExec(timeout) error: %v", err)
+	}
+	if !res.TimedOut {
+		t.Errorf("RunExec(timeout) = %+v; want TimedOut: true", res)
+	}
+	if _, exists := context["out"]; exists {
+		t.Errorf("RunExec(timeout) should not gather from partial output")
+	}
+}
@@ -6,6 +6,58 @@ import (
 	"github.com/benedictjohannes/crobe/playbook"
 )
 
+// Verdict is the outcome of an assertion.
+type Verdict string
+
+const (
+	// VerdictPass means the assertion ran and met its minimum passing score.
+	VerdictPass Verdict = "pass"
+	// VerdictFail means the assertion ran and the control did not hold.
+	VerdictFail Verdict = "fail"
+	// VerdictError means the probe itself broke (JS error, missing binary, rule error...),
+	// so the control could not be evaluated.
+	VerdictError Verdict = "error"
+	// VerdictSkipped means the assertion was deliberately not run.
+	VerdictSkipped Verdict = "skipped"
+	// VerdictNotApplicable means the assertion does not apply to this system.
+	VerdictNotApplicable Verdict = "not_applicable"
+	// VerdictWaived means the assertion failed, but an active waiver accepts the failure.
+	VerdictWaived Verdict = "waived"
+)
+
+// RemediationMode controls whether remediations of failed assertions are run.
+type RemediationMode string
+
+const (
+	// RemediateOff never runs remediations. It is the default.
+	RemediateOff RemediationMode = ""
+	// RemediateDryRun resolves the remediation of failed assertions without running it.
+	RemediateDryRun RemediationMode = "dry-run"
+	// RemediateApply runs the remediation of failed assertions, then re-runs their cmds.
+	RemediateApply RemediationMode = "apply"
+)
+
+// RemediationTrace records the remediation of a failed assertion. The assertion's own
+// verdict, score, logs and outputs hold the result after remediation.
+type RemediationTrace struct {
+	Mode RemediationMode
+	// Script is the resolved script the remediation runs (or would run, in dry-run).
+	Script        string
+	Log           *CommandLog
+	Error         string
+	VerdictBefore Verdict
+	ScoreBefore   int
+	CmdLogsBefore []CommandLog
+	OutputsBefore []string
+}
+
+// WaiverTrace records the waiver matching a failed assertion. An expired waiver leaves the
+// assertion failed.
+type WaiverTrace struct {
+	Waiver  playbook.Waiver
+	Expired bool
+}
+
 type CommandLog struct {
 	Exec   playbook.Exec
 	Result ExecutionResult
@@ -18,7 +70,9 @@ type AssertionContext struct {
 		Start time.Time
 		End   time.Time
 	}
-	Passed      bool
+	Verdict     Verdict
+	Reason      string
+	Errors      []string
 	Score       int
 	MinScore    int
 	Context     map[string]interface{}
@@ -26,6 +80,8 @@ type AssertionContext struct {
 	CmdLogs     []CommandLog
 	PostCmdLogs []CommandLog
 	Outputs     []string
+	Remediation *RemediationTrace
+	Waiver      *WaiverTrace
 }
 
 type SectionContext struct {
@@ -40,9 +96,17 @@ type ExecutionTrace struct {
 		Start time.Time
 		End   time.Time
 	}
-	Username    string
-	OS          string
-	Arch        string
-	TotalPassed int
-	TotalFailed int
+	Username           string
+	Hostname           string
+	OS                 string
+	Arch               string
+	TotalPassed        int
+	TotalFailed        int
+	TotalErrored       int
+	TotalSkipped       int
+	TotalNotApplicable int
+	TotalWaived        int
+	// Selection is the assertion filter the run was restricted to, if any.
+	Selection playbook.Selector
+	Remediate RemediationMode
 }
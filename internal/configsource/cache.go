@@ -0,0 +1,146 @@
+package configsource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// RemoteCache, when set, keeps the last good copy of every remote file (playbooks, libraries,
+// signatures, waivers) on disk. Fetches then revalidate the copy with conditional requests,
+// and fall back to it when the server cannot be reached. Nil disables caching.
+var RemoteCache *Cache
+
+// DefaultCacheMaxAge is how old a cached copy may be and still be used offline.
+const DefaultCacheMaxAge = 7 * 24 * time.Hour
+
+// Cache stores remote files by URL. Fetched files are only written to disk by Commit, once
+// the caller has validated them, so a broken playbook never replaces the last good copy.
+type Cache struct {
+	dir    string
+	maxAge time.Duration
+	// pending holds the files fetched or revalidated since the last Commit.
+	pending map[string]cacheEntry
+	// fallbacks lists the cached copies used because their server could not be reached.
+	fallbacks []playbook.CachedCopy
+}
+
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	body         []byte
+}
+
+// NewCache returns a cache in dir whose copies are used offline up to maxAge old.
+func NewCache(dir string, maxAge time.Duration) *Cache {
+	return &Cache{dir: dir, maxAge: maxAge, pending: make(map[string]cacheEntry)}
+}
+
+// DefaultCacheDir returns the crobe folder of the user's cache directory, or "" if the
+// user has none.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "crobe")
+}
+
+func (c *Cache) paths(url string) (body string, meta string) {
+	sum := sha256.Sum256([]byte(url))
+	name := filepath.Join(c.dir, "remote", hex.EncodeToString(sum[:]))
+	return name + ".body", name + ".json"
+}
+
+// lookup returns the cached copy of url, if any.
+func (c *Cache) lookup(url string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+	if entry, ok := c.pending[url]; ok {
+		return &entry
+	}
+	bodyPath, metaPath := c.paths(url)
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.URL != url {
+		return nil
+	}
+	if entry.body, err = os.ReadFile(bodyPath); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// store records a file fetched (or revalidated) from its server, to be saved by Commit.
+func (c *Cache) store(entry cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.pending[entry.URL] = entry
+}
+
+// fallback returns the cached copy of url in place of a failed fetch, unless there is none
+// or it is older than the maximum age.
+func (c *Cache) fallback(url string, entry *cacheEntry, fetchErr error) ([]byte, string, error) {
+	if c == nil || entry == nil {
+		return nil, "", fetchErr
+	}
+	if age := time.Since(entry.FetchedAt); age > c.maxAge {
+		return nil, "", fmt.Errorf("%w (the cached copy is %s old, older than the maximum age of %s)", fetchErr, age.Round(time.Second), c.maxAge)
+	}
+	c.fallbacks = append(c.fallbacks, playbook.CachedCopy{Location: url, FetchedAt: entry.FetchedAt})
+	return entry.body, entry.ContentType, nil
+}
+
+// fallbacksSince returns the cached copies used after the first n fallbacks.
+func (c *Cache) fallbacksSince(n int) []playbook.CachedCopy {
+	if c == nil || len(c.fallbacks) <= n {
+		return nil
+	}
+	return append([]playbook.CachedCopy(nil), c.fallbacks[n:]...)
+}
+
+func (c *Cache) fallbackCount() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.fallbacks)
+}
+
+// Commit saves the files fetched since the last Commit. Call it once they are validated.
+func (c *Cache) Commit() error {
+	if c == nil || len(c.pending) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(c.dir, "remote"), 0700); err != nil {
+		return err
+	}
+	for url, entry := range c.pending {
+		bodyPath, metaPath := c.paths(url)
+		meta, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(bodyPath, entry.body, 0600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(metaPath, meta, 0600); err != nil {
+			return err
+		}
+		delete(c.pending, url)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package configsource
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+func TestLoadConfig_RemoteCache(t *testing.T) {
+	content := "title: Cached v1\nsections: []\n"
+	etag := `"v1"`
+	var conditional []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	oldTLSConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer func() { transport.TLSClientConfig = oldTLSConfig }()
+
+	dir := t.TempDir()
+	RemoteCache = NewCache(dir, time.Hour)
+	defer func() { RemoteCache = nil }()
+	url := server.URL + "/playbook.yaml"
+
+	// First fetch: nothing cached until committed
+	if _, _, err := LoadConfig(url, nil); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := RemoteCache.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// Revalidation with a conditional request
+	RemoteCache = NewCache(dir, time.Hour)
+	config, _, err := LoadConfig(url, nil)
+	if err != nil || config.Title != "Cached v1" {
+		t.Fatalf("LoadConfig() on 304 = %+v, %v", config, err)
+	}
+	if conditional[1] != etag {
+		t.Errorf("expected If-None-Match %s on revalidation, got %q", etag, conditional[1])
+	}
+	if len(config.CachedCopies) != 0 {
+		t.Errorf("a revalidated copy is not an offline fallback: %+v", config.CachedCopies)
+	}
+
+	// A new version that is never validated must not replace the cached copy
+	content, etag = "title: Broken v2\nsections: []\n", `"v2"`
+	RemoteCache = NewCache(dir, time.Hour)
+	if config, _, _ := LoadConfig(url, nil); config.Title != "Broken v2" {
+		t.Fatalf("expected the new version from the server, got %q", config.Title)
+	}
+
+	// Offline: the last committed copy is used and recorded
+	server.Close()
+	RemoteCache = NewCache(dir, time.Hour)
+	config, _, err = LoadConfig(url, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig() offline error = %v", err)
+	}
+	if config.Title != "Cached v1" {
+		t.Errorf("expected the last validated copy offline, got %q", config.Title)
+	}
+	if len(config.CachedCopies) != 1 || config.CachedCopies[0].Location != url || time.Since(config.CachedCopies[0].FetchedAt) > time.Minute {
+		t.Errorf("unexpected cached copies: %+v", config.CachedCopies)
+	}
+
+	// Offline with a copy older than the maximum age
+	RemoteCache = NewCache(dir, time.Nanosecond)
+	if _, _, err := LoadConfig(url, nil); err == nil || !strings.Contains(err.Error(), "older than the maximum age of 1ns") {
+		t.Errorf("expected a stale cache error, got %v", err)
+	}
+
+	// Offline without a cache
+	RemoteCache = nil
+	if _, _, err := LoadConfig(url, nil); err == nil || !strings.Contains(err.Error(), "failed to fetch remote playbook") {
+		t.Errorf("expected a fetch error without cache, got %v", err)
+	}
+}
+
+func TestLoadConfig_Nonce(t *testing.T) {
+	content := "title: Nonce\nreportDestination: https\nreportDestinationHttps:\n  url: https://hub.example.com\nsections: []\n"
+	served := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Header().Set(submission.NonceHeader, fmt.Sprintf("nonce-%d", served))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	oldTLSConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer func() { transport.TLSClientConfig = oldTLSConfig }()
+
+	dir := t.TempDir()
+	RemoteCache = NewCache(dir, time.Hour)
+	defer func() { RemoteCache = nil }()
+	url := server.URL + "/playbook.yaml"
+
+	config, _, err := LoadConfig(url, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := config.ReportDestinationHTTPS.Nonce; got != "nonce-1" {
+		t.Errorf("expected nonce-1, got %q", got)
+	}
+	RemoteCache.Commit()
+
+	// A revalidated copy comes with a fresh nonce
+	config, _, _ = LoadConfig(url, nil)
+	if got := config.ReportDestinationHTTPS.Nonce; got != "nonce-2" {
+		t.Errorf("expected nonce-2 on revalidation, got %q", got)
+	}
+
+	// Offline, the cached copy has no nonce
+	server.Close()
+	config, _, err = LoadConfig(url, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig() offline error = %v", err)
+	}
+	if got := config.ReportDestinationHTTPS.Nonce; got != "" {
+		t.Errorf("expected no nonce offline, got %q", got)
+	}
+}
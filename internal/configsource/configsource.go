@@ -9,53 +9,101 @@ import (
 	"strings"
 	"time"
 
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
 	"github.com/benedictjohannes/crobe/playbook"
+	"github.com/benedictjohannes/crobe/submission"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig loads the playbook from either a local file or an HTTPS URL.
-func LoadConfig(path string, headers map[string]string) (*playbook.Playbook, []byte, error) {
-	var data []byte
-	var contentType string
-	var err error
+// TLS configures the connections used to fetch remote files (CA bundle, client certificate,
+// pinning).
+var TLS tlsconfig.Options
+
+// nonces holds the submission nonce sent by the server with each remote file, by URL. Files
+// loaded from the cache when their server could not be reached have none.
+var nonces = make(map[string]string)
 
+// LoadConfig loads the playbook from either a local file or an HTTPS URL, and resolves its
+// include entries. The returned bytes are the content of the playbook file itself. Remote
+// files loaded from the RemoteCache are listed in the playbook's CachedCopies, and the
+// submission nonce sent with a remote playbook is set in its reportDestinationHttps.
+func LoadConfig(path string, headers map[string]string) (*playbook.Playbook, []byte, error) {
 	if strings.HasPrefix(path, "http://") {
 		return nil, nil, fmt.Errorf("insecure HTTP connections are not allowed: %s", path)
 	}
-	isHttps := strings.HasPrefix(path, "https://")
-	if isHttps {
-		data, contentType, err = fetchHttpsPlaybook(path, headers)
-	} else {
-		data, err = os.ReadFile(path)
-	}
 
+	fallbacks := RemoteCache.fallbackCount()
+	var config playbook.Playbook
+	data, err := loadDocument(path, headers, &config)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	var config playbook.Playbook
+	r := &includeResolver{headers: headers, origin: originOf(path), stack: []string{locationKey(path)}}
+	if config.Sections, err = r.resolveSections(config.Sections, path); err != nil {
+		return nil, nil, err
+	}
+
+	config.CachedCopies = RemoteCache.fallbacksSince(fallbacks)
+	setNonce(&config, path)
+	return &config, data, nil
+}
+
+// setNonce passes the submission nonce sent with the playbook at location on to its report
+// destination.
+func setNonce(config *playbook.Playbook, location string) {
+	if config.ReportDestinationHTTPS != nil {
+		config.ReportDestinationHTTPS.Nonce = nonces[location]
+	}
+}
+
+// loadDocument reads a playbook or library from a local file or an HTTPS URL into out,
+// as JSON (by extension or content type) or YAML.
+func loadDocument(location string, headers map[string]string, out interface{}) ([]byte, error) {
+	data, contentType, err := readDocument(location, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseDocument(data, location, contentType, out); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readDocument returns the content of a local file or an HTTPS URL, with the content type
+// of the response (empty for local files).
+func readDocument(location string, headers map[string]string) ([]byte, string, error) {
+	if strings.HasPrefix(location, "https://") {
+		return fetchHttpsPlaybook(location, headers)
+	}
+	data, err := os.ReadFile(location)
+	return data, "", err
+}
+
+func parseDocument(data []byte, location string, contentType string, out interface{}) error {
+	isHttps := strings.HasPrefix(location, "https://")
 	isJson := strings.HasPrefix(strings.ToLower(contentType), "application/json") ||
-		strings.HasSuffix(strings.ToLower(path), ".json") && !isHttps
+		strings.HasSuffix(strings.ToLower(location), ".json") && !isHttps
 
 	if isJson {
-		err = json.Unmarshal(data, &config)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse JSON: %w", err)
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
 		}
 	} else {
-		err = yaml.Unmarshal(data, &config)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
+		if err := yaml.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse YAML: %w", err)
 		}
 	}
-
-	return &config, data, nil
+	return nil
 }
 
+// fetchHttpsPlaybook fetches a remote file. With a RemoteCache, the cached copy is
+// revalidated with a conditional request, and used instead when the server cannot be reached.
 func fetchHttpsPlaybook(url string, headers map[string]string) ([]byte, string, error) {
-	client := &http.Client{
-		Timeout: 60 * time.Second,
+	client, err := tlsconfig.NewClient(TLS, 60*time.Second)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to configure TLS: %w", err)
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -66,21 +114,59 @@ func fetchHttpsPlaybook(url string, headers map[string]string) ([]byte, string,
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
+	delete(nonces, url)
+
+	cached := RemoteCache.lookup(url)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to fetch remote playbook: %w", err)
+		return RemoteCache.fallback(url, cached, fmt.Errorf("failed to fetch remote playbook: %w", err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		recordNonce(url, resp)
+		cached.FetchedAt = time.Now()
+		RemoteCache.store(*cached)
+		return cached.body, cached.ContentType, nil
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return RemoteCache.fallback(url, cached, fmt.Errorf("failed to fetch remote playbook: status %d", resp.StatusCode))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, "", fmt.Errorf("failed to fetch remote playbook: status %d", resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, "", err
+		return RemoteCache.fallback(url, cached, err)
 	}
 
-	return data, resp.Header.Get("Content-Type"), nil
+	recordNonce(url, resp)
+	contentType := resp.Header.Get("Content-Type")
+	RemoteCache.store(cacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  contentType,
+		FetchedAt:    time.Now(),
+		body:         data,
+	})
+	return data, contentType, nil
+}
+
+// recordNonce keeps the submission nonce of a response served for url, if any. Nonces are
+// single use, so they are never cached.
+func recordNonce(url string, resp *http.Response) {
+	if nonce := resp.Header.Get(submission.NonceHeader); nonce != "" {
+		nonces[url] = nonce
+	}
 }
@@ -1,12 +1,18 @@
 package configsource
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
 )
 
 func TestLoadConfig_Insecure(t *testing.T) {
@@ -289,6 +295,34 @@ func TestLoadConfig_HttpsTls(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_HttpsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("title: Pinned Playbook\nsections: []\n"))
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	defer func() { TLS = tlsconfig.Options{} }()
+
+	TLS = tlsconfig.Options{CABundle: caPath, Pins: []string{base64.StdEncoding.EncodeToString(hash[:])}}
+	config, _, err := LoadConfig(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Expected the CA bundle to be trusted, got %v", err)
+	}
+	if config.Title != "Pinned Playbook" {
+		t.Errorf("Expected Pinned Playbook, got %s", config.Title)
+	}
+
+	TLS = tlsconfig.Options{CABundle: caPath, Pins: []string{base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))}}
+	if _, _, err := LoadConfig(server.URL, nil); err == nil || !strings.Contains(err.Error(), "pinned public key") {
+		t.Errorf("Expected a pinning error, got %v", err)
+	}
+}
+
 func TestFetchHttpsPlaybook_ReadError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set content length but don't write enough data
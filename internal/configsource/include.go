@@ -0,0 +1,232 @@
+package configsource
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// includeResolver replaces include entries with the content of library files, recursively.
+type includeResolver struct {
+	// headers are only sent to includes from the same origin as the playbook.
+	headers map[string]string
+	// origin is the scheme and host of a remote playbook, empty for a local one.
+	origin string
+	// stack holds the files being resolved, from the playbook down, to detect include cycles.
+	stack []string
+}
+
+func (r *includeResolver) resolveSections(sections []playbook.Section, from string) ([]playbook.Section, error) {
+	resolved := make([]playbook.Section, 0, len(sections))
+	for _, section := range sections {
+		if section.Include == "" {
+			assertions, err := r.resolveAssertions(section.Assertions, from)
+			if err != nil {
+				return nil, err
+			}
+			section.Assertions = assertions
+			resolved = append(resolved, section)
+			continue
+		}
+		if section.Title != "" || len(section.Description) > 0 || len(section.Assertions) > 0 {
+			return nil, fmt.Errorf("section include of %s in %s must not define anything else", section.Include, from)
+		}
+
+		lib, location, err := r.load(section.Include, from)
+		if err != nil {
+			return nil, err
+		}
+		if len(lib.Sections) == 0 {
+			return nil, fmt.Errorf("included file %s has no sections", location)
+		}
+		included, err := r.resolveSections(lib.Sections, location)
+		r.pop()
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, included...)
+	}
+	return resolved, nil
+}
+
+func (r *includeResolver) resolveAssertions(assertions []playbook.Assertion, from string) ([]playbook.Assertion, error) {
+	resolved := make([]playbook.Assertion, 0, len(assertions))
+	for _, assertion := range assertions {
+		if assertion.Include == "" {
+			resolved = append(resolved, assertion)
+			continue
+		}
+		if assertion.Code != "" || assertion.Title != "" || len(assertion.Cmds) > 0 {
+			return nil, fmt.Errorf("assertion include of %s in %s must not define anything else", assertion.Include, from)
+		}
+
+		lib, location, err := r.load(assertion.Include, from)
+		if err != nil {
+			return nil, err
+		}
+		if len(lib.Assertions) == 0 {
+			return nil, fmt.Errorf("included file %s has no assertions", location)
+		}
+		included, err := r.resolveAssertions(lib.Assertions, location)
+		r.pop()
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, included...)
+	}
+	return resolved, nil
+}
+
+// load reads the library referenced by ref from the file from, and pushes it on the stack.
+// Callers pop it once its own includes are resolved.
+func (r *includeResolver) load(ref string, from string) (playbook.Library, string, error) {
+	var lib playbook.Library
+	location, err := resolveLocation(ref, from)
+	if err != nil {
+		return lib, "", err
+	}
+
+	key := locationKey(location)
+	for i, k := range r.stack {
+		if k == key {
+			cycle := append(append([]string{}, r.stack[i:]...), key)
+			return lib, "", fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	var headers map[string]string
+	if r.origin != "" && originOf(location) == r.origin {
+		headers = r.headers
+	}
+	if _, err := loadDocument(location, headers, &lib); err != nil {
+		return lib, "", fmt.Errorf("failed to include %s: %w", location, err)
+	}
+	if err := rebaseFiles(&lib, location); err != nil {
+		return lib, "", err
+	}
+	r.stack = append(r.stack, key)
+	return lib, location, nil
+}
+
+func (r *includeResolver) pop() {
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// resolveLocation resolves ref relative to the file from. Local files may include local files
+// and HTTPS URLs; remote files may only include HTTPS URLs.
+func resolveLocation(ref string, from string) (string, error) {
+	if strings.HasPrefix(ref, "http://") {
+		return "", fmt.Errorf("insecure HTTP connections are not allowed: %s", ref)
+	}
+	if strings.HasPrefix(ref, "https://") {
+		return ref, nil
+	}
+	if strings.HasPrefix(from, "https://") {
+		if filepath.IsAbs(ref) || filepath.VolumeName(ref) != "" {
+			return "", fmt.Errorf("remote file %s cannot include local file %s", from, ref)
+		}
+		base, err := url.Parse(from)
+		if err != nil {
+			return "", err
+		}
+		rel, err := url.Parse(filepath.ToSlash(ref))
+		if err != nil {
+			return "", fmt.Errorf("invalid include %s in %s: %v", ref, from, err)
+		}
+		return base.ResolveReference(rel).String(), nil
+	}
+	if filepath.IsAbs(ref) {
+		return filepath.Clean(ref), nil
+	}
+	return filepath.Join(filepath.Dir(from), ref), nil
+}
+
+// originOf returns the scheme and host of an HTTPS location, or "" for a local file.
+func originOf(location string) string {
+	if !strings.HasPrefix(location, "https://") {
+		return ""
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host)
+}
+
+// locationKey identifies a file for cycle detection, however the path to it was spelled.
+func locationKey(location string) string {
+	if strings.HasPrefix(location, "https://") {
+		return location
+	}
+	if abs, err := filepath.Abs(location); err == nil {
+		return abs
+	}
+	return filepath.Clean(location)
+}
+
+// rebaseFiles prepares the content of a library for the including playbook: its assertions
+// record where they came from, and relative JS/TS file paths (which the builder resolves
+// against the playbook) are made absolute so they keep pointing next to the library.
+// Libraries fetched over HTTPS cannot reference such files.
+func rebaseFiles(lib *playbook.Library, location string) error {
+	isRemote := strings.HasPrefix(location, "https://")
+	var err error
+	rebase := func(file *string) {
+		if *file == "" || err != nil {
+			return
+		}
+		if isRemote {
+			err = fmt.Errorf("included file %s references local file %s; bake it before publishing", location, *file)
+			return
+		}
+		if !filepath.IsAbs(*file) {
+			*file = locationKey(filepath.Join(filepath.Dir(location), *file))
+		}
+	}
+
+	rebaseAssertion := func(a *playbook.Assertion) {
+		if a.Source == "" {
+			a.Source = location
+		}
+		forEachFile(a, rebase)
+	}
+	for i := range lib.Sections {
+		rebase(&lib.Sections[i].WhenFile)
+		for j := range lib.Sections[i].Assertions {
+			rebaseAssertion(&lib.Sections[i].Assertions[j])
+		}
+	}
+	for i := range lib.Assertions {
+		rebaseAssertion(&lib.Assertions[i])
+	}
+	return err
+}
+
+// forEachFile calls fn with every JS/TS file path of an assertion.
+func forEachFile(a *playbook.Assertion, fn func(*string)) {
+	execFiles := func(e *playbook.Exec) {
+		fn(&e.FuncFile)
+		fn(&e.ShellFuncFile)
+		for i := range e.Gather {
+			fn(&e.Gather[i].FuncFile)
+		}
+	}
+	fn(&a.WhenFile)
+	for i := range a.PreCmds {
+		execFiles(&a.PreCmds[i])
+	}
+	for i := range a.Cmds {
+		execFiles(&a.Cmds[i].Exec)
+		fn(&a.Cmds[i].StdOutRule.FuncFile)
+		fn(&a.Cmds[i].StdErrRule.FuncFile)
+	}
+	for i := range a.PostCmds {
+		execFiles(&a.PostCmds[i])
+	}
+	if a.Remediation != nil {
+		execFiles(&a.Remediation.Exec)
+	}
+}
@@ -0,0 +1,233 @@
+package configsource
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadConfig_Includes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"playbook.yaml": `
+title: Team Playbook
+sections:
+  - include: libs/os-integrity.yaml
+  - title: Team
+    description: [Team checks]
+    assertions:
+      - code: TEAM_01
+        title: Team check
+        cmds: [{exec: {script: echo team}}]
+      - include: libs/ssh.yaml
+`,
+		"libs/os-integrity.yaml": `
+sections:
+  - title: OS Integrity
+    description: [Shared OS checks]
+    assertions:
+      - code: OS_KERNEL
+        title: Kernel
+        cmds: [{exec: {funcFile: scripts/kernel.ts}}]
+      - include: common/shell.yaml
+`,
+		"libs/common/shell.yaml": `
+assertions:
+  - code: OS_SHELL
+    title: Shell
+    cmds: [{exec: {script: echo $SHELL}}]
+`,
+		"libs/ssh.yaml": `
+assertions:
+  - code: SSH_ROOT
+    title: Root login
+    cmds: [{exec: {script: echo no}}]
+`,
+	})
+
+	config, _, err := LoadConfig(filepath.Join(dir, "playbook.yaml"), nil)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if len(config.Sections) != 2 || config.Sections[0].Title != "OS Integrity" || config.Sections[1].Title != "Team" {
+		t.Fatalf("unexpected sections: %+v", config.Sections)
+	}
+	var codes []string
+	for _, s := range config.Sections {
+		for _, a := range s.Assertions {
+			codes = append(codes, a.Code)
+		}
+	}
+	if strings.Join(codes, ",") != "OS_KERNEL,OS_SHELL,TEAM_01,SSH_ROOT" {
+		t.Errorf("unexpected assertion order: %v", codes)
+	}
+
+	kernel := config.Sections[0].Assertions[0]
+	wantFile := filepath.Join(dir, "libs", "scripts", "kernel.ts")
+	if abs, _ := filepath.Abs(wantFile); kernel.Cmds[0].Exec.FuncFile != abs {
+		t.Errorf("funcFile = %q, want it relative to the library: %q", kernel.Cmds[0].Exec.FuncFile, abs)
+	}
+	if !strings.HasSuffix(kernel.Source, filepath.Join("libs", "os-integrity.yaml")) {
+		t.Errorf("expected source of included assertion, got %q", kernel.Source)
+	}
+	if shell := config.Sections[0].Assertions[1]; !strings.HasSuffix(shell.Source, filepath.Join("common", "shell.yaml")) {
+		t.Errorf("expected innermost source of nested include, got %q", shell.Source)
+	}
+	if team := config.Sections[1].Assertions[0]; team.Source != "" {
+		t.Errorf("playbook's own assertions have no source, got %q", team.Source)
+	}
+}
+
+func TestLoadConfig_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "Cycle",
+			files: map[string]string{
+				"playbook.yaml": "title: T\nsections:\n  - include: a.yaml\n",
+				"a.yaml":        "sections:\n  - include: b.yaml\n",
+				"b.yaml":        "sections:\n  - include: ./a.yaml\n",
+			},
+			want: "include cycle: ",
+		},
+		{
+			name:  "Missing file",
+			files: map[string]string{"playbook.yaml": "title: T\nsections:\n  - include: missing.yaml\n"},
+			want:  "failed to include",
+		},
+		{
+			name: "Library without sections",
+			files: map[string]string{
+				"playbook.yaml": "title: T\nsections:\n  - include: lib.yaml\n",
+				"lib.yaml":      "assertions:\n  - code: A\n",
+			},
+			want: "has no sections",
+		},
+		{
+			name: "Library without assertions",
+			files: map[string]string{
+				"playbook.yaml": "title: T\nsections:\n  - title: S\n    assertions:\n      - include: lib.yaml\n",
+				"lib.yaml":      "sections: []\n",
+			},
+			want: "has no assertions",
+		},
+		{
+			name:  "Include entry with content",
+			files: map[string]string{"playbook.yaml": "title: T\nsections:\n  - title: S\n    include: lib.yaml\n"},
+			want:  "must not define anything else",
+		},
+		{
+			name:  "Insecure include",
+			files: map[string]string{"playbook.yaml": "title: T\nsections:\n  - include: http://example.com/lib.yaml\n"},
+			want:  "insecure HTTP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			_, _, err := LoadConfig(filepath.Join(dir, "playbook.yaml"), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_RemoteIncludes(t *testing.T) {
+	files := map[string]string{
+		"/playbooks/main.yaml":  "title: Remote\nsections:\n  - include: ../libs/os.yaml\n",
+		"/libs/os.yaml":         "sections:\n  - title: OS\n    assertions:\n      - include: shell.yaml\n",
+		"/libs/shell.yaml":      "assertions:\n  - code: OS_SHELL\n    title: Shell\n",
+		"/playbooks/local.yaml": "title: Remote\nsections:\n  - include: /etc/passwd\n",
+		"/playbooks/func.yaml":  "title: Remote\nsections:\n  - title: S\n    assertions:\n      - include: ../libs/func.yaml\n",
+		"/libs/func.yaml":       "assertions:\n  - code: F\n    cmds: [{exec: {funcFile: f.ts}}]\n",
+	}
+	var authHeaders []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	oldTLSConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer func() { transport.TLSClientConfig = oldTLSConfig }()
+
+	headers := map[string]string{"Authorization": "Bearer token"}
+	config, _, err := LoadConfig(server.URL+"/playbooks/main.yaml", headers)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Sections) != 1 || config.Sections[0].Assertions[0].Code != "OS_SHELL" {
+		t.Errorf("unexpected sections: %+v", config.Sections)
+	}
+	if config.Sections[0].Assertions[0].Source != server.URL+"/libs/shell.yaml" {
+		t.Errorf("unexpected source: %q", config.Sections[0].Assertions[0].Source)
+	}
+	for _, h := range authHeaders {
+		if h != "Bearer token" {
+			t.Errorf("expected headers on every include request, got %q", h)
+		}
+	}
+
+	// Headers stay with the origin of the playbook
+	var otherHeaders []string
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHeaders = append(otherHeaders, r.Header.Get("Authorization"))
+		w.Write([]byte(files["/libs/shell.yaml"]))
+	}))
+	defer other.Close()
+	files["/playbooks/cross.yaml"] = "title: Remote\nsections:\n  - title: S\n    assertions:\n      - include: " + other.URL + "/libs/shell.yaml\n"
+	if _, _, err := LoadConfig(server.URL+"/playbooks/cross.yaml", headers); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(otherHeaders) != 1 || otherHeaders[0] != "" {
+		t.Errorf("expected no headers sent to another origin, got %q", otherHeaders)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.yaml": "title: Local\nsections:\n  - title: S\n    assertions:\n      - include: " + server.URL + "/libs/shell.yaml\n"})
+	authHeaders = nil
+	if _, _, err := LoadConfig(filepath.Join(dir, "main.yaml"), headers); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(authHeaders) != 1 || authHeaders[0] != "" {
+		t.Errorf("expected no headers sent to the includes of a local playbook, got %q", authHeaders)
+	}
+
+	if _, _, err := LoadConfig(server.URL+"/playbooks/local.yaml", nil); err == nil || !strings.Contains(err.Error(), "cannot include local file") {
+		t.Errorf("expected remote playbooks not to include local files, got %v", err)
+	}
+	if _, _, err := LoadConfig(server.URL+"/playbooks/func.yaml", nil); err == nil || !strings.Contains(err.Error(), "references local file f.ts") {
+		t.Errorf("expected remote libraries not to reference local files, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package configsource
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/internal/signing"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// LoadSignedConfig loads a baked playbook like LoadConfig, but only once its detached
+// signature verifies against publicKey. The signature is read from signaturePath, or from
+// the playbook's location with .sig appended, with the same headers.
+//
+// Include entries are refused: the included files would not be covered by the signature.
+func LoadSignedConfig(path string, signaturePath string, headers map[string]string, publicKey ed25519.PublicKey) (*playbook.Playbook, []byte, error) {
+	if signaturePath == "" {
+		signaturePath = signing.SignaturePath(path)
+	}
+	for _, location := range []string{path, signaturePath} {
+		if strings.HasPrefix(location, "http://") {
+			return nil, nil, fmt.Errorf("insecure HTTP connections are not allowed: %s", location)
+		}
+	}
+
+	fallbacks := RemoteCache.fallbackCount()
+	data, contentType, err := readDocument(path, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, _, err := readDocument(signaturePath, headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read signature %s: %w", signaturePath, err)
+	}
+	if err := signing.Verify(publicKey, data, signature); err != nil {
+		return nil, nil, err
+	}
+
+	var config playbook.Playbook
+	if err := parseDocument(data, path, contentType, &config); err != nil {
+		return nil, nil, err
+	}
+	for _, section := range config.Sections {
+		if section.Include != "" {
+			return nil, nil, fmt.Errorf("signed playbooks must be baked: include of %s is not covered by the signature", section.Include)
+		}
+		for _, assertion := range section.Assertions {
+			if assertion.Include != "" {
+				return nil, nil, fmt.Errorf("signed playbooks must be baked: include of %s is not covered by the signature", assertion.Include)
+			}
+		}
+	}
+	config.CachedCopies = RemoteCache.fallbacksSince(fallbacks)
+	setNonce(&config, path)
+	return &config, data, nil
+}
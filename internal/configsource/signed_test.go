@@ -0,0 +1,106 @@
+package configsource
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/internal/signing"
+)
+
+func TestLoadSignedConfig(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baked := "title: Baked\nsections:\n  - title: S1\n    assertions:\n      - code: A1\n"
+	withInclude := "title: Raw\nsections:\n  - include: libs/os.yaml\n"
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"baked.yaml":        baked,
+		"baked.yaml.sig":    string(signing.Sign(priv, []byte(baked))),
+		"tampered.yaml":     baked + "      - code: INJECTED\n",
+		"tampered.yaml.sig": string(signing.Sign(priv, []byte(baked))),
+		"unsigned.yaml":     baked,
+		"include.yaml":      withInclude,
+		"include.yaml.sig":  string(signing.Sign(priv, []byte(withInclude))),
+		"elsewhere.sig":     string(signing.Sign(priv, []byte(baked))),
+	})
+
+	config, data, err := LoadSignedConfig(filepath.Join(dir, "baked.yaml"), "", nil, pub)
+	if err != nil {
+		t.Fatalf("LoadSignedConfig() error = %v", err)
+	}
+	if config.Title != "Baked" || string(data) != baked {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if _, _, err := LoadSignedConfig(filepath.Join(dir, "unsigned.yaml"), filepath.Join(dir, "elsewhere.sig"), nil, pub); err != nil {
+		t.Errorf("expected an explicit signature path to be used, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		file, sig string
+		key       ed25519.PublicKey
+		want      string
+	}{
+		{"Tampered", "tampered.yaml", "", pub, "does not match the pinned public key"},
+		{"Missing signature", "unsigned.yaml", "", pub, "failed to read signature"},
+		{"Includes", "include.yaml", "", pub, "signed playbooks must be baked"},
+		{"Insecure signature", "baked.yaml", "http://example.com/baked.yaml.sig", pub, "insecure HTTP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadSignedConfig(filepath.Join(dir, tt.file), tt.sig, nil, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadSignedConfig() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSignedConfig_Https(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baked := "title: Remote Baked\nsections: []\n"
+	files := map[string]string{
+		"/playbooks/main.yaml":     baked,
+		"/playbooks/main.yaml.sig": string(signing.Sign(priv, []byte(baked))),
+	}
+	var authHeaders []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	oldTLSConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer func() { transport.TLSClientConfig = oldTLSConfig }()
+
+	headers := map[string]string{"Authorization": "Bearer token"}
+	config, _, err := LoadSignedConfig(server.URL+"/playbooks/main.yaml?team=ops", "", headers, pub)
+	if err != nil {
+		t.Fatalf("LoadSignedConfig() error = %v", err)
+	}
+	if config.Title != "Remote Baked" {
+		t.Errorf("unexpected title %q", config.Title)
+	}
+	if len(authHeaders) != 2 || authHeaders[0] != "Bearer token" || authHeaders[1] != "Bearer token" {
+		t.Errorf("expected headers on the playbook and signature requests, got %v", authHeaders)
+	}
+}
@@ -0,0 +1,43 @@
+package configsource
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// LoadWaivers loads and validates a waiver file from either a local file or an HTTPS URL.
+func LoadWaivers(path string, headers map[string]string) ([]playbook.Waiver, error) {
+	if strings.HasPrefix(path, "http://") {
+		return nil, fmt.Errorf("insecure HTTP connections are not allowed: %s", path)
+	}
+	var file playbook.WaiverFile
+	if _, err := loadDocument(path, headers, &file); err != nil {
+		return nil, err
+	}
+	if err := playbook.ValidateWaivers(file.Waivers); err != nil {
+		return nil, err
+	}
+	return file.Waivers, nil
+}
+
+// LoadRunWaivers loads the waiver file given for a run, if any, and warns about the waivers
+// that have expired: the failures they covered count again.
+func LoadRunWaivers(path string, headers map[string]string) ([]playbook.Waiver, error) {
+	if path == "" {
+		return nil, nil
+	}
+	waivers, err := LoadWaivers(path, headers)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, w := range waivers {
+		if w.Expired(now) {
+			fmt.Printf("🚨 Expired %s on %s: the failures it covered count again\n", w.Describe(), w.Expires)
+		}
+	}
+	return waivers, nil
+}
@@ -0,0 +1,72 @@
+package configsource
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWaivers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"waivers.yaml": `
+waivers:
+  - codes: [DISK_ENCRYPTION]
+    hosts: ["kiosk-*"]
+    justification: Kiosks hold no user data
+    approver: CISO
+    expires: "2026-12-31"
+`,
+		"waivers.json": `{"waivers": [{"codes": ["SSH_*"], "justification": "Migration", "approver": "Ops", "expires": "2026-06-30"}]}`,
+		"invalid.yaml": `
+waivers:
+  - codes: [DISK_ENCRYPTION]
+    approver: CISO
+    expires: "2026-12-31"
+`,
+	})
+
+	waivers, err := LoadWaivers(filepath.Join(dir, "waivers.yaml"), nil)
+	if err != nil {
+		t.Fatalf("LoadWaivers() error = %v", err)
+	}
+	if len(waivers) != 1 || waivers[0].Hosts[0] != "kiosk-*" || waivers[0].Expires != "2026-12-31" {
+		t.Errorf("unexpected waivers: %+v", waivers)
+	}
+
+	waivers, err = LoadWaivers(filepath.Join(dir, "waivers.json"), nil)
+	if err != nil || len(waivers) != 1 || waivers[0].Codes[0] != "SSH_*" {
+		t.Errorf("LoadWaivers(json) = %+v, %v", waivers, err)
+	}
+
+	if _, err := LoadWaivers(filepath.Join(dir, "invalid.yaml"), nil); err == nil || !strings.Contains(err.Error(), "missing a justification") {
+		t.Errorf("expected validation error, got %v", err)
+	}
+	if _, err := LoadWaivers("http://example.com/waivers.yaml", nil); err == nil || !strings.Contains(err.Error(), "insecure") {
+		t.Errorf("expected insecure HTTP error, got %v", err)
+	}
+}
+
+func TestLoadRunWaivers(t *testing.T) {
+	if waivers, err := LoadRunWaivers("", nil); err != nil || waivers != nil {
+		t.Errorf("expected no waivers without a file, got %v, %v", waivers, err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"expired.yaml": `
+waivers:
+  - codes: [SSH_ROOT]
+    justification: Legacy host
+    approver: CISO
+    expires: "2020-01-01"
+`,
+	})
+	// Expired waivers are still returned, so that the report can flag them
+	waivers, err := LoadRunWaivers(filepath.Join(dir, "expired.yaml"), nil)
+	if err != nil || len(waivers) != 1 {
+		t.Errorf("expected the expired waiver, got %v, %v", waivers, err)
+	}
+	if _, err := LoadRunWaivers(filepath.Join(dir, "none.yaml"), nil); err == nil {
+		t.Error("expected an error for a missing waiver file")
+	}
+}
@@ -0,0 +1,105 @@
+// Package encryption encrypts report files to the public keys of their readers, in the age
+// format (https://age-encryption.org), and decrypts them with the matching identities.
+package encryption
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Ext is appended to the name of encrypted report files.
+const Ext = ".age"
+
+// ContentType is the media type of encrypted report files.
+const ContentType = "application/age"
+
+// ParseRecipients parses age X25519 public keys ("age1..."). Empty entries are ignored.
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		r, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report recipient %q: %w", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// SelectRecipients parses the recipients given on the command line, which replace those
+// configured in the playbook, or else the configured ones.
+func SelectRecipients(override []string, configured []string) ([]age.Recipient, error) {
+	if len(override) > 0 {
+		return ParseRecipients(override)
+	}
+	return ParseRecipients(configured)
+}
+
+// Encrypt encrypts data so that any of the recipients can decrypt it.
+func Encrypt(data []byte, recipients []age.Recipient) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted to one of the identities.
+func Decrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// LoadIdentities reads an age identity file, as written by `age-keygen` or GenerateIdentity:
+// one AGE-SECRET-KEY-1... per line, with # comments.
+func LoadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// GenerateIdentity writes a new identity file, only readable by its owner, in the format of
+// `age-keygen`. It never replaces an existing file, and returns the public key to give to
+// the agents as a report recipient.
+func GenerateIdentity(path string) (string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	recipient := identity.Recipient().String()
+	if _, err := fmt.Fprintf(f, "# public key: %s\n%s\n", recipient, identity); err != nil {
+		f.Close()
+		return "", err
+	}
+	return recipient, f.Close()
+}
@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	hubKey := filepath.Join(dir, "hub.key")
+	recipient, err := GenerateIdentity(hubKey)
+	if err != nil {
+		t.Fatalf("GenerateIdentity() error = %v", err)
+	}
+	if info, _ := os.Stat(hubKey); info.Mode().Perm() != 0600 {
+		t.Errorf("expected a 0600 identity file, got %s", info.Mode().Perm())
+	}
+	if _, err := GenerateIdentity(hubKey); !os.IsExist(err) {
+		t.Errorf("expected an existing identity not to be replaced, got %v", err)
+	}
+	identities, err := LoadIdentities(hubKey)
+	if err != nil {
+		t.Fatalf("LoadIdentities() error = %v", err)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	recipients, err := ParseRecipients([]string{recipient, " ", other.Recipient().String()})
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("ParseRecipients() = %v, %v", recipients, err)
+	}
+
+	ciphertext, err := Encrypt([]byte("alice ran sudo"), recipients)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if strings.Contains(string(ciphertext), "alice") {
+		t.Errorf("expected the plaintext not to appear in the ciphertext")
+	}
+	for _, ids := range [][]age.Identity{identities, {other}} {
+		plaintext, err := Decrypt(ciphertext, ids)
+		if err != nil || string(plaintext) != "alice ran sudo" {
+			t.Errorf("Decrypt() = %q, %v", plaintext, err)
+		}
+	}
+
+	stranger, _ := age.GenerateX25519Identity()
+	if _, err := Decrypt(ciphertext, []age.Identity{stranger}); err == nil {
+		t.Errorf("expected another identity to fail")
+	}
+	if _, err := ParseRecipients([]string{"ssh-ed25519 AAAA"}); err == nil || !strings.Contains(err.Error(), "invalid report recipient") {
+		t.Errorf("expected an invalid recipient error, got %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "bad.key"), []byte("hello\n"), 0600)
+	if _, err := LoadIdentities(filepath.Join(dir, "bad.key")); err == nil {
+		t.Errorf("expected a malformed identity file error")
+	}
+}
+
+func TestSelectRecipients(t *testing.T) {
+	configured, _ := age.GenerateX25519Identity()
+	override, _ := age.GenerateX25519Identity()
+
+	recipients, err := SelectRecipients(nil, []string{configured.Recipient().String()})
+	if err != nil || len(recipients) != 1 || recipients[0].(*age.X25519Recipient).String() != configured.Recipient().String() {
+		t.Errorf("expected the configured recipient, got %v, %v", recipients, err)
+	}
+	recipients, err = SelectRecipients([]string{override.Recipient().String()}, []string{configured.Recipient().String()})
+	if err != nil || len(recipients) != 1 || recipients[0].(*age.X25519Recipient).String() != override.Recipient().String() {
+		t.Errorf("expected the override to replace the configured recipient, got %v, %v", recipients, err)
+	}
+	if _, err := SelectRecipients(nil, []string{"age1invalid"}); err == nil {
+		t.Errorf("expected an invalid configured recipient to be rejected")
+	}
+}
@@ -0,0 +1,22 @@
+package listflags
+
+import (
+	"strings"
+)
+
+// ListFlags implements flag.Value to collect a list from comma-separated values.
+// The flag can also be specified multiple times; empty items are dropped.
+type ListFlags []string
+
+func (l *ListFlags) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *ListFlags) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
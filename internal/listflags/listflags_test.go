@@ -0,0 +1,25 @@
+package listflags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListFlags_Set(t *testing.T) {
+	var l ListFlags
+
+	if err := l.Set("ssh, auth"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := l.Set("cis-*,,"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	expected := ListFlags{"ssh", "auth", "cis-*"}
+	if !reflect.DeepEqual(l, expected) {
+		t.Errorf("l = %v, want %v", l, expected)
+	}
+	if l.String() != "ssh,auth,cis-*" {
+		t.Errorf("String() = %q, want %q", l.String(), "ssh,auth,cis-*")
+	}
+}
@@ -0,0 +1,50 @@
+package reportwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+// Enroll registers a device key with a report server by posting the enrollment as JSON, and
+// returns the response status.
+func Enroll(url string, headers map[string]string, enrollment submission.Enrollment) (int, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return 0, fmt.Errorf("insecure HTTP enrollment is not allowed: %s", url)
+	}
+	body, err := json.Marshal(enrollment)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client, err := tlsconfig.NewClient(TLS, 60*time.Second)
+	if err != nil {
+		return 0, fmt.Errorf("failed to configure TLS: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enroll: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("failed to enroll: status %d. Response: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.StatusCode, nil
+}
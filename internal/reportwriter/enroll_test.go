@@ -0,0 +1,47 @@
+package reportwriter
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+func TestEnroll(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	var received submission.Enrollment
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer enroll-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	enrollment, _ := submission.NewEnrollment(pub, "laptop-42")
+
+	status, err := Enroll(server.URL, map[string]string{"Authorization": "Bearer enroll-token"}, enrollment)
+	if err != nil || status != http.StatusCreated {
+		t.Fatalf("Enroll() = %d, %v", status, err)
+	}
+	if received != enrollment {
+		t.Errorf("server received %+v, want %+v", received, enrollment)
+	}
+
+	if _, err := Enroll(server.URL, nil, enrollment); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected an unauthorized enrollment to fail, got %v", err)
+	}
+	if _, err := Enroll("http://hub.example.com/enroll", nil, enrollment); err == nil || !strings.Contains(err.Error(), "insecure HTTP enrollment") {
+		t.Errorf("expected an insecure URL to be refused, got %v", err)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/benedictjohannes/crobe/internal/encryption"
 	"github.com/benedictjohannes/crobe/report"
 )
 
@@ -27,28 +29,53 @@ func WriteToFolder(reportsDir string, res report.FinalResult) error {
 	}
 
 	reportBase := filepath.Join(reportsDir, timestamp+".report")
-	logFile := reportBase + ".log"
-	mdFile := reportBase + ".md"
-	jsonFile := reportBase + ".json"
+	jsonBytes, err := json.MarshalIndent(res.Structured, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON report: %w", err)
+	}
 
-	if err := os.WriteFile(logFile, []byte(res.Log), 0644); err != nil {
+	logFile, err := writeReportFile(reportBase+".log", []byte(res.Log))
+	if err != nil {
 		return fmt.Errorf("failed to write log file: %w", err)
 	}
-	if err := os.WriteFile(mdFile, []byte(res.Markdown), 0644); err != nil {
+	mdFile, err := writeReportFile(reportBase+".md", []byte(res.Markdown))
+	if err != nil {
 		return fmt.Errorf("failed to write md file: %w", err)
 	}
-	jsonBytes, err := json.MarshalIndent(res.Structured, "", "  ")
+	jsonFile, err := writeReportFile(reportBase+".json", jsonBytes)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON report: %w", err)
-	}
-	if err := os.WriteFile(jsonFile, jsonBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write JSON file: %w", err)
 	}
+	outputFiles := make([]string, len(res.Outputs))
+	for i, o := range res.Outputs {
+		if outputFiles[i], err = writeReportFile(reportBase+o.Ext, o.Content); err != nil {
+			return fmt.Errorf("failed to write %s file: %w", o.Format, err)
+		}
+	}
 
 	fmt.Printf("\n✅ Generation Complete!\n")
-	fmt.Printf("📊 PASS: %d, FAIL: %d\n", res.Structured.Stats.Passed, res.Structured.Stats.Failed)
+	fmt.Printf("📊 %s\n", res.Structured.Stats.Summary())
 	fmt.Printf("📝 Log: %s\n", logFile)
 	fmt.Printf("📝 Markdown: %s\n", mdFile)
 	fmt.Printf("📊 JSON Report: %s\n", jsonFile)
+	for i, o := range res.Outputs {
+		fmt.Printf("📄 %s: %s\n", strings.ToUpper(string(o.Format)), outputFiles[i])
+	}
+	if len(Recipients) > 0 {
+		fmt.Printf("🔒 Encrypted to %d recipient(s), decrypt with 'crobe decrypt'\n", len(Recipients))
+	}
 	return nil
 }
+
+// writeReportFile writes a report file, encrypted to the Recipients with the encryption.Ext
+// extension if any, and returns the path written.
+func writeReportFile(path string, data []byte) (string, error) {
+	if len(Recipients) > 0 {
+		encrypted, err := encryption.Encrypt(data, Recipients)
+		if err != nil {
+			return "", err
+		}
+		path, data = path+encryption.Ext, encrypted
+	}
+	return path, os.WriteFile(path, data, 0644)
+}
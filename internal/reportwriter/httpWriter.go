@@ -3,12 +3,18 @@ package reportwriter
 import (
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
+	"github.com/benedictjohannes/crobe/internal/encryption"
+	"github.com/benedictjohannes/crobe/submission"
 	"bytes"
+	"crypto"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -18,63 +24,192 @@ import (
 	"time"
 )
 
-type JSONPayload struct {
-	JSON          string `json:"json"`
-	JSONSignature string `json:"jsonSignature,omitempty"`
-	MD            string `json:"md"`
-	MDSignature   string `json:"mdSignature,omitempty"`
-	Log           string `json:"log"`
-	LogSignature  string `json:"logSignature,omitempty"`
-}
+// TLS holds the TLS options given on the command line. They override those of the
+// playbook's reportDestinationHttps.
+var TLS tlsconfig.Options
+
+// DeviceKey, when set, signs every submitted report, with the ID of its public key sent
+// along for the server to look up the enrolled key.
+var DeviceKey crypto.Signer
+
+type JSONPayload = submission.Envelope
 
 type reportFile struct {
 	filename    string
 	contentType string
-	content     []byte
+	// content is base64 encoded
+	content string
+}
+
+// IdempotencyKeyHeader carries a key unique to each report. It is kept when a spooled
+// submission is retried, so that the server can discard duplicates.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// submissionRequest is a report ready to be posted. Spooled submissions are retried as is.
+type submissionRequest struct {
+	URL            string            `json:"url"`
+	ContentType    string            `json:"contentType"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           []byte            `json:"body"`
+	IdempotencyKey string            `json:"idempotencyKey"`
+	TLS            tlsconfig.Options `json:"tls"`
+}
+
+// retryableError is a failed submission that may succeed later without changing the report
+// (network error, server error).
+type retryableError struct {
+	error
+}
+
+func (e retryableError) Unwrap() error {
+	return e.error
+}
+
+func isRetryable(err error) bool {
+	var retryable retryableError
+	return errors.As(err, &retryable)
 }
 
-// WriteToHTTP sends the report to a remote server.
+// WriteToHTTP sends the report to a remote server. When the submission fails for a reason
+// that may go away and ReportSpool is set, the report is spooled to be retried later.
 func WriteToHTTP(config *playbook.ReportDestinationConfig, res report.FinalResult) (err error) {
 	if !strings.HasPrefix(config.URL, "https://") {
 		return fmt.Errorf("insecure HTTP report submission is not allowed: %s", config.URL)
 	}
 
+	sub, err := newSubmission(config, res)
+	if err != nil {
+		return err
+	}
+
+	formatStr := config.Format
+	if config.Format == "" {
+		formatStr = "multipart (default)"
+	}
+	fmt.Printf("📤 Submitting report to: %s (Format: %s)\n", config.URL, formatStr)
+	if DeviceKey != nil {
+		keyID, _ := submission.KeyID(DeviceKey.Public())
+		fmt.Printf("🔑 Signed with device key %s\n", keyID)
+	}
+	if len(Recipients) > 0 {
+		fmt.Printf("🔒 Encrypted to %d recipient(s)\n", len(Recipients))
+	}
+
+	status, err := sub.send()
+	if err != nil {
+		if ReportSpool == nil || !isRetryable(err) {
+			return err
+		}
+		path, spoolErr := ReportSpool.add(sub, err)
+		if spoolErr != nil {
+			return fmt.Errorf("%w (failed to spool the report: %v)", err, spoolErr)
+		}
+		fmt.Printf("\n📦 Submission failed: %v\n", err)
+		fmt.Printf("📦 Report spooled to %s, to be retried on the next run or with 'crobe flush-spool'\n", path)
+		fmt.Printf("📊 %s\n", res.Structured.Stats.Summary())
+		return nil
+	}
+
+	fmt.Printf("\n✅ Submission Complete!\n")
+	fmt.Printf("📊 %s\n", res.Structured.Stats.Summary())
+	fmt.Printf("✅ Status: %d\n", status)
+	return nil
+}
+
+// newSubmission encodes the report in the configured format.
+func newSubmission(config *playbook.ReportDestinationConfig, res report.FinalResult) (*submissionRequest, error) {
 	jsonBytes, err := json.MarshalIndent(res.Structured, "", "  ")
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	contents := [][]byte{jsonBytes, []byte(res.Markdown), []byte(res.Log)}
+	contentTypes := []string{"application/json", "text/markdown", "text/plain"}
+	names := []string{"report.json", "report.md", "report.log"}
+	if config.IncludeOutputs {
+		for _, o := range res.Outputs {
+			contents = append(contents, o.Content)
+			contentTypes = append(contentTypes, o.ContentType)
+			names = append(names, o.Name())
+		}
+	}
+	var encryptionFormat string
+	if len(Recipients) > 0 {
+		for i, content := range contents {
+			if contents[i], err = encryption.Encrypt(content, Recipients); err != nil {
+				return nil, fmt.Errorf("failed to encrypt report: %w", err)
+			}
+			contentTypes[i] = encryption.ContentType
+		}
+		encryptionFormat = submission.EncryptionAge
 	}
 
+	encoded := submission.Files{
+		JSON:     base64.StdEncoding.EncodeToString(contents[0]),
+		Markdown: base64.StdEncoding.EncodeToString(contents[1]),
+		Log:      base64.StdEncoding.EncodeToString(contents[2]),
+	}
 	files := []reportFile{
-		{"report.json", "application/json", jsonBytes},
-		{"report.md", "text/markdown", []byte(res.Markdown)},
-		{"report.log", "text/plain", []byte(res.Log)},
+		{names[0], contentTypes[0], encoded.JSON},
+		{names[1], contentTypes[1], encoded.Markdown},
+		{names[2], contentTypes[2], encoded.Log},
+	}
+	for i := 3; i < len(contents); i++ {
+		if encoded.Outputs == nil {
+			encoded.Outputs = make(map[string]string)
+		}
+		encoded.Outputs[names[i]] = base64.StdEncoding.EncodeToString(contents[i])
+		files = append(files, reportFile{names[i], contentTypes[i], encoded.Outputs[names[i]]})
+	}
+
+	material := submission.Material{
+		DeviceID:  res.Structured.Hostname,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Nonce:     config.Nonce,
+		Files:     encoded,
+	}
+	var signature, envelopeSignature string
+	if DeviceKey != nil {
+		if material.KeyID, err = submission.KeyID(DeviceKey.Public()); err != nil {
+			return nil, err
+		}
+		signature, err = submission.Sign(DeviceKey, material)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign report with the device key: %w", err)
+		}
+	}
+	if config.SignatureSecret != "" {
+		envelopeSignature = submission.SignHMAC(config.SignatureSecret, material)
 	}
 
-	var body io.Reader
+	var body []byte
 	var contentType string
 
 	if config.Format == playbook.ReportFormatJSON {
-		jsonB64 := base64.StdEncoding.EncodeToString(jsonBytes)
-		mdB64 := base64.StdEncoding.EncodeToString([]byte(res.Markdown))
-		logB64 := base64.StdEncoding.EncodeToString([]byte(res.Log))
-
 		payload := JSONPayload{
-			JSON: jsonB64,
-			MD:   mdB64,
-			Log:  logB64,
+			JSON:              encoded.JSON,
+			MD:                encoded.Markdown,
+			Log:               encoded.Log,
+			Files:             encoded.Outputs,
+			Encryption:        encryptionFormat,
+			DeviceID:          material.DeviceID,
+			Timestamp:         material.Timestamp,
+			Nonce:             material.Nonce,
+			KeyID:             material.KeyID,
+			Signature:         signature,
+			EnvelopeSignature: envelopeSignature,
 		}
 
 		if config.SignatureSecret != "" {
-			payload.JSONSignature = calculateHMAC(jsonB64, config.SignatureSecret)
-			payload.MDSignature = calculateHMAC(mdB64, config.SignatureSecret)
-			payload.LogSignature = calculateHMAC(logB64, config.SignatureSecret)
+			payload.JSONSignature = calculateHMAC(encoded.JSON, config.SignatureSecret)
+			payload.MDSignature = calculateHMAC(encoded.Markdown, config.SignatureSecret)
+			payload.LogSignature = calculateHMAC(encoded.Log, config.SignatureSecret)
 		}
 
-		jsonBody, err := json.MarshalIndent(payload, "", "  ")
+		body, err = json.MarshalIndent(payload, "", "  ")
 		if err != nil {
-			return err
+			return nil, err
 		}
-		body = bytes.NewReader(jsonBody)
 		contentType = "application/json"
 	} else {
 		// Default to Multipart
@@ -82,55 +217,89 @@ func WriteToHTTP(config *playbook.ReportDestinationConfig, res report.FinalResul
 		writer := multipart.NewWriter(b)
 		for _, f := range files {
 			if err = addFilePart(writer, f.filename, f.contentType, f.content, config.SignatureSecret); err != nil {
-				return err
+				return nil, err
+			}
+		}
+		fields := []struct{ name, value string }{
+			{submission.FieldDeviceID, material.DeviceID},
+			{submission.FieldTimestamp, material.Timestamp},
+			{submission.FieldNonce, material.Nonce},
+			{submission.FieldKeyID, material.KeyID},
+			{submission.FieldSignature, signature},
+			{submission.FieldEnvelopeSignature, envelopeSignature},
+			{submission.FieldEncryption, encryptionFormat},
+		}
+		for _, field := range fields {
+			if field.value == "" {
+				continue
+			}
+			if err = writer.WriteField(field.name, field.value); err != nil {
+				return nil, err
 			}
 		}
 		writer.Close()
-		body = b
+		body = b.Bytes()
 		contentType = writer.FormDataContentType()
 	}
 
-	req, err := http.NewRequest("POST", config.URL, body)
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate idempotency key: %w", err)
 	}
 
-	req.Header.Set("Content-Type", contentType)
-	for k, v := range config.AdditionalHeaders {
-		req.Header.Set(k, v)
+	tlsOptions := tlsconfig.Options{
+		CABundle:   config.CABundle,
+		ClientCert: config.ClientCert,
+		ClientKey:  config.ClientKey,
+		Pins:       config.Pins,
 	}
+	return &submissionRequest{
+		URL:            config.URL,
+		ContentType:    contentType,
+		Headers:        config.AdditionalHeaders,
+		Body:           body,
+		IdempotencyKey: hex.EncodeToString(key),
+		TLS:            tlsOptions.Override(TLS),
+	}, nil
+}
 
-	client := &http.Client{
-		Timeout: 60 * time.Second,
+// send posts the submission and returns the response status.
+func (s *submissionRequest) send() (int, error) {
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(s.Body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	formatStr := config.Format
-	if config.Format == "" {
-		formatStr = "multipart (default)"
+	req.Header.Set("Content-Type", s.ContentType)
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(IdempotencyKeyHeader, s.IdempotencyKey)
+
+	client, err := tlsconfig.NewClient(s.TLS, 60*time.Second)
+	if err != nil {
+		// The TLS files may be fixed or restored before a retry
+		return 0, retryableError{fmt.Errorf("failed to configure TLS: %w", err)}
 	}
-	fmt.Printf("📤 Submitting report to: %s (Format: %s)\n", config.URL, formatStr)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to submit report: %w", err)
+		return 0, retryableError{fmt.Errorf("failed to submit report: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to submit report: status %d. Response: %s", resp.StatusCode, string(respBody))
+		err := fmt.Errorf("failed to submit report: status %d. Response: %s", resp.StatusCode, string(respBody))
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout {
+			return resp.StatusCode, retryableError{err}
+		}
+		return resp.StatusCode, err
 	}
-
-	fmt.Printf("\n✅ Submission Complete!\n")
-	fmt.Printf("📊 PASS: %d, FAIL: %d\n", res.Structured.Stats.Passed, res.Structured.Stats.Failed)
-	fmt.Printf("✅ Status: %d\n", resp.StatusCode)
-	return nil
+	return resp.StatusCode, nil
 }
 
-func addFilePart(writer *multipart.Writer, filename string, contentType string, content []byte, secret string) error {
-	// Base64 encode the content
-	b64Content := base64.StdEncoding.EncodeToString(content)
-
+func addFilePart(writer *multipart.Writer, filename string, contentType string, b64Content string, secret string) error {
 	// 1. Add the Base64-encoded file part
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, filename, filename))
@@ -3,12 +3,21 @@ package reportwriter
 import (
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
+	"github.com/benedictjohannes/crobe/internal/tlsconfig"
+	"github.com/benedictjohannes/crobe/submission"
+	"filippo.io/age"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -71,7 +80,7 @@ func TestWriteToHTTP(t *testing.T) {
 			}
 		}
 
-		checkFile("report.json", "application/json", "{\n  \"timestamps\": {\n    \"start\": \"2026-04-10T12:00:00Z\",\n    \"end\": \"2026-04-10T12:00:10Z\"\n  },\n  \"username\": \"testuser\",\n  \"os\": \"\",\n  \"arch\": \"\",\n  \"assertions\": null,\n  \"stats\": {\n    \"passed\": 0,\n    \"failed\": 0\n  }\n}", true)
+		checkFile("report.json", "application/json", "{\n  \"timestamps\": {\n    \"start\": \"2026-04-10T12:00:00Z\",\n    \"end\": \"2026-04-10T12:00:10Z\"\n  },\n  \"username\": \"testuser\",\n  \"os\": \"\",\n  \"arch\": \"\",\n  \"assertions\": null,\n  \"stats\": {\n    \"passed\": 0,\n    \"failed\": 0,\n    \"errored\": 0,\n    \"skipped\": 0,\n    \"notApplicable\": 0\n  }\n}", true)
 		checkFile("report.md", "text/markdown", "# Test Markdown", true)
 		checkFile("report.log", "text/plain", "test log", true)
 
@@ -218,6 +227,206 @@ func TestWriteToHTTP_ServerErrors(t *testing.T) {
 	}
 }
 
+func TestWriteToHTTP_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+	wrongPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	defer func() { TLS = tlsconfig.Options{} }()
+
+	config := &playbook.ReportDestinationConfig{URL: server.URL, CABundle: caPath, Pins: []string{pin}}
+	if err := WriteToHTTP(config, report.FinalResult{}); err != nil {
+		t.Errorf("Expected the pinned CA bundle to be trusted, got %v", err)
+	}
+
+	config.Pins = []string{wrongPin}
+	if err := WriteToHTTP(config, report.FinalResult{}); err == nil || !strings.Contains(err.Error(), "pinned public key") {
+		t.Errorf("Expected a pinning error, got %v", err)
+	}
+
+	// Command line options override the playbook
+	TLS = tlsconfig.Options{Pins: []string{pin}}
+	if err := WriteToHTTP(config, report.FinalResult{}); err != nil {
+		t.Errorf("Expected --pin to override the playbook pins, got %v", err)
+	}
+
+	TLS = tlsconfig.Options{Pins: []string{"not-a-pin"}}
+	if err := WriteToHTTP(config, report.FinalResult{}); err == nil || !strings.Contains(err.Error(), "failed to configure TLS") {
+		t.Errorf("Expected a TLS configuration error, got %v", err)
+	}
+}
+
+func TestWriteToHTTP_DeviceKey(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	keyID, _ := submission.KeyID(key.Public())
+	DeviceKey = key
+	defer func() { DeviceKey = nil }()
+
+	var received JSONPayload
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			r.ParseMultipartForm(10 << 20)
+			received = JSONPayload{
+				DeviceID:          r.FormValue("deviceId"),
+				Timestamp:         r.FormValue("timestamp"),
+				Nonce:             r.FormValue("nonce"),
+				KeyID:             r.FormValue("keyId"),
+				Signature:         r.FormValue("signature"),
+				EnvelopeSignature: r.FormValue("envelopeSignature"),
+			}
+			for name, dst := range map[string]*string{"report.json": &received.JSON, "report.md": &received.MD, "report.log": &received.Log} {
+				f, _, err := r.FormFile(name)
+				if err != nil {
+					t.Errorf("Missing file part: %s", name)
+					continue
+				}
+				content, _ := io.ReadAll(f)
+				f.Close()
+				*dst = string(content)
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&received)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	res := report.FinalResult{Markdown: "# Device", Log: "device log"}
+	res.Structured.Hostname = "laptop-42"
+	guard := submission.NewReplayGuard(time.Minute)
+	for _, format := range []playbook.ReportFormat{playbook.ReportFormatMultipart, playbook.ReportFormatJSON} {
+		received = JSONPayload{}
+		nonce := "nonce-" + string(format)
+		config := &playbook.ReportDestinationConfig{URL: server.URL, Format: format, SignatureSecret: "secret", Nonce: nonce}
+		if err := WriteToHTTP(config, res); err != nil {
+			t.Fatalf("%s: WriteToHTTP failed: %v", format, err)
+		}
+		if received.KeyID != keyID {
+			t.Errorf("%s: expected key ID %s, got %q", format, keyID, received.KeyID)
+		}
+		if received.DeviceID != "laptop-42" || received.Nonce != nonce {
+			t.Errorf("%s: expected device laptop-42 and nonce %s, got %q and %q", format, nonce, received.DeviceID, received.Nonce)
+		}
+		if err := submission.Verify(key.Public(), received.Material(), received.Signature); err != nil {
+			t.Errorf("%s: expected a valid device signature, got %v", format, err)
+		}
+		if err := submission.VerifyHMAC("secret", received.Material(), received.EnvelopeSignature); err != nil {
+			t.Errorf("%s: expected a valid envelope signature, got %v", format, err)
+		}
+		if err := guard.Check(received.Material(), time.Now()); err != nil {
+			t.Errorf("%s: expected a fresh submission, got %v", format, err)
+		}
+		if err := guard.Check(received.Material(), time.Now()); err == nil {
+			t.Errorf("%s: expected a replayed submission to be rejected", format)
+		}
+	}
+}
+
+func TestWriteToHTTP_Encrypted(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	identity, _ := age.GenerateX25519Identity()
+	Recipients = []age.Recipient{identity.Recipient()}
+	defer func() { Recipients = nil }()
+
+	var received JSONPayload
+	var partType string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			r.ParseMultipartForm(10 << 20)
+			received = JSONPayload{Encryption: r.FormValue("encryption")}
+			f, header, err := r.FormFile("report.md")
+			if err != nil {
+				t.Errorf("Missing file part: report.md")
+			} else {
+				content, _ := io.ReadAll(f)
+				f.Close()
+				received.MD = string(content)
+				partType = header.Header.Get("Content-Type")
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&received)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	res := report.FinalResult{Markdown: "# Secret", Log: "secret log"}
+	for _, format := range []playbook.ReportFormat{playbook.ReportFormatMultipart, playbook.ReportFormatJSON} {
+		received, partType = JSONPayload{}, ""
+		config := &playbook.ReportDestinationConfig{URL: server.URL, Format: format}
+		if err := WriteToHTTP(config, res); err != nil {
+			t.Fatalf("%s: WriteToHTTP failed: %v", format, err)
+		}
+		if received.Encryption != submission.EncryptionAge {
+			t.Errorf("%s: expected encryption %q, got %q", format, submission.EncryptionAge, received.Encryption)
+		}
+		if format == playbook.ReportFormatMultipart && partType != "application/age" {
+			t.Errorf("expected encrypted parts to be application/age, got %q", partType)
+		}
+		md, err := submission.DecryptFile(received.MD, identity)
+		if err != nil || string(md) != "# Secret" {
+			t.Errorf("%s: DecryptFile() = %q, %v", format, md, err)
+		}
+	}
+}
+
+func TestWriteToHTTP_Outputs(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	var received JSONPayload
+	var sarifPart string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			r.ParseMultipartForm(10 << 20)
+			if f, _, err := r.FormFile("report.sarif"); err == nil {
+				content, _ := io.ReadAll(f)
+				f.Close()
+				sarifPart = string(content)
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&received)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sarif := []byte(`{"version":"2.1.0"}`)
+	encoded := base64.StdEncoding.EncodeToString(sarif)
+	res := report.FinalResult{Outputs: []report.Output{{Format: playbook.OutputSARIF, Ext: ".sarif", ContentType: "application/sarif+json", Content: sarif}}}
+	for _, include := range []bool{false, true} {
+		received, sarifPart = JSONPayload{}, ""
+		config := &playbook.ReportDestinationConfig{URL: server.URL, IncludeOutputs: include, SignatureSecret: "secret"}
+		if err := WriteToHTTP(config, res); err != nil {
+			t.Fatalf("WriteToHTTP failed: %v", err)
+		}
+		if (sarifPart == encoded) != include {
+			t.Errorf("includeOutputs %v: got report.sarif part %q", include, sarifPart)
+		}
+
+		config.Format = playbook.ReportFormatJSON
+		if err := WriteToHTTP(config, res); err != nil {
+			t.Fatalf("WriteToHTTP failed: %v", err)
+		}
+		if (received.Files["report.sarif"] == encoded) != include {
+			t.Errorf("includeOutputs %v: got envelope files %v", include, received.Files)
+		}
+		if err := submission.VerifyHMAC("secret", received.Material(), received.EnvelopeSignature); err != nil {
+			t.Errorf("includeOutputs %v: %v", include, err)
+		}
+	}
+}
+
 func TestWriteToHTTP_InvalidURL(t *testing.T) {
 	config := &playbook.ReportDestinationConfig{
 		URL: "https://   invalid", // Spaces make it invalid for NewRequest
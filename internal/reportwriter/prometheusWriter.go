@@ -0,0 +1,44 @@
+package reportwriter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/benedictjohannes/crobe/report"
+)
+
+// WriteToPrometheus writes the report metrics to a .prom file for the textfile collector of
+// node_exporter. The file is replaced atomically, so that the collector never reads a
+// partial file. Metrics are never encrypted: the collector must be able to read them.
+func WriteToPrometheus(path string, res report.FinalResult) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	// The collector only reads *.prom files, so it ignores the temporary file
+	f, err := os.CreateTemp(dir, ".crobe-*.prom.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(report.PrometheusMetrics(res.Structured)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+
+	fmt.Printf("\n✅ Generation Complete!\n")
+	fmt.Printf("📊 %s\n", res.Structured.Stats.Summary())
+	fmt.Printf("📈 Metrics: %s\n", path)
+	return nil
+}
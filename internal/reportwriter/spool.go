@@ -0,0 +1,249 @@
+package reportwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportSpool, when set, keeps on disk the reports whose submission failed for a reason that
+// may go away (network error, server error), to be retried later. Nil disables spooling.
+var ReportSpool *Spool
+
+const (
+	// DefaultSpoolMaxAge is how long a spooled report is retried before it is dropped.
+	DefaultSpoolMaxAge = 30 * 24 * time.Hour
+	// DefaultSpoolMaxSize is the total size of the spooled reports beyond which the oldest
+	// are dropped.
+	DefaultSpoolMaxSize int64 = 64 << 20
+
+	// Retries of a spooled report back off exponentially from spoolRetryBase, up to
+	// spoolRetryMax between attempts.
+	spoolRetryBase = time.Minute
+	spoolRetryMax  = 6 * time.Hour
+)
+
+// Spool stores failed submissions in a directory, one file each, named so that they sort
+// in the order they were queued.
+type Spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	// unreachable is set once a submission failed in this process. The server is then
+	// likely still unreachable, so Flush does not wait on it again unless forced.
+	unreachable bool
+}
+
+type spoolEntry struct {
+	submissionRequest
+	QueuedAt    time.Time `json:"queuedAt"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError"`
+	path        string
+	size        int64
+}
+
+// FlushResult counts the outcome of the spooled reports in a Flush.
+type FlushResult struct {
+	Delivered int
+	Pending   int
+	Dropped   int
+}
+
+// NewSpool returns a spool in dir holding up to maxSize bytes of reports, each retried for
+// up to maxAge.
+func NewSpool(dir string, maxSize int64, maxAge time.Duration) *Spool {
+	return &Spool{dir: dir, maxSize: maxSize, maxAge: maxAge}
+}
+
+// DefaultSpoolDir returns the crobe/spool folder of the user's cache directory, or "" if the
+// user has none.
+func DefaultSpoolDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "crobe", "spool")
+}
+
+// retryDelay returns how long to wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := spoolRetryBase
+	for i := 1; i < attempts && delay < spoolRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, spoolRetryMax)
+}
+
+// add spools a submission that failed with err, then drops the oldest reports if the spool
+// grew beyond its maximum size. It returns the path of the spooled file.
+func (s *Spool) add(sub *submissionRequest, err error) (string, error) {
+	s.unreachable = true
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	entry := &spoolEntry{
+		submissionRequest: *sub,
+		QueuedAt:          now,
+		Attempts:          1,
+		NextAttempt:       now.Add(retryDelay(1)),
+		LastError:         err.Error(),
+		path:              filepath.Join(s.dir, fmt.Sprintf("%d-%s.json", now.UnixNano(), sub.IdempotencyKey)),
+	}
+	if err := entry.save(); err != nil {
+		return "", err
+	}
+
+	entries, err := s.entries()
+	if err != nil {
+		return entry.path, nil
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	// The report just spooled is kept even when it alone exceeds the maximum size
+	for len(entries) > 1 && total > s.maxSize {
+		oldest := entries[0]
+		entries = entries[1:]
+		total -= oldest.size
+		if os.Remove(oldest.path) == nil {
+			fmt.Printf("🗑️ Spool over %d MiB: dropped the report for %s queued %s\n", s.maxSize>>20, oldest.URL, oldest.QueuedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return entry.path, nil
+}
+
+// entries returns the spooled reports, oldest first.
+func (s *Spool) entries() ([]*spoolEntry, error) {
+	files, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var entries []*spoolEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("⚠️ Skipped the spooled report %s: %v\n", path, err)
+			continue
+		}
+		entry := &spoolEntry{path: path, size: int64(len(data))}
+		// A damaged report is set aside so that it does not block the others
+		if err := json.Unmarshal(data, entry); err != nil {
+			quarantined := path + ".corrupt"
+			if os.Rename(path, quarantined) == nil {
+				path = quarantined
+			}
+			fmt.Printf("⚠️ Set aside the unreadable spooled report %s: %v\n", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return entries, nil
+}
+
+// save writes the entry to a temporary file synced to disk, then renames it into place, so
+// that a crash or a full disk never leaves a truncated report in the spool.
+func (e *spoolEntry) save() error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// The temporary file does not end in .json, so entries ignores it
+	f, err := os.CreateTemp(filepath.Dir(e.path), ".spool-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write spooled report: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spooled report: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spooled report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spooled report: %w", err)
+	}
+	if err := os.Rename(f.Name(), e.path); err != nil {
+		return fmt.Errorf("failed to write spooled report: %w", err)
+	}
+	e.size = int64(len(data))
+	return nil
+}
+
+// Flush retries the spooled reports whose backoff has elapsed, oldest first, or all of them
+// when force is set. It stops at the first report failing again, as the others are likely
+// to fail the same way. Reports rejected by their server or older than the maximum age are
+// dropped.
+func (s *Spool) Flush(force bool) (FlushResult, error) {
+	var result FlushResult
+	if s == nil {
+		return result, nil
+	}
+	entries, err := s.entries()
+	if err != nil {
+		return result, err
+	}
+	now := time.Now()
+	stopped := !force && s.unreachable
+	for _, entry := range entries {
+		queued := entry.QueuedAt.Format("2006-01-02 15:04:05")
+		if age := now.Sub(entry.QueuedAt); age > s.maxAge {
+			if err := os.Remove(entry.path); err != nil {
+				return result, err
+			}
+			fmt.Printf("🗑️ Dropped the spooled report for %s queued %s: older than the maximum age of %s\n", entry.URL, queued, s.maxAge)
+			result.Dropped++
+			continue
+		}
+		if stopped || (!force && now.Before(entry.NextAttempt)) {
+			result.Pending++
+			continue
+		}
+
+		fmt.Printf("📤 Retrying the report for %s queued %s (attempt %d)\n", entry.URL, queued, entry.Attempts+1)
+		_, err := entry.send()
+		switch {
+		case err == nil:
+			if err := os.Remove(entry.path); err != nil {
+				return result, err
+			}
+			fmt.Printf("✅ Delivered the spooled report queued %s\n", queued)
+			result.Delivered++
+		case isRetryable(err):
+			s.unreachable = true
+			stopped = true
+			entry.Attempts++
+			entry.NextAttempt = now.Add(retryDelay(entry.Attempts))
+			entry.LastError = err.Error()
+			if err := entry.save(); err != nil {
+				return result, err
+			}
+			fmt.Printf("📦 Still failing, next attempt after %s: %v\n", entry.NextAttempt.Format("2006-01-02 15:04:05"), err)
+			result.Pending++
+		default:
+			if err := os.Remove(entry.path); err != nil {
+				return result, err
+			}
+			fmt.Printf("❌ Dropped the spooled report queued %s, rejected by the server: %v\n", queued, err)
+			result.Dropped++
+		}
+	}
+	return result, nil
+}
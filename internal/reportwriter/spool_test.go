@@ -0,0 +1,211 @@
+package reportwriter
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benedictjohannes/crobe/playbook"
+	"github.com/benedictjohannes/crobe/report"
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+func TestWriteToHTTP_Spool(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	var keys []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	setStatus := func(code int) {
+		mu.Lock()
+		status = code
+		mu.Unlock()
+	}
+
+	dir := t.TempDir()
+	ReportSpool = NewSpool(dir, DefaultSpoolMaxSize, DefaultSpoolMaxAge)
+	defer func() { ReportSpool = nil }()
+	config := &playbook.ReportDestinationConfig{URL: server.URL}
+
+	// A server error spools the report instead of failing
+	if err := WriteToHTTP(config, report.FinalResult{Markdown: "# Spooled"}); err != nil {
+		t.Fatalf("Expected the report to be spooled, got %v", err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 spooled report, got %d", len(files))
+	}
+
+	// The server just failed: the next flush of this run does not retry it
+	setStatus(http.StatusOK)
+	if result, err := ReportSpool.Flush(false); err != nil || result.Pending != 1 || len(keys) != 1 {
+		t.Errorf("Expected the report to stay pending, got %+v, %v (%d requests)", result, err, len(keys))
+	}
+
+	// Neither does the next run, until the backoff elapsed
+	ReportSpool = NewSpool(dir, DefaultSpoolMaxSize, DefaultSpoolMaxAge)
+	if result, _ := ReportSpool.Flush(false); result.Pending != 1 || len(keys) != 1 {
+		t.Errorf("Expected the backoff to be respected, got %+v (%d requests)", result, len(keys))
+	}
+
+	// A forced flush delivers it with the same idempotency key
+	if result, err := ReportSpool.Flush(true); err != nil || result.Delivered != 1 {
+		t.Errorf("Expected the report to be delivered, got %+v, %v", result, err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected the retry to reuse the idempotency key, got %v", keys)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected the delivered report to leave the spool, got %d files", len(files))
+	}
+
+	// Rejected reports are not spooled
+	setStatus(http.StatusBadRequest)
+	if err := WriteToHTTP(config, report.FinalResult{}); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Expected a rejected report to fail, got %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected a rejected report not to be spooled, got %d files", len(files))
+	}
+}
+
+func TestSpool_Flush(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/reject") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	spool := NewSpool(dir, DefaultSpoolMaxSize, time.Hour)
+	spoolAt := func(name, url string, queued time.Time, attempts int) string {
+		entry := &spoolEntry{
+			submissionRequest: submissionRequest{URL: url, IdempotencyKey: name},
+			QueuedAt:          queued,
+			Attempts:          attempts,
+			path:              filepath.Join(dir, name+".json"),
+		}
+		if err := entry.save(); err != nil {
+			t.Fatal(err)
+		}
+		return entry.path
+	}
+	now := time.Now()
+	expired := spoolAt("1-expired", server.URL, now.Add(-2*time.Hour), 3)
+	rejected := spoolAt("2-rejected", server.URL+"/reject", now.Add(-time.Minute), 1)
+	failing := spoolAt("3-failing", server.URL, now.Add(-time.Minute), 2)
+	notTried := spoolAt("4-not-tried", server.URL, now.Add(-time.Minute), 1)
+
+	result, err := spool.Flush(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dropped != 2 || result.Pending != 2 || result.Delivered != 0 {
+		t.Errorf("Unexpected flush result: %+v", result)
+	}
+	for _, path := range []string{expired, rejected} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be dropped", path)
+		}
+	}
+
+	entries, _ := spool.entries()
+	if len(entries) != 2 || entries[0].path != failing || entries[1].path != notTried {
+		t.Fatalf("Unexpected spool entries: %+v", entries)
+	}
+	if entries[0].Attempts != 3 || !strings.Contains(entries[0].LastError, "status 502") {
+		t.Errorf("Expected the failed attempt to be recorded, got %+v", entries[0])
+	}
+	if wait := time.Until(entries[0].NextAttempt); wait < 3*time.Minute || wait > 4*time.Minute {
+		t.Errorf("Expected a 4m backoff after 3 attempts, got %s", wait)
+	}
+	if entries[1].Attempts != 1 {
+		t.Errorf("Expected the flush to stop at the first failure, got %d attempts", entries[1].Attempts)
+	}
+}
+
+func TestSpool_Flush_Corrupt(t *testing.T) {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	delivered := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// A report truncated by a crash while it was written
+	truncated := filepath.Join(dir, "1-truncated.json")
+	if err := os.WriteFile(truncated, []byte(`{"url":"https://hub.exa`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entry := &spoolEntry{
+		submissionRequest: submissionRequest{URL: server.URL, IdempotencyKey: "valid"},
+		QueuedAt:          time.Now(),
+		Attempts:          1,
+		path:              filepath.Join(dir, "2-valid.json"),
+	}
+	if err := entry.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewSpool(dir, DefaultSpoolMaxSize, DefaultSpoolMaxAge).Flush(true)
+	if err != nil || result.Delivered != 1 || delivered != 1 {
+		t.Errorf("Expected the valid report to be delivered, got %+v, %v (%d requests)", result, err, delivered)
+	}
+	if _, err := os.Stat(truncated + ".corrupt"); err != nil {
+		t.Errorf("Expected the truncated report to be set aside: %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only the set aside report to remain, got %d files", len(files))
+	}
+}
+
+func TestSpool_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	spool := NewSpool(dir, 2500, DefaultSpoolMaxAge)
+	for i := 0; i < 3; i++ {
+		sub := &submissionRequest{URL: "https://hub.example.com", Body: make([]byte, 500), IdempotencyKey: string(rune('a' + i))}
+		if _, err := spool.add(sub, retryableError{os.ErrDeadlineExceeded}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := spool.entries()
+	if len(entries) != 2 || entries[0].IdempotencyKey != "b" || entries[1].IdempotencyKey != "c" {
+		t.Errorf("Expected the oldest report to be dropped, got %d entries", len(entries))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 20: spoolRetryMax}
+	for attempts, want := range tests {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestDefaultSpoolMaxAge_AcceptedByReplayGuard(t *testing.T) {
+	if DefaultSpoolMaxAge > submission.DefaultMaxAge {
+		t.Errorf("expected reports spooled up to %s to be accepted by the default ReplayGuard (%s)", DefaultSpoolMaxAge, submission.DefaultMaxAge)
+	}
+}
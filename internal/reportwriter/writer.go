@@ -5,8 +5,14 @@ import (
 
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
+
+	"filippo.io/age"
 )
 
+// Recipients, when set, encrypt every report file, written to a folder or submitted, so that
+// only the holders of their identities can read it.
+var Recipients []age.Recipient
+
 // DispatchReport decides where to send the report based on the configuration.
 func DispatchReport(config *playbook.Playbook, res report.FinalResult) error {
 	destination := config.ReportDestination
@@ -23,6 +29,11 @@ func DispatchReport(config *playbook.Playbook, res report.FinalResult) error {
 			return fmt.Errorf("reportDestination is 'https' but reportDestinationHttps is missing")
 		}
 		return WriteToHTTP(config.ReportDestinationHTTPS, res)
+	case playbook.ReportDestinationPrometheus:
+		if config.ReportDestinationPrometheus == "" {
+			return fmt.Errorf("reportDestination is 'prometheus' but reportDestinationPrometheus is missing")
+		}
+		return WriteToPrometheus(config.ReportDestinationPrometheus, res)
 	case playbook.ReportDestinationFolder, "":
 		if reportsDir == "" {
 			reportsDir = config.ReportDestinationFolder
@@ -8,8 +8,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/benedictjohannes/crobe/internal/encryption"
 	"github.com/benedictjohannes/crobe/playbook"
 	"github.com/benedictjohannes/crobe/report"
+
+	"filippo.io/age"
 )
 
 func TestDispatchReport(t *testing.T) {
@@ -169,6 +172,43 @@ func TestDispatchReport(t *testing.T) {
 			t.Errorf("Expected 3 files in reports directory (override), got %d", len(files))
 		}
 	})
+
+	t.Run("prometheus missing path", func(t *testing.T) {
+		config := &playbook.Playbook{
+			ReportDestination: playbook.ReportDestinationPrometheus,
+		}
+		err := DispatchReport(config, res)
+		if err == nil || !strings.Contains(err.Error(), "reportDestinationPrometheus is missing") {
+			t.Errorf("Expected error for missing path, got %v", err)
+		}
+	})
+
+	t.Run("prometheus success", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "textfile", "crobe.prom")
+		config := &playbook.Playbook{
+			ReportDestination:           playbook.ReportDestinationPrometheus,
+			ReportDestinationPrometheus: path,
+		}
+		// Writing twice replaces the file
+		for i := 0; i < 2; i++ {
+			if err := DispatchReport(config, res); err != nil {
+				t.Fatalf("DispatchReport to prometheus failed: %v", err)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), "crobe_assertions{") {
+			t.Errorf("Expected metrics in %s, got %q (%v)", path, data, err)
+		}
+		info, _ := os.Stat(path)
+		if info != nil && info.Mode().Perm() != 0644 {
+			t.Errorf("Expected the metrics file to be readable by the collector, got %v", info.Mode())
+		}
+		// No temporary file is left behind
+		files, _ := os.ReadDir(filepath.Dir(path))
+		if len(files) != 1 {
+			t.Errorf("Expected only the metrics file, got %d files", len(files))
+		}
+	})
 }
 
 func TestWriteToFolder(t *testing.T) {
@@ -255,6 +295,53 @@ func TestWriteToFolder(t *testing.T) {
 	})
 }
 
+func TestWriteToFolder_Encrypted(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	Recipients = []age.Recipient{identity.Recipient()}
+	defer func() { Recipients = nil }()
+
+	dir := t.TempDir()
+	res := report.FinalResult{Structured: report.FinalReport{Username: "testuser"}, Markdown: "# Secret", Log: "secret log"}
+	if err := WriteToFolder(dir, res); err != nil {
+		t.Fatalf("WriteToFolder failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files in reports directory, got %v", files)
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".age") {
+			t.Errorf("Expected only encrypted files, got %s", file)
+			continue
+		}
+		data, _ := os.ReadFile(file)
+		plaintext, err := encryption.Decrypt(data, []age.Identity{identity})
+		if err != nil {
+			t.Errorf("%s: Decrypt failed: %v", file, err)
+		}
+		if strings.HasSuffix(file, ".md.age") && string(plaintext) != "# Secret" {
+			t.Errorf("Expected the markdown report, got %q", plaintext)
+		}
+	}
+}
+
+func TestWriteToFolder_Outputs(t *testing.T) {
+	dir := t.TempDir()
+	res := report.FinalResult{Outputs: []report.Output{{Format: playbook.OutputSARIF, Ext: ".sarif", Content: []byte(`{"version":"2.1.0"}`)}}}
+	if err := WriteToFolder(dir, res); err != nil {
+		t.Fatalf("WriteToFolder failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.report.sarif"))
+	if len(files) != 1 {
+		t.Fatalf("Expected a SARIF file in reports directory, got %v", files)
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != `{"version":"2.1.0"}` {
+		t.Errorf("Expected the SARIF output, got %q", data)
+	}
+}
+
 func TestWriteToFolder_Errors(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "reportwriter-error-test-*")
 	if err != nil {
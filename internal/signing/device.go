@@ -0,0 +1,85 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+// DefaultDeviceKeyPath returns the crobe/device.key file of the user's config directory, or
+// "" if the user has none.
+func DefaultDeviceKeyPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "crobe", "device.key")
+}
+
+// GenerateDeviceKey generates a key signing the reports of this device, with one of the
+// submission.Algorithm* algorithms.
+func GenerateDeviceKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case submission.AlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case submission.AlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unknown device key algorithm '%s' (expected %s or %s)", algorithm, submission.AlgorithmEd25519, submission.AlgorithmECDSAP256)
+	}
+}
+
+// SaveDeviceKey writes a device key as PEM encoded PKCS #8, only readable by its owner. It
+// never replaces an existing key, which would break the enrollment of the device.
+func SaveDeviceKey(path string, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadDeviceKey reads a PEM encoded PKCS #8 ed25519 or ECDSA P-256 device key.
+func LoadDeviceKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a signing key", path)
+	}
+	if _, err := submission.Algorithm(signer.Public()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,49 @@
+package signing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/submission"
+)
+
+func TestDeviceKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, algorithm := range []string{submission.AlgorithmEd25519, submission.AlgorithmECDSAP256} {
+		key, err := GenerateDeviceKey(algorithm)
+		if err != nil {
+			t.Fatalf("GenerateDeviceKey(%s) error = %v", algorithm, err)
+		}
+		path := filepath.Join(dir, algorithm, "device.key")
+		if err := SaveDeviceKey(path, key); err != nil {
+			t.Fatalf("SaveDeviceKey() error = %v", err)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+			t.Errorf("expected a 0600 device key, got %s", info.Mode().Perm())
+		}
+		loaded, err := LoadDeviceKey(path)
+		if err != nil {
+			t.Fatalf("LoadDeviceKey() error = %v", err)
+		}
+		if got, _ := submission.Algorithm(loaded.Public()); got != algorithm {
+			t.Errorf("loaded a %s key, want %s", got, algorithm)
+		}
+		if err := SaveDeviceKey(path, key); !os.IsExist(err) {
+			t.Errorf("expected an existing key not to be replaced, got %v", err)
+		}
+	}
+
+	if _, err := GenerateDeviceKey("rsa"); err == nil || !strings.Contains(err.Error(), "unknown device key algorithm") {
+		t.Errorf("expected an unknown algorithm error, got %v", err)
+	}
+	notKey := filepath.Join(dir, "not.key")
+	os.WriteFile(notKey, []byte("hello"), 0600)
+	if _, err := LoadDeviceKey(notKey); err == nil || !strings.Contains(err.Error(), "not a PEM encoded private key") {
+		t.Errorf("expected a PEM error, got %v", err)
+	}
+	if _, err := LoadDeviceKey(filepath.Join(dir, "missing.key")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package signing signs baked playbooks with ed25519 and verifies their detached signatures.
+// It also manages the device keys that sign submitted reports.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SignatureExt is appended to a playbook's location to find its detached signature.
+const SignatureExt = ".sig"
+
+// SignaturePath returns the default location of the signature of a playbook: the playbook
+// path or URL with .sig appended (before any query string of a URL).
+func SignaturePath(location string) string {
+	if strings.HasPrefix(location, "https://") {
+		if u, err := url.Parse(location); err == nil {
+			u.Path += SignatureExt
+			return u.String()
+		}
+	}
+	return location + SignatureExt
+}
+
+// Sign returns the content of a detached signature file for data: the base64 encoded
+// ed25519 signature.
+func Sign(key ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n")
+}
+
+// Verify checks a detached signature file, as produced by Sign, against data.
+func Verify(key ed25519.PublicKey, data []byte, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed signature: expected a base64 encoded ed25519 signature")
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature does not match the pinned public key %s", Fingerprint(key))
+	}
+	return nil
+}
+
+// SignFile writes the detached signature of the file at path next to it, and returns the
+// location of the signature.
+func SignFile(key ed25519.PrivateKey, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sigPath := SignaturePath(path)
+	if err := os.WriteFile(sigPath, Sign(key, data), 0644); err != nil {
+		return "", fmt.Errorf("failed to write signature: %w", err)
+	}
+	return sigPath, nil
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS #8 ed25519 private key, as generated by
+// `openssl genpkey -algorithm ed25519`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return edKey, nil
+}
+
+// LoadPublicKey reads a public key file in any format accepted by ParsePublicKey.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePublicKey parses an ed25519 public key as PEM (PKIX, as printed by `openssl pkey
+// -pubout`), or as base64 of either the DER encoding or the raw 32 key bytes. The base64
+// forms fit on one line, to be embedded in the agent at build time.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	if strings.HasPrefix(s, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(s))
+		if block == nil || block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("not a PEM encoded public key")
+		}
+		der = block.Bytes
+	} else {
+		raw, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("public key is neither PEM nor base64")
+		}
+		if len(raw) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(raw), nil
+		}
+		der = raw
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 public key")
+	}
+	return edKey, nil
+}
+
+// Fingerprint identifies a public key in messages, eg: "SHA256:3q2+7w...".
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("title: Baked\n")
+	sig := Sign(priv, data)
+
+	if err := Verify(pub, data, sig); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify(pub, []byte("title: Tampered\n"), sig); err == nil || !strings.Contains(err.Error(), "does not match the pinned public key "+Fingerprint(pub)) {
+		t.Errorf("expected a mismatch error for tampered data, got %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := Verify(otherPub, data, sig); err == nil {
+		t.Errorf("expected a mismatch error for another key")
+	}
+	if err := Verify(pub, data, []byte("not a signature")); err == nil || !strings.Contains(err.Error(), "malformed signature") {
+		t.Errorf("expected a malformed signature error, got %v", err)
+	}
+}
+
+func TestSignaturePath(t *testing.T) {
+	tests := map[string]string{
+		"playbook.yaml":                           "playbook.yaml.sig",
+		"https://hub.example.com/p.yaml":          "https://hub.example.com/p.yaml.sig",
+		"https://hub.example.com/p.yaml?team=ops": "https://hub.example.com/p.yaml.sig?team=ops",
+	}
+	for location, want := range tests {
+		if got := SignaturePath(location); got != want {
+			t.Errorf("SignaturePath(%q) = %q; want %q", location, got, want)
+		}
+	}
+}
+
+func TestKeyFormats(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "key.pem")
+	pubPath := filepath.Join(dir, "key.pub.pem")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600)
+	os.WriteFile(pubPath, []byte(pubPEM), 0644)
+
+	loaded, err := LoadPrivateKey(privPath)
+	if err != nil || !loaded.Equal(priv) {
+		t.Errorf("LoadPrivateKey() = %v, %v", loaded, err)
+	}
+	if _, err := LoadPrivateKey(pubPath); err == nil {
+		t.Errorf("expected an error loading a public key as private key")
+	}
+
+	for name, s := range map[string]string{
+		"PEM":        pubPEM,
+		"base64 DER": base64.StdEncoding.EncodeToString(pubDER),
+		"base64 raw": base64.StdEncoding.EncodeToString(pub) + "\n",
+	} {
+		key, err := ParsePublicKey(s)
+		if err != nil || !key.Equal(pub) {
+			t.Errorf("ParsePublicKey(%s) = %v, %v", name, key, err)
+		}
+	}
+	if key, err := LoadPublicKey(pubPath); err != nil || !key.Equal(pub) {
+		t.Errorf("LoadPublicKey() = %v, %v", key, err)
+	}
+	if _, err := ParsePublicKey("not a key!"); err == nil {
+		t.Errorf("expected an error for an invalid public key")
+	}
+
+	sigPath, err := SignFile(priv, pubPath)
+	if err != nil || sigPath != pubPath+".sig" {
+		t.Fatalf("SignFile() = %q, %v", sigPath, err)
+	}
+	sig, _ := os.ReadFile(sigPath)
+	if err := Verify(pub, []byte(pubPEM), sig); err != nil {
+		t.Errorf("signature written by SignFile does not verify: %v", err)
+	}
+}
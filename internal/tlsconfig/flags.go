@@ -0,0 +1,18 @@
+package tlsconfig
+
+import (
+	"flag"
+
+	"github.com/benedictjohannes/crobe/internal/listflags"
+)
+
+// AddFlags defines the TLS flags of remote playbook, report and enrollment servers, and
+// returns the options they set once parsed.
+func AddFlags(flags *flag.FlagSet) *Options {
+	var o Options
+	flags.StringVar(&o.CABundle, "ca-bundle", "", "PEM file of the CAs trusted by remote playbook and report servers (replaces the system roots)")
+	flags.StringVar(&o.ClientCert, "client-cert", "", "PEM client certificate presented to remote playbook and report servers for mutual TLS (requires --client-key)")
+	flags.StringVar(&o.ClientKey, "client-key", "", "PEM private key of --client-cert")
+	flags.Var((*listflags.ListFlags)(&o.Pins), "pin", "Base64 SHA-256 hash of the public key (SPKI) of a certificate that remote playbook and report servers must present (comma-separated, or specify multiple times)")
+	return &o
+}
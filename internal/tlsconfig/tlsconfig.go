@@ -0,0 +1,118 @@
+// Package tlsconfig builds the HTTPS clients used to fetch playbooks and submit reports, with
+// an optional CA bundle, client certificate (mutual TLS) and public key pinning.
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options configure the TLS connections to a server. The zero value uses the system roots.
+type Options struct {
+	// CABundle is a PEM file of the CAs trusted to sign the server certificate. It replaces
+	// the system roots.
+	CABundle string
+	// ClientCert and ClientKey are PEM files of the client certificate presented for mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// Pins are base64 SHA-256 hashes of the SubjectPublicKeyInfo of a certificate in the
+	// server's verified chain (optionally prefixed with "sha256//"). The connection is refused unless
+	// one of them matches.
+	Pins []string
+}
+
+// IsEmpty reports whether the options leave the default TLS configuration unchanged.
+func (o Options) IsEmpty() bool {
+	return o.CABundle == "" && o.ClientCert == "" && o.ClientKey == "" && len(o.Pins) == 0
+}
+
+// Override returns o with every option set in override replacing its own.
+func (o Options) Override(override Options) Options {
+	if override.CABundle != "" {
+		o.CABundle = override.CABundle
+	}
+	if override.ClientCert != "" || override.ClientKey != "" {
+		o.ClientCert, o.ClientKey = override.ClientCert, override.ClientKey
+	}
+	if len(override.Pins) > 0 {
+		o.Pins = override.Pins
+	}
+	return o
+}
+
+// Config loads the files of the options into a TLS configuration.
+func (o Options) Config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if o.CABundle != "" {
+		data, err := os.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificate found in CA bundle %s", o.CABundle)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return nil, fmt.Errorf("a client certificate and its key must be given together")
+	}
+	if o.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(o.Pins) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range o.Pins {
+			hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256//"))
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin '%s': expected a base64 SHA-256 hash", pin)
+			}
+			pins[string(hash)] = true
+		}
+		// Runs after the regular chain verification, which pinning does not replace. Only the
+		// verified chains count: the server can send any extra certificate along with its own.
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if pins[string(hash[:])] {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("no certificate of %s matches a pinned public key", cs.ServerName)
+		}
+	}
+	return cfg, nil
+}
+
+// NewClient returns an HTTP client using the options. Without options, it uses the default
+// transport.
+func NewClient(o Options, timeout time.Duration) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+	if o.IsEmpty() {
+		return client, nil
+	}
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	client.Transport = transport
+	return client, nil
+}
@@ -0,0 +1,210 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"flag"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert creates a CA and a client certificate signed by it in dir, and returns
+// the CA pool with the paths of the client certificate and key.
+func writeClientCert(t *testing.T, dir string) (*x509.CertPool, string, string) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "probe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}), 0644)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return pool, certPath, keyPath
+}
+
+func TestNewClient_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCAs, certPath, keyPath := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	hash := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	get := func(o Options) (string, error) {
+		client, err := NewClient(o, 5*time.Second)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := get(Options{CABundle: caPath, ClientCert: certPath, ClientKey: keyPath, Pins: []string{otherPin, "sha256//" + pin}}); err != nil || body != "hello probe" {
+		t.Errorf("mutual TLS request = %q, %v", body, err)
+	}
+	if _, err := get(Options{CABundle: caPath}); err == nil {
+		t.Errorf("expected the server to refuse a client without certificate")
+	}
+	if _, err := get(Options{ClientCert: certPath, ClientKey: keyPath}); err == nil {
+		t.Errorf("expected an unknown authority error without the CA bundle")
+	}
+	if _, err := get(Options{CABundle: caPath, ClientCert: certPath, ClientKey: keyPath, Pins: []string{otherPin}}); err == nil || !strings.Contains(err.Error(), "matches a pinned public key") {
+		t.Errorf("expected a pinning error, got %v", err)
+	}
+}
+
+func TestNewClient_PinUnverifiedCertificate(t *testing.T) {
+	// A certificate the server sends but that is not part of the verified chain
+	extraKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	extraTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Pinned Server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	extraDER, err := x509.CreateCertificate(rand.Reader, extraTemplate, extraTemplate, &extraKey.PublicKey, extraKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extraCert, _ := x509.ParseCertificate(extraDER)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.StartTLS()
+	defer server.Close()
+	cert := server.TLS.Certificates[0]
+	cert.Certificate = append(cert.Certificate, extraDER)
+	server.TLS.Certificates = []tls.Certificate{cert}
+
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	hash := sha256.Sum256(extraCert.RawSubjectPublicKeyInfo)
+
+	client, err := NewClient(Options{CABundle: caPath, Pins: []string{base64.StdEncoding.EncodeToString(hash[:])}}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "matches a pinned public key") {
+		t.Errorf("expected a pinning error for a certificate outside the verified chain, got %v", err)
+	}
+}
+
+func TestOptions_Config(t *testing.T) {
+	dir := t.TempDir()
+	_, certPath, keyPath := writeClientCert(t, dir)
+
+	if cfg, err := (Options{}).Config(); err != nil || cfg.RootCAs != nil {
+		t.Errorf("empty options should keep the system roots, got %v", err)
+	}
+	tests := []struct {
+		name string
+		o    Options
+		want string
+	}{
+		{"Missing CA bundle", Options{CABundle: filepath.Join(dir, "none.pem")}, "failed to read CA bundle"},
+		{"Empty CA bundle", Options{CABundle: keyPath}, "no PEM certificate found"},
+		{"Cert without key", Options{ClientCert: certPath}, "must be given together"},
+		{"Mismatched key", Options{ClientCert: keyPath, ClientKey: keyPath}, "failed to load client certificate"},
+		{"Invalid pin", Options{Pins: []string{"abc"}}, "invalid pin 'abc'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.o.Config()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Config() error = %v, want it to contain %q", err, tt.want)
+			}
+			if _, err := NewClient(tt.o, time.Second); err == nil {
+				t.Errorf("NewClient() should fail like Config()")
+			}
+		})
+	}
+}
+
+func TestOptions_Override(t *testing.T) {
+	base := Options{CABundle: "playbook-ca.pem", ClientCert: "a.pem", ClientKey: "a.key", Pins: []string{"p1"}}
+
+	if got := base.Override(Options{}); got.CABundle != "playbook-ca.pem" || got.ClientCert != "a.pem" || got.Pins[0] != "p1" {
+		t.Errorf("empty override changed options: %+v", got)
+	}
+	got := base.Override(Options{CABundle: "flag-ca.pem", ClientCert: "b.pem", ClientKey: "b.key", Pins: []string{"p2"}})
+	if got.CABundle != "flag-ca.pem" || got.ClientCert != "b.pem" || got.ClientKey != "b.key" || got.Pins[0] != "p2" {
+		t.Errorf("override not applied: %+v", got)
+	}
+	if !(Options{}).IsEmpty() || base.IsEmpty() {
+		t.Errorf("IsEmpty() is wrong")
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := AddFlags(flags)
+	if err := flags.Parse([]string{"--ca-bundle", "ca.pem", "--client-cert", "c.pem", "--client-key", "c.key", "--pin", "p1,p2", "--pin", "p3"}); err != nil {
+		t.Fatal(err)
+	}
+	if o.CABundle != "ca.pem" || o.ClientCert != "c.pem" || o.ClientKey != "c.key" || strings.Join(o.Pins, ",") != "p1,p2,p3" {
+		t.Errorf("parsed options = %+v", *o)
+	}
+}
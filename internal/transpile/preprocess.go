@@ -48,6 +48,9 @@ func BakeFile(inputPath, outputPath string) error {
 // (funcFile, shellFuncFile) into inlined JavaScript code.
 func Preprocess(config *playbook.Playbook, baseDir string) error {
 	for i := range config.Sections {
+		if err := processWhen(&config.Sections[i].When, &config.Sections[i].WhenFile, baseDir); err != nil {
+			return err
+		}
 		for j := range config.Sections[i].Assertions {
 			if err := processAssertion(&config.Sections[i].Assertions[j], baseDir); err != nil {
 				return err
@@ -58,6 +61,9 @@ func Preprocess(config *playbook.Playbook, baseDir string) error {
 }
 
 func processAssertion(a *playbook.Assertion, baseDir string) error {
+	if err := processWhen(&a.When, &a.WhenFile, baseDir); err != nil {
+		return err
+	}
 	for i := range a.PreCmds {
 		if err := processExec(&a.PreCmds[i], baseDir); err != nil {
 			return err
@@ -79,12 +85,17 @@ func processAssertion(a *playbook.Assertion, baseDir string) error {
 			return err
 		}
 	}
+	if a.Remediation != nil {
+		if err := processExec(&a.Remediation.Exec, baseDir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func processExec(e *playbook.Exec, baseDir string) error {
 	if e.ShellFuncFile != "" {
-		code, err := Transpile(filepath.Join(baseDir, e.ShellFuncFile))
+		code, err := Transpile(resolvePath(baseDir, e.ShellFuncFile))
 		if err != nil {
 			return fmt.Errorf("transpilation error for shellFuncFile (%s): %v", e.ShellFuncFile, err)
 		}
@@ -92,7 +103,7 @@ func processExec(e *playbook.Exec, baseDir string) error {
 		e.ShellFuncFile = ""
 	}
 	if e.FuncFile != "" {
-		code, err := Transpile(filepath.Join(baseDir, e.FuncFile))
+		code, err := Transpile(resolvePath(baseDir, e.FuncFile))
 		if err != nil {
 			return fmt.Errorf("transpilation error for funcFile (%s): %v", e.FuncFile, err)
 		}
@@ -101,7 +112,7 @@ func processExec(e *playbook.Exec, baseDir string) error {
 	}
 	for i := range e.Gather {
 		if e.Gather[i].FuncFile != "" {
-			code, err := Transpile(filepath.Join(baseDir, e.Gather[i].FuncFile))
+			code, err := Transpile(resolvePath(baseDir, e.Gather[i].FuncFile))
 			if err != nil {
 				return fmt.Errorf("transpilation error for gather funcFile (%s): %v", e.Gather[i].FuncFile, err)
 			}
@@ -114,7 +125,7 @@ func processExec(e *playbook.Exec, baseDir string) error {
 
 func processEvalRule(r *playbook.EvaluationRule, baseDir string) error {
 	if r.FuncFile != "" {
-		code, err := Transpile(filepath.Join(baseDir, r.FuncFile))
+		code, err := Transpile(resolvePath(baseDir, r.FuncFile))
 		if err != nil {
 			return fmt.Errorf("transpilation error for evaluationRule funcFile (%s): %v", r.FuncFile, err)
 		}
@@ -123,3 +134,24 @@ func processEvalRule(r *playbook.EvaluationRule, baseDir string) error {
 	}
 	return nil
 }
+
+func processWhen(when *string, whenFile *string, baseDir string) error {
+	if *whenFile != "" {
+		code, err := Transpile(resolvePath(baseDir, *whenFile))
+		if err != nil {
+			return fmt.Errorf("transpilation error for whenFile (%s): %v", *whenFile, err)
+		}
+		*when = code
+		*whenFile = ""
+	}
+	return nil
+}
+
+// resolvePath resolves a JS/TS file path against baseDir. Absolute paths, such as those of
+// files referenced by included libraries, are kept.
+func resolvePath(baseDir string, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(baseDir, file)
+}
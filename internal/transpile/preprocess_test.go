@@ -254,6 +254,52 @@ sections:
 	}
 }
 
+func TestBakeFile_Includes(t *testing.T) {
+	tmpDir := t.TempDir()
+	libDir := filepath.Join(tmpDir, "libs")
+	if err := os.MkdirAll(filepath.Join(libDir, "scripts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(tmpDir, "raw.yaml"): `
+title: "Include Test"
+sections:
+  - include: libs/os.yaml
+`,
+		filepath.Join(libDir, "os.yaml"): `
+sections:
+  - title: "OS"
+    assertions:
+      - code: OS_01
+        title: "Generated"
+        cmds:
+          - exec:
+              funcFile: scripts/gen.ts
+`,
+		filepath.Join(libDir, "scripts", "gen.ts"): `export default () => "echo from-library";`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputPath := filepath.Join(tmpDir, "baked.yaml")
+	if err := BakeFile(filepath.Join(tmpDir, "raw.yaml"), outputPath); err != nil {
+		t.Fatalf("BakeFile failed: %v", err)
+	}
+	baked, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(baked), "include") || strings.Contains(string(baked), "funcFile") {
+		t.Errorf("expected a flattened playbook, got:\n%s", baked)
+	}
+	if !strings.Contains(string(baked), "OS_01") || !strings.Contains(string(baked), "from-library") {
+		t.Errorf("expected the library's assertion with its script inlined, got:\n%s", baked)
+	}
+}
+
 func TestBakeFileErrors(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -333,8 +379,10 @@ func TestPreprocessFullAssertion(t *testing.T) {
 	config := &playbook.Playbook{
 		Sections: []playbook.Section{
 			{
+				WhenFile: "script.ts",
 				Assertions: []playbook.Assertion{
 					{
+						WhenFile: "script.ts",
 						PreCmds: []playbook.Exec{
 							{FuncFile: "script.ts"},
 						},
@@ -353,6 +401,10 @@ func TestPreprocessFullAssertion(t *testing.T) {
 						PostCmds: []playbook.Exec{
 							{FuncFile: "script.ts"},
 						},
+						Remediation: &playbook.Remediation{
+							Description: "Fix",
+							Exec:        playbook.Exec{FuncFile: "script.ts"},
+						},
 					},
 				},
 			},
@@ -365,6 +417,14 @@ func TestPreprocessFullAssertion(t *testing.T) {
 
 	a := config.Sections[0].Assertions[0]
 
+	// Verify When
+	if config.Sections[0].When == "" || config.Sections[0].WhenFile != "" {
+		t.Error("Section: When should be populated and WhenFile cleared")
+	}
+	if a.When == "" || a.WhenFile != "" {
+		t.Error("Assertion: When should be populated and WhenFile cleared")
+	}
+
 	// Verify PreCmds
 	if a.PreCmds[0].Func == "" || a.PreCmds[0].FuncFile != "" {
 		t.Error("PreCmds: Func should be populated and FuncFile cleared")
@@ -389,4 +449,9 @@ func TestPreprocessFullAssertion(t *testing.T) {
 	if a.PostCmds[0].Func == "" || a.PostCmds[0].FuncFile != "" {
 		t.Error("PostCmds: Func should be populated and FuncFile cleared")
 	}
+
+	// Verify Remediation
+	if a.Remediation.Exec.Func == "" || a.Remediation.Exec.FuncFile != "" {
+		t.Error("Remediation: Func should be populated and FuncFile cleared")
+	}
 }
@@ -0,0 +1,83 @@
+package varflags
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/playbook"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes environment variables overriding playbook vars, eg: CROBE_VAR_configPath.
+const EnvPrefix = "CROBE_VAR_"
+
+// VarFlags implements flag.Value to collect "name=value" var overrides from CLI.
+// Specify the flag multiple times for each var; a later value for the same name wins.
+type VarFlags []string
+
+func (v *VarFlags) String() string {
+	return strings.Join(*v, ", ")
+}
+
+func (v *VarFlags) Set(value string) error {
+	name, _, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=value, got '%s'", value)
+	}
+	*v = append(*v, value)
+	return nil
+}
+
+// Overrides collects the overrides of the declared playbook vars, from lowest to highest
+// precedence: CROBE_VAR_<name> environment variables, varFile (YAML or JSON map), then
+// --var flags. Environment variables of vars the playbook does not declare are ignored,
+// so that one environment can serve several playbooks.
+func Overrides(declared map[string]interface{}, environ []string, varFile string, flags VarFlags) (map[string]interface{}, error) {
+	overrides := make(map[string]interface{})
+
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, EnvPrefix)
+		if _, ok := declared[name]; ok {
+			overrides[name] = value
+		}
+	}
+
+	if varFile != "" {
+		data, err := os.ReadFile(varFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read var file: %w", err)
+		}
+		var fileVars map[string]interface{}
+		if err := yaml.Unmarshal(data, &fileVars); err != nil {
+			return nil, fmt.Errorf("failed to parse var file %s: %w", varFile, err)
+		}
+		for name, value := range fileVars {
+			overrides[name] = value
+		}
+	}
+
+	for _, kv := range flags {
+		name, value, _ := strings.Cut(kv, "=")
+		overrides[strings.TrimSpace(name)] = value
+	}
+	return overrides, nil
+}
+
+// Apply overrides the playbook vars from the environment, varFile and --var flags, then
+// interpolates ${vars.name} references.
+func Apply(config *playbook.Playbook, varFile string, flags VarFlags) error {
+	overrides, err := Overrides(config.Vars, os.Environ(), varFile, flags)
+	if err != nil {
+		return err
+	}
+	if err := config.ApplyVars(overrides); err != nil {
+		return err
+	}
+	return config.InterpolateVars()
+}
@@ -0,0 +1,89 @@
+package varflags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestVarFlags_Set(t *testing.T) {
+	var v VarFlags
+
+	if err := v.Set("kernelRegex=^[6-9]\\."); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := v.Set("query=a=b"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := v.Set("novalue"); err == nil {
+		t.Error("expected an error for a var without '='")
+	}
+	if err := v.Set("=value"); err == nil {
+		t.Error("expected an error for a var without a name")
+	}
+
+	if len(v) != 2 {
+		t.Errorf("len(v) = %d, want 2", len(v))
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	declared := map[string]interface{}{"path": "/etc/app.env", "retries": 3, "strict": false, "level": "low"}
+	varFile := filepath.Join(t.TempDir(), "vars.yaml")
+	if err := os.WriteFile(varFile, []byte("retries: 5\nstrict: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	environ := []string{
+		"CROBE_VAR_path=/opt/app.env",
+		"CROBE_VAR_retries=4",
+		"CROBE_VAR_other=ignored",
+		"HOME=/root",
+	}
+	flags := VarFlags{"strict=false", "level=high"}
+
+	overrides, err := Overrides(declared, environ, varFile, flags)
+	if err != nil {
+		t.Fatalf("Overrides failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"path":    "/opt/app.env", // environment
+		"retries": 5,              // var file wins over environment
+		"strict":  "false",        // flag wins over var file
+		"level":   "high",
+	}
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Errorf("overrides = %v, want %v", overrides, expected)
+	}
+
+	if _, err := Overrides(declared, nil, filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil {
+		t.Error("expected an error for a missing var file")
+	}
+	badFile := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(badFile, []byte("- not\n- a map\n"), 0644)
+	if _, err := Overrides(declared, nil, badFile, nil); err == nil {
+		t.Error("expected an error for a var file that is not a map")
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv(EnvPrefix+"path", "/etc/env")
+	config := &playbook.Playbook{
+		Vars: map[string]interface{}{"path": "/opt/app.env", "retries": 3},
+		Sections: []playbook.Section{{
+			Title:      "App",
+			Assertions: []playbook.Assertion{{Code: "APP", Description: "${vars.path} retried ${vars.retries} times"}},
+		}},
+	}
+	if err := Apply(config, "", VarFlags{"retries=5"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got := config.Sections[0].Assertions[0].Description; got != "/etc/env retried 5 times" {
+		t.Errorf("Description = %q", got)
+	}
+	if err := Apply(config, "", VarFlags{"unknown=1"}); err == nil {
+		t.Error("expected an error for an undeclared var")
+	}
+}
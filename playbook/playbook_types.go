@@ -1,15 +1,46 @@
 package playbook
 
+import "time"
+
 type Assertion struct {
-	Code            string `yaml:"code" json:"code" jsonschema:"description=Unique code for the assertion,minLength=3"`
-	Title           string `yaml:"title" json:"title" jsonschema:"description=Title of the assertion,minLength=3"`
-	Description     string `yaml:"description" json:"description" jsonschema:"description=Detailed description of what is being checked,minLength=3"`
-	PreCmds         []Exec `yaml:"preCmds,omitempty" json:"preCmds,omitempty" jsonschema:"description=Executions before main commands. Data gathered here persists for the whole assertion."`
-	Cmds            []Cmd  `yaml:"cmds" json:"cmds" jsonschema:"description=Main command units to execute. At least one required.,minItems=1"`
-	PostCmds        []Exec `yaml:"postCmds,omitempty" json:"postCmds,omitempty" jsonschema:"description=Executions after all main commands settle."`
-	MinPassingScore *int   `yaml:"minPassingScore,omitempty" json:"minPassingScore,omitempty" jsonschema:"description=Minimum score to consider assertion as passed (Default: sum of all cmds' passScores)"`
-	PassDescription string `yaml:"passDescription" json:"passDescription" jsonschema:"description=Message shown if the assertion passes,minLength=3"`
-	FailDescription string `yaml:"failDescription" json:"failDescription" jsonschema:"description=Message shown if the assertion fails,minLength=3"`
+	Code            string       `yaml:"code" json:"code" jsonschema:"description=Unique code for the assertion,minLength=3"`
+	Title           string       `yaml:"title" json:"title" jsonschema:"description=Title of the assertion,minLength=3"`
+	Description     string       `yaml:"description" json:"description" jsonschema:"description=Detailed description of what is being checked,minLength=3"`
+	PreCmds         []Exec       `yaml:"preCmds,omitempty" json:"preCmds,omitempty" jsonschema:"description=Executions before main commands. Data gathered here persists for the whole assertion."`
+	Cmds            []Cmd        `yaml:"cmds" json:"cmds" jsonschema:"description=Main command units to execute. At least one required.,minItems=1"`
+	PostCmds        []Exec       `yaml:"postCmds,omitempty" json:"postCmds,omitempty" jsonschema:"description=Executions after all main commands settle."`
+	MinPassingScore *int         `yaml:"minPassingScore,omitempty" json:"minPassingScore,omitempty" jsonschema:"description=Minimum score to consider assertion as passed (Default: sum of all cmds' passScores)"`
+	PassDescription string       `yaml:"passDescription" json:"passDescription" jsonschema:"description=Message shown if the assertion passes,minLength=3"`
+	FailDescription string       `yaml:"failDescription" json:"failDescription" jsonschema:"description=Message shown if the assertion fails,minLength=3"`
+	Remediation     *Remediation `yaml:"remediation,omitempty" json:"remediation,omitempty" jsonschema:"description=Fix for a failed assertion. Only ever executed when the agent runs with --remediate=apply (--remediate=dry-run shows what would run)."`
+	Platforms       []Platform   `yaml:"platforms,omitempty" json:"platforms,omitempty" jsonschema:"description=Platforms the assertion applies to (linux|mac|windows). On other platforms it is reported as not applicable without running anything. Default: all platforms.,enum=linux,enum=mac,enum=windows"`
+	When            string       `yaml:"when,omitempty" json:"when,omitempty" jsonschema:"description=Embedded JS predicate deciding whether the assertion applies. Evaluated before any preCmds; a falsy result reports the assertion as not applicable. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd\\, vars }) => boolean."`
+	WhenFile        string       `yaml:"whenFile,omitempty" json:"whenFile,omitempty" jsonschema:"description=Path to JS/TS file for when. BUILDER ONLY: using this in real playbook will cause error."`
+	Controls        []ControlRef `yaml:"controls,omitempty" json:"controls,omitempty" jsonschema:"description=Compliance framework controls this assertion provides evidence for (eg: CIS 5.2.10\\, NIST 800-53 AC-6)."`
+	Tags            []string     `yaml:"tags,omitempty" json:"tags,omitempty" jsonschema:"description=Free-form labels used to select assertions from the command line (--tags\\, --exclude-tags)."`
+	Severity        Severity     `yaml:"severity,omitempty" json:"severity,omitempty" jsonschema:"description=How bad a failure of this assertion is. Drives its default weight in the overall score (info: 0\\, low: 1\\, medium: 2\\, high: 4\\, critical: 8).,default=medium,enum=info,enum=low,enum=medium,enum=high,enum=critical"`
+	Weight          *int         `yaml:"weight,omitempty" json:"weight,omitempty" jsonschema:"description=Weight of the assertion in the overall score (Default: derived from severity). 0 excludes it from scoring.,minimum=0"`
+	Serial          bool         `yaml:"serial,omitempty" json:"serial,omitempty" jsonschema:"description=Run this assertion alone: it waits for running assertions to finish and nothing else starts until it is done. Use for checks that are disturbed by concurrent load."`
+	Include         string       `yaml:"include,omitempty" json:"include,omitempty" jsonschema:"description=Path or HTTPS URL of a library file. This entry is replaced by the assertions of the library. Relative paths resolve against the including file. An include entry defines nothing else."`
+	// Source is the library the assertion was included from, if any. Used in error messages.
+	Source string `yaml:"-" json:"-"`
+}
+
+// GetSeverity returns the severity of the assertion, defaulting to medium.
+func (a Assertion) GetSeverity() Severity {
+	if a.Severity == "" {
+		return SeverityMedium
+	}
+	return a.Severity
+}
+
+// GetWeight returns the weight of the assertion in the overall score: its own weight, or
+// the default weight of its severity.
+func (a Assertion) GetWeight() int {
+	if a.Weight != nil {
+		return *a.Weight
+	}
+	return severityWeights[a.GetSeverity()]
 }
 
 func (a Assertion) GetMinPassingScore() int {
@@ -54,20 +85,26 @@ func (c Cmd) GetFailScore() int {
 
 type Exec struct {
 	Shell               string       `yaml:"shell,omitempty" json:"shell,omitempty" jsonschema:"description=Shell/Interpreter to use (eg: bash\\, powershell\\, sh). Optional. Defaults: pwsh (windows\\, falls back to powershell if not found)\\, zsh (mac)\\, bash (linux and others). Note that for bash and powershell\\, set -o pipefail and $ErrorActionPreference = 'Stop' is added to catch execution errors. Non-shell interpreters like python3 or node are supported. Set to ! to directly execute the script as cmd and args\\, executed directly (eg: ls -lah)."`
-	ShellFunc           string       `yaml:"shellFunc,omitempty" json:"shellFunc,omitempty" jsonschema:"description=Embedded JS code that returns the shell to use. Takes precedence over shell. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd }) => string."`
+	ShellFunc           string       `yaml:"shellFunc,omitempty" json:"shellFunc,omitempty" jsonschema:"description=Embedded JS code that returns the shell to use. Takes precedence over shell. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd\\, vars }) => string."`
 	ShellFuncFile       string       `yaml:"shellFuncFile,omitempty" json:"shellFuncFile,omitempty" jsonschema:"description=Path to JS/TS file for shellFunc. BUILDER ONLY."`
 	Script              string       `yaml:"script,omitempty" json:"script,omitempty" jsonschema:"description=Script to execute."`
 	ScriptFileExtension string       `yaml:"scriptFileExtension,omitempty" json:"scriptFileExtension,omitempty" jsonschema:"description=Extension for the temporary script file (eg: sh\\, ps1\\, py\\, js). Optional. If not specified\\, defaults to 'sh' for bash/sh/zsh\\, 'ps1' for powershell/pwsh\\, and empty for others."`
-	Func                string       `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=Embedded JS code that returns the script to be executed. Takes precedence over script. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd }) => string."`
+	Func                string       `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=Embedded JS code that returns the script to be executed. Takes precedence over script. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd\\, vars }) => string."`
 	FuncFile            string       `yaml:"funcFile,omitempty" json:"funcFile,omitempty" jsonschema:"description=Path to JS/TS file. BUILDER ONLY: using this in real playbook will cause error."`
 	Gather              []GatherSpec `yaml:"gather,omitempty" json:"gather,omitempty" jsonschema:"description=Data extraction specs"`
 	ExcludeFromReport   bool         `yaml:"excludeFromReport,omitempty" json:"excludeFromReport,omitempty" jsonschema:"description=Hide stdout/stderr results from log and markdown report"`
+	Timeout             string       `yaml:"timeout,omitempty" json:"timeout,omitempty" jsonschema:"description=Maximum execution time as a duration (eg: 30s\\, 5m). Defaults to the playbook's defaultTimeout. When exceeded\\, the whole process tree is terminated and the cmd is scored with its failScore."`
+}
+
+// GetTimeout returns the parsed timeout of the execution, or 0 if none is set.
+func (e Exec) GetTimeout() time.Duration {
+	return parseDuration(e.Timeout)
 }
 
 type EvaluationRule struct {
 	Regex         string `yaml:"regex,omitempty" json:"regex,omitempty" jsonschema:"description=Regex to match against output"`
 	IncludeStdErr *bool  `yaml:"includeStdErr,omitempty" json:"includeStdErr,omitempty" jsonschema:"description=Include stderr in regex evaluation,default=false"`
-	Func          string `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=JS function for evaluation. Takes precedence over regex. Signature: (stdout\\, stderr\\, assertionContext) => -1 | 0 | 1"`
+	Func          string `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=JS function for evaluation. Takes precedence over regex. Signature: (stdout\\, stderr\\, assertionContext\\, vars) => -1 | 0 | 1"`
 	FuncFile      string `yaml:"funcFile,omitempty" json:"funcFile,omitempty" jsonschema:"description=Path to JS/TS file. BUILDER ONLY: using this in real playbook will cause error."`
 }
 
@@ -83,7 +120,7 @@ type GatherSpec struct {
 	ExcludeFromReport bool   `yaml:"excludeFromReport,omitempty" json:"excludeFromReport,omitempty" jsonschema:"description=Hide key from JSON report"`
 	Regex             string `yaml:"regex,omitempty" json:"regex,omitempty" jsonschema:"description=Regex to extract data"`
 	IncludeStdErr     *bool  `yaml:"includeStdErr,omitempty" json:"includeStdErr,omitempty" jsonschema:"description=Include stderr in regex evaluation,default=false"`
-	Func              string `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=JS function for extraction. Takes precedence over regex. Signature: (stdout\\, stderr\\, assertionContext) => string"`
+	Func              string `yaml:"func,omitempty" json:"func,omitempty" jsonschema:"description=JS function for extraction. Takes precedence over regex. Signature: (stdout\\, stderr\\, assertionContext\\, vars) => string"`
 	FuncFile          string `yaml:"funcFile,omitempty" json:"funcFile,omitempty" jsonschema:"description=Path to JS/TS file. BUILDER ONLY: using this in real playbook will cause error."`
 }
 
@@ -105,12 +142,79 @@ type ReportDestination string
 const (
 	ReportDestinationFolder ReportDestination = "folder"
 	ReportDestinationHTTPS  ReportDestination = "https"
+	// ReportDestinationPrometheus writes metrics for the textfile collector of node_exporter.
+	ReportDestinationPrometheus ReportDestination = "prometheus"
 )
 
 type Section struct {
 	Title       string      `yaml:"title" json:"title" jsonschema:"description=Title of the section,minLength=3"`
 	Description []string    `yaml:"description" json:"description" jsonschema:"description=List of descriptions for the section,minItems=1"`
 	Assertions  []Assertion `yaml:"assertions" json:"assertions" jsonschema:"description=List of assertions within this section,minItems=1"`
+	Platforms   []Platform  `yaml:"platforms,omitempty" json:"platforms,omitempty" jsonschema:"description=Platforms the whole section applies to (linux|mac|windows). On other platforms all its assertions are reported as not applicable. Default: all platforms.,enum=linux,enum=mac,enum=windows"`
+	When        string      `yaml:"when,omitempty" json:"when,omitempty" jsonschema:"description=Embedded JS predicate deciding whether the whole section applies. A falsy result reports all its assertions as not applicable. Signature: ({ assertionContext\\, env\\, os\\, arch\\, user\\, cwd\\, vars }) => boolean."`
+	WhenFile    string      `yaml:"whenFile,omitempty" json:"whenFile,omitempty" jsonschema:"description=Path to JS/TS file for when. BUILDER ONLY: using this in real playbook will cause error."`
+	Include     string      `yaml:"include,omitempty" json:"include,omitempty" jsonschema:"description=Path or HTTPS URL of a library file. This entry is replaced by the sections of the library. Relative paths resolve against the including file. An include entry defines nothing else."`
+}
+
+// Library is a file of reusable sections and assertions, pulled into playbooks by include
+// entries: a section include takes its sections, an assertion include takes its assertions.
+type Library struct {
+	Sections   []Section   `yaml:"sections,omitempty" json:"sections,omitempty"`
+	Assertions []Assertion `yaml:"assertions,omitempty" json:"assertions,omitempty"`
+}
+
+// Remediation fixes the condition checked by an assertion. With --remediate=apply, it runs
+// after the assertion fails, and the assertion's cmds are then re-run to verify the fix.
+type Remediation struct {
+	Description string `yaml:"description" json:"description" jsonschema:"description=What the remediation changes on the system,minLength=3"`
+	Exec        Exec   `yaml:"exec" json:"exec" jsonschema:"description=Execution that applies the fix. Values gathered by the assertion are available to func and shellFunc."`
+}
+
+// ControlRef maps an assertion to a control of a compliance framework.
+type ControlRef struct {
+	Framework string `yaml:"framework" json:"framework" jsonschema:"description=Name of the framework (eg: CIS Ubuntu 22.04\\, NIST 800-53\\, ISO 27001),minLength=1"`
+	ID        string `yaml:"id" json:"id" jsonschema:"description=Control identifier within the framework (eg: 5.2.10\\, AC-6\\, A.8.2),minLength=1"`
+	URL       string `yaml:"url,omitempty" json:"url,omitempty" jsonschema:"description=Link to the control text,format=uri"`
+}
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+var severityWeights = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     4,
+	SeverityCritical: 8,
+}
+
+type Platform string
+
+const (
+	PlatformLinux   Platform = "linux"
+	PlatformMac     Platform = "mac"
+	PlatformWindows Platform = "windows"
+)
+
+// AppliesTo reports whether os (as named in playbooks: linux, mac, windows) is one of
+// platforms. An empty list applies to every platform.
+func AppliesTo(platforms []Platform, os string) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+	for _, p := range platforms {
+		if string(p) == os {
+			return true
+		}
+	}
+	return false
 }
 
 type ReportFormat string
@@ -120,18 +224,92 @@ const (
 	ReportFormatJSON      ReportFormat = "json"
 )
 
+// OutputFormat is an additional format the report is rendered in, next to its JSON,
+// markdown and log files.
+type OutputFormat string
+
+const (
+	// OutputSARIF renders failures as SARIF 2.1.0 results, for code-scanning UIs.
+	OutputSARIF OutputFormat = "sarif"
+	// OutputJUnit renders sections as JUnit XML testsuites, for CI pipelines.
+	OutputJUnit OutputFormat = "junit"
+	// OutputHTML renders a self-contained HTML page, for readers without a markdown viewer.
+	OutputHTML OutputFormat = "html"
+	// OutputOSCAL renders an OSCAL assessment-results document, for GRC platforms.
+	OutputOSCAL OutputFormat = "oscal"
+	// OutputXCCDF renders an XCCDF 1.2 benchmark and TestResult, for SCAP tooling.
+	OutputXCCDF OutputFormat = "xccdf"
+	// OutputOpenMetrics renders the metrics of the prometheus destination in the OpenMetrics
+	// text format, for scrapers and push gateways.
+	OutputOpenMetrics OutputFormat = "openmetrics"
+)
+
 type ReportDestinationConfig struct {
 	URL               string            `yaml:"url" json:"url" jsonschema:"description=URL to post report content to"`
 	Format            ReportFormat      `yaml:"format,omitempty" json:"format,omitempty" jsonschema:"description=Format of the report (json|multipart),default=multipart,enum=multipart,enum=json"`
-	SignatureSecret   string            `yaml:"signatureSecret,omitempty" json:"signatureSecret,omitempty" jsonschema:"description=Secret for HMAC-SHA256 signature"`
+	SignatureSecret   string            `yaml:"signatureSecret,omitempty" json:"signatureSecret,omitempty" jsonschema:"description=Secret for the legacy HMAC-SHA256 signature. Shipped with the playbook: prefer device keys (crobe enroll)."`
 	AdditionalHeaders map[string]string `yaml:"additionalHeaders,omitempty" json:"additionalHeaders,omitempty" jsonschema:"description=Custom headers for the request"`
+	CABundle          string            `yaml:"caBundle,omitempty" json:"caBundle,omitempty" jsonschema:"description=PEM file of the CAs trusted to sign the server certificate. Replaces the system roots. Overridden by --ca-bundle."`
+	ClientCert        string            `yaml:"clientCert,omitempty" json:"clientCert,omitempty" jsonschema:"description=PEM client certificate presented for mutual TLS. Requires clientKey. Overridden by --client-cert."`
+	ClientKey         string            `yaml:"clientKey,omitempty" json:"clientKey,omitempty" jsonschema:"description=PEM private key of clientCert. Overridden by --client-key."`
+	IncludeOutputs    bool              `yaml:"includeOutputs,omitempty" json:"includeOutputs,omitempty" jsonschema:"description=Also submit the report in the additional formats of reportOutputs (eg: report.sarif)."`
+	Pins              []string          `yaml:"pins,omitempty" json:"pins,omitempty" jsonschema:"description=Base64 SHA-256 hashes of the SubjectPublicKeyInfo of a certificate in the server chain. The submission is refused unless one matches. Overridden by --pin."`
+	// Nonce is the nonce the server sent along with the playbook, signed with the submitted
+	// report. Set by the loader, never serialized.
+	Nonce string `yaml:"-" json:"-"`
 }
 
 type Playbook struct {
-	Title                   string                   `yaml:"title" json:"title" jsonschema:"description=Title of the report,minLength=3"`
-	ReportFrontmatter       map[string]interface{}   `yaml:"reportFrontmatter,omitempty" json:"reportFrontmatter,omitempty" jsonschema:"description=Custom metadata merged into the generated markdown report frontmatter."`
-	Sections                []Section                `yaml:"sections" json:"sections" jsonschema:"description=Logical groups of assertions.,minItems=1"`
-	ReportDestination       ReportDestination        `yaml:"reportDestination,omitempty" json:"reportDestination,omitempty" jsonschema:"description=Destination for the report (folder|https),default=folder,enum=folder,enum=https"`
-	ReportDestinationFolder string                   `yaml:"reportDestinationFolder,omitempty" json:"reportDestinationFolder,omitempty" jsonschema:"description=Folder path if reportDestination is 'folder'. Defaults to 'reports'."`
-	ReportDestinationHTTPS  *ReportDestinationConfig `yaml:"reportDestinationHttps,omitempty" json:"reportDestinationHttps,omitempty" jsonschema:"description=Required if reportDestination is 'https'."`
+	Title                       string                   `yaml:"title" json:"title" jsonschema:"description=Title of the report,minLength=3"`
+	ReportFrontmatter           map[string]interface{}   `yaml:"reportFrontmatter,omitempty" json:"reportFrontmatter,omitempty" jsonschema:"description=Custom metadata merged into the generated markdown report frontmatter."`
+	Sections                    []Section                `yaml:"sections" json:"sections" jsonschema:"description=Logical groups of assertions.,minItems=1"`
+	ReportDestination           ReportDestination        `yaml:"reportDestination,omitempty" json:"reportDestination,omitempty" jsonschema:"description=Destination for the report (folder|https|prometheus),default=folder,enum=folder,enum=https,enum=prometheus"`
+	ReportDestinationFolder     string                   `yaml:"reportDestinationFolder,omitempty" json:"reportDestinationFolder,omitempty" jsonschema:"description=Folder path if reportDestination is 'folder'. Defaults to 'reports'."`
+	ReportDestinationHTTPS      *ReportDestinationConfig `yaml:"reportDestinationHttps,omitempty" json:"reportDestinationHttps,omitempty" jsonschema:"description=Required if reportDestination is 'https'."`
+	ReportDestinationPrometheus string                   `yaml:"reportDestinationPrometheus,omitempty" json:"reportDestinationPrometheus,omitempty" jsonschema:"description=Path of the .prom file written if reportDestination is 'prometheus'\\, in the directory of the node_exporter textfile collector (eg: /var/lib/node_exporter/textfile_collector/crobe.prom). Required if reportDestination is 'prometheus'."`
+	ReportOutputs               []OutputFormat           `yaml:"reportOutputs,omitempty" json:"reportOutputs,omitempty" jsonschema:"description=Additional formats the report is rendered in (sarif\\, junit\\, html\\, oscal\\, xccdf\\, openmetrics)\\, written next to the JSON\\, markdown and log files. Submitted too with reportDestinationHttps.includeOutputs. Overridden by --output.,enum=sarif,enum=junit,enum=html,enum=oscal,enum=xccdf,enum=openmetrics"`
+	ReportRecipients            []string                 `yaml:"reportRecipients,omitempty" json:"reportRecipients,omitempty" jsonschema:"description=age public keys (age1...) that the report files are encrypted to\\, in the reports folder and in submissions. Any of them can decrypt the reports (crobe decrypt). Overridden by --recipient."`
+	DefaultTimeout              string                   `yaml:"defaultTimeout,omitempty" json:"defaultTimeout,omitempty" jsonschema:"description=Default timeout for every execution that does not specify its own (eg: 2m). Empty means no timeout."`
+	RunTimeout                  string                   `yaml:"runTimeout,omitempty" json:"runTimeout,omitempty" jsonschema:"description=Deadline for the whole playbook run (eg: 30m). Executions still running when it passes are terminated and scored as timed out."`
+	Concurrency                 int                      `yaml:"concurrency,omitempty" json:"concurrency,omitempty" jsonschema:"description=Maximum number of assertions executed at the same time (Default: 1\\, sequential). Overridden by the --parallel flag.,minimum=1"`
+	Vars                        map[string]interface{}   `yaml:"vars,omitempty" json:"vars,omitempty" jsonschema:"description=Variables with their default values (string\\, number or boolean). Referenced as ${vars.name} in scripts\\, regexes and descriptions\\, and available to every JS function as vars. Overridden by CROBE_VAR_<name> environment variables\\, then --var-file\\, then --var name=value."`
+	// CachedCopies lists the remote files of the playbook that were loaded from the local
+	// cache because their server could not be reached. Set by the loader, never serialized.
+	CachedCopies []CachedCopy `yaml:"-" json:"-"`
+}
+
+// CachedCopy is a remote playbook file loaded from the cache instead of its server.
+type CachedCopy struct {
+	Location string `json:"location"`
+	// FetchedAt is when the server last served or confirmed the cached copy.
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// GetConcurrency returns the number of assertions that may run at the same time (at least 1).
+func (p Playbook) GetConcurrency() int {
+	if p.Concurrency < 1 {
+		return 1
+	}
+	return p.Concurrency
+}
+
+func (p Playbook) GetDefaultTimeout() time.Duration {
+	return parseDuration(p.DefaultTimeout)
+}
+
+func (p Playbook) GetRunTimeout() time.Duration {
+	return parseDuration(p.RunTimeout)
+}
+
+// parseDuration parses a duration string, treating empty or invalid values as 0.
+// Invalid values are rejected earlier by ValidateConfig.
+func parseDuration(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
 }
@@ -2,6 +2,7 @@ package playbook
 
 import (
 	"testing"
+	"time"
 )
 
 func TestAssertion_GetMinPassingScore(t *testing.T) {
@@ -189,3 +190,102 @@ func TestGatherSpec_GetIncludeStdErr(t *testing.T) {
 		})
 	}
 }
+
+func TestExec_GetTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Exec
+		want time.Duration
+	}{
+		{
+			name: "empty returns 0",
+			e:    Exec{},
+			want: 0,
+		},
+		{
+			name: "valid duration is parsed",
+			e:    Exec{Timeout: "1m30s"},
+			want: 90 * time.Second,
+		},
+		{
+			name: "invalid duration returns 0",
+			e:    Exec{Timeout: "later"},
+			want: 0,
+		},
+		{
+			name: "negative duration returns 0",
+			e:    Exec{Timeout: "-5s"},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.GetTimeout(); got != tt.want {
+				t.Errorf("Exec.GetTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaybook_GetTimeouts(t *testing.T) {
+	p := Playbook{DefaultTimeout: "30s", RunTimeout: "10m"}
+	if got := p.GetDefaultTimeout(); got != 30*time.Second {
+		t.Errorf("Playbook.GetDefaultTimeout() = %v, want 30s", got)
+	}
+	if got := p.GetRunTimeout(); got != 10*time.Minute {
+		t.Errorf("Playbook.GetRunTimeout() = %v, want 10m", got)
+	}
+}
+
+func TestAssertion_GetWeight(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		weight   *int
+		want     int
+	}{
+		{"", nil, 2},
+		{SeverityInfo, nil, 0},
+		{SeverityLow, nil, 1},
+		{SeverityHigh, nil, 4},
+		{SeverityCritical, nil, 8},
+		{SeverityCritical, func(i int) *int { return &i }(3), 3},
+		{SeverityLow, func(i int) *int { return &i }(0), 0},
+	}
+	for _, tt := range tests {
+		a := Assertion{Severity: tt.severity, Weight: tt.weight}
+		if got := a.GetWeight(); got != tt.want {
+			t.Errorf("Assertion{Severity: %q}.GetWeight() = %d, want %d", tt.severity, got, tt.want)
+		}
+	}
+	if got := (Assertion{}).GetSeverity(); got != SeverityMedium {
+		t.Errorf("default severity = %q, want medium", got)
+	}
+}
+
+func TestPlaybook_GetConcurrency(t *testing.T) {
+	for _, tt := range []struct{ concurrency, want int }{{0, 1}, {-1, 1}, {1, 1}, {8, 8}} {
+		if got := (Playbook{Concurrency: tt.concurrency}).GetConcurrency(); got != tt.want {
+			t.Errorf("Playbook{Concurrency: %d}.GetConcurrency() = %d, want %d", tt.concurrency, got, tt.want)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	tests := []struct {
+		name      string
+		platforms []Platform
+		os        string
+		want      bool
+	}{
+		{"empty applies everywhere", nil, "windows", true},
+		{"listed platform", []Platform{PlatformLinux, PlatformMac}, "mac", true},
+		{"unlisted platform", []Platform{PlatformLinux, PlatformMac}, "windows", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AppliesTo(tt.platforms, tt.os); got != tt.want {
+				t.Errorf("AppliesTo(%v, %s) = %v, want %v", tt.platforms, tt.os, got, tt.want)
+			}
+		})
+	}
+}
@@ -20,3 +20,21 @@ func GenerateSchema() (string, error) {
 	}
 	return string(data), nil
 }
+
+// JSONSchemaExtend lets a section be either a full section or an include entry.
+func (Section) JSONSchemaExtend(s *jsonschema.Schema) {
+	requireDefinitionOrInclude(s)
+}
+
+// JSONSchemaExtend lets an assertion be either a full assertion or an include entry.
+func (Assertion) JSONSchemaExtend(s *jsonschema.Schema) {
+	requireDefinitionOrInclude(s)
+}
+
+func requireDefinitionOrInclude(s *jsonschema.Schema) {
+	s.OneOf = []*jsonschema.Schema{
+		{Required: s.Required},
+		{Required: []string{"include"}},
+	}
+	s.Required = nil
+}
@@ -0,0 +1,98 @@
+package playbook
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Selector narrows a run to a subset of the playbook's assertions. Every pattern is a glob
+// (path.Match syntax, eg: SSH_*), except that * and ? also match "/", which titles and
+// tags may contain. Criteria combine with AND; within a list, any pattern may
+// match. An empty Selector selects every assertion.
+type Selector struct {
+	Tags        []string `json:"tags,omitempty"`
+	ExcludeTags []string `json:"excludeTags,omitempty"`
+	Codes       []string `json:"codes,omitempty"`
+	Sections    []string `json:"sections,omitempty"`
+}
+
+func (s Selector) IsEmpty() bool {
+	return len(s.Tags) == 0 && len(s.ExcludeTags) == 0 && len(s.Codes) == 0 && len(s.Sections) == 0
+}
+
+// Validate reports malformed glob patterns.
+func (s Selector) Validate() error {
+	for _, list := range [][]string{s.Tags, s.ExcludeTags, s.Codes, s.Sections} {
+		for _, pattern := range list {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid selection pattern '%s': %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Selects reports whether assertion (within section) is selected. When it is not, reason
+// explains which criterion excluded it.
+func (s Selector) Selects(section Section, assertion Assertion) (bool, string) {
+	if len(s.Sections) > 0 && !matchAny(s.Sections, section.Title) {
+		return false, "section not selected"
+	}
+	if len(s.Codes) > 0 && !matchAny(s.Codes, assertion.Code) {
+		return false, "code not selected"
+	}
+	if len(s.Tags) > 0 {
+		tagged := false
+		for _, tag := range assertion.Tags {
+			if matchAny(s.Tags, tag) {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			return false, "no selected tag"
+		}
+	}
+	for _, tag := range assertion.Tags {
+		if matchAny(s.ExcludeTags, tag) {
+			return false, fmt.Sprintf("excluded tag '%s'", tag)
+		}
+	}
+	return true, ""
+}
+
+// String describes the active criteria, eg: "tags=ssh,auth code=SSH_*".
+func (s Selector) String() string {
+	var parts []string
+	for _, c := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"tags", s.Tags},
+		{"exclude-tags", s.ExcludeTags},
+		{"code", s.Codes},
+		{"section", s.Sections},
+	} {
+		if len(c.patterns) > 0 {
+			parts = append(parts, c.name+"="+strings.Join(c.patterns, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch is path.Match with "/" matched like any other character: it is swapped for a
+// NUL byte, which path.Match gives no meaning, on both sides.
+func globMatch(pattern, value string) bool {
+	ok, _ := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(value, "/", "\x00"))
+	return ok
+}
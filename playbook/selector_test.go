@@ -0,0 +1,59 @@
+package playbook
+
+import "testing"
+
+func TestSelector_Selects(t *testing.T) {
+	section := Section{Title: "1. Network"}
+	ssh := Assertion{Code: "SSH_ROOT_LOGIN", Tags: []string{"ssh", "cis-5.2", "nist/ac/6"}}
+	slow := Assertion{Code: "FS_WORLD_WRITABLE", Tags: []string{"filesystem", "slow"}}
+	untagged := Assertion{Code: "KERNEL_MODERN"}
+
+	tests := []struct {
+		name       string
+		selector   Selector
+		assertion  Assertion
+		want       bool
+		wantReason string
+	}{
+		{"Empty selects all", Selector{}, untagged, true, ""},
+		{"Tag match", Selector{Tags: []string{"ssh"}}, ssh, true, ""},
+		{"Tag glob", Selector{Tags: []string{"cis-*"}}, ssh, true, ""},
+		{"Tag glob across slashes", Selector{Tags: []string{"nist/*"}}, ssh, true, ""},
+		{"Tag single character across slashes", Selector{Tags: []string{"nist?ac?6"}}, ssh, true, ""},
+		{"Tag miss", Selector{Tags: []string{"ssh"}}, slow, false, "no selected tag"},
+		{"Untagged with tag filter", Selector{Tags: []string{"ssh"}}, untagged, false, "no selected tag"},
+		{"Excluded tag", Selector{ExcludeTags: []string{"slow"}}, slow, false, "excluded tag 'slow'"},
+		{"Exclude wins over include", Selector{Tags: []string{"filesystem"}, ExcludeTags: []string{"slow"}}, slow, false, "excluded tag 'slow'"},
+		{"Untagged survives exclude", Selector{ExcludeTags: []string{"slow"}}, untagged, true, ""},
+		{"Code glob", Selector{Codes: []string{"SSH_*"}}, ssh, true, ""},
+		{"Code miss", Selector{Codes: []string{"SSH_*"}}, slow, false, "code not selected"},
+		{"Section glob", Selector{Sections: []string{"*Network"}}, untagged, true, ""},
+		{"Section miss", Selector{Sections: []string{"2.*"}}, untagged, false, "section not selected"},
+		{"Combined criteria", Selector{Sections: []string{"1.*"}, Codes: []string{"SSH_*"}, Tags: []string{"ssh"}}, ssh, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.selector.Selects(section, tt.assertion)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("Selects() = %v, %q; want %v, %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSelector_StringAndValidate(t *testing.T) {
+	s := Selector{Tags: []string{"ssh", "auth"}, Codes: []string{"SSH_*"}}
+	if got := s.String(); got != "tags=ssh,auth code=SSH_*" {
+		t.Errorf("String() = %q", got)
+	}
+	if s.IsEmpty() || !(Selector{}).IsEmpty() {
+		t.Errorf("IsEmpty() mismatch")
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (Selector{Codes: []string{"SSH_["}}).Validate(); err == nil {
+		t.Errorf("Validate() should reject malformed patterns")
+	}
+}
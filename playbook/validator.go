@@ -2,20 +2,72 @@ package playbook
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 )
 
 func ValidateConfig(config Playbook, isAgent bool) error {
-	codes := make(map[string]bool)
+	codes := make(map[string]string)
+
+	if err := checkDuration(config.DefaultTimeout, "defaultTimeout"); err != nil {
+		return err
+	}
+	if err := checkDuration(config.RunTimeout, "runTimeout"); err != nil {
+		return err
+	}
+	if config.Concurrency < 0 {
+		return fmt.Errorf("invalid concurrency %d: must be positive", config.Concurrency)
+	}
+	if err := checkVars(config.Vars); err != nil {
+		return err
+	}
+	if err := checkOutputs(config.ReportOutputs); err != nil {
+		return err
+	}
 
 	for _, section := range config.Sections {
+		if section.Include != "" {
+			return fmt.Errorf("section include of %s was not resolved", section.Include)
+		}
+		if err := checkPlatforms(section.Platforms, fmt.Sprintf("section '%s'", section.Title)); err != nil {
+			return err
+		}
+		if isAgent && section.WhenFile != "" {
+			return fmt.Errorf("agent error: section '%s' contains whenFile", section.Title)
+		}
 		for _, assertion := range section.Assertions {
+			if assertion.Include != "" {
+				return fmt.Errorf("assertion include of %s in section '%s' was not resolved", assertion.Include, section.Title)
+			}
 			if assertion.Code == "" {
 				return fmt.Errorf("assertion '%s' in section '%s' is missing a 'code'", assertion.Title, section.Title)
 			}
-			if codes[assertion.Code] {
-				return fmt.Errorf("duplicate code found: %s", assertion.Code)
+			origin := assertionOrigin(section, assertion)
+			if first, ok := codes[assertion.Code]; ok {
+				return fmt.Errorf("duplicate code found: %s, in %s and %s", assertion.Code, first, origin)
+			}
+			codes[assertion.Code] = origin
+
+			if err := checkTimeouts(assertion); err != nil {
+				return err
+			}
+			if err := checkPlatforms(assertion.Platforms, fmt.Sprintf("assertion %s", assertion.Code)); err != nil {
+				return err
+			}
+			if err := checkSeverity(assertion); err != nil {
+				return err
+			}
+			if err := checkControls(assertion); err != nil {
+				return err
+			}
+			if err := checkRemediation(assertion); err != nil {
+				return err
+			}
+			if err := checkRegexes(assertion); err != nil {
+				return err
 			}
-			codes[assertion.Code] = true
 
 			if isAgent {
 				if err := checkNoFuncFile(assertion); err != nil {
@@ -27,7 +79,19 @@ func ValidateConfig(config Playbook, isAgent bool) error {
 	return nil
 }
 
+// assertionOrigin describes where an assertion was defined, for collision errors.
+func assertionOrigin(section Section, assertion Assertion) string {
+	origin := fmt.Sprintf("section '%s'", section.Title)
+	if assertion.Source != "" {
+		origin += fmt.Sprintf(" (from %s)", assertion.Source)
+	}
+	return origin
+}
+
 func checkNoFuncFile(assertion Assertion) error {
+	if assertion.WhenFile != "" {
+		return fmt.Errorf("agent error: assertion %s contains whenFile", assertion.Code)
+	}
 	for _, exec := range assertion.PreCmds {
 		if exec.ShellFuncFile != "" {
 			return fmt.Errorf("agent error: assertion %s contains shellFuncFile in preCmd", assertion.Code)
@@ -63,5 +127,168 @@ func checkNoFuncFile(assertion Assertion) error {
 			return fmt.Errorf("agent error: assertion %s contains funcFile in postCmd", assertion.Code)
 		}
 	}
+	if r := assertion.Remediation; r != nil {
+		if r.Exec.ShellFuncFile != "" {
+			return fmt.Errorf("agent error: assertion %s contains shellFuncFile in remediation", assertion.Code)
+		}
+		if r.Exec.FuncFile != "" {
+			return fmt.Errorf("agent error: assertion %s contains funcFile in remediation", assertion.Code)
+		}
+	}
+	return nil
+}
+
+func checkTimeouts(assertion Assertion) error {
+	for _, exec := range assertion.PreCmds {
+		if err := checkDuration(exec.Timeout, fmt.Sprintf("timeout of preCmd in assertion %s", assertion.Code)); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range assertion.Cmds {
+		if err := checkDuration(cmd.Exec.Timeout, fmt.Sprintf("timeout of cmd in assertion %s", assertion.Code)); err != nil {
+			return err
+		}
+	}
+	for _, exec := range assertion.PostCmds {
+		if err := checkDuration(exec.Timeout, fmt.Sprintf("timeout of postCmd in assertion %s", assertion.Code)); err != nil {
+			return err
+		}
+	}
+	if assertion.Remediation != nil {
+		if err := checkDuration(assertion.Remediation.Exec.Timeout, fmt.Sprintf("timeout of remediation in assertion %s", assertion.Code)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDuration(value string, field string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s '%s': %v", field, value, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid %s '%s': must be positive", field, value)
+	}
+	return nil
+}
+
+func checkSeverity(assertion Assertion) error {
+	if _, ok := severityWeights[assertion.GetSeverity()]; !ok {
+		return fmt.Errorf("assertion %s has unknown severity '%s' (expected info, low, medium, high or critical)", assertion.Code, assertion.Severity)
+	}
+	if assertion.Weight != nil && *assertion.Weight < 0 {
+		return fmt.Errorf("assertion %s has negative weight %d", assertion.Code, *assertion.Weight)
+	}
+	return nil
+}
+
+func checkRemediation(assertion Assertion) error {
+	r := assertion.Remediation
+	if r == nil {
+		return nil
+	}
+	if strings.TrimSpace(r.Description) == "" {
+		return fmt.Errorf("remediation of assertion %s is missing a description", assertion.Code)
+	}
+	if r.Exec.Script == "" && r.Exec.Func == "" && r.Exec.FuncFile == "" {
+		return fmt.Errorf("remediation of assertion %s has nothing to execute (script, func or funcFile required)", assertion.Code)
+	}
+	return nil
+}
+
+// checkRegexes compiles the regexes of the assertion. Those still referencing vars are
+// checked once the vars are interpolated.
+func checkRegexes(assertion Assertion) error {
+	check := func(regex string, where string) error {
+		if regex == "" || strings.Contains(regex, "${vars.") {
+			return nil
+		}
+		if _, err := regexp.Compile(regex); err != nil {
+			return fmt.Errorf("invalid regex in %s of assertion %s: %v", where, assertion.Code, err)
+		}
+		return nil
+	}
+	checkGathers := func(e Exec, where string) error {
+		for _, g := range e.Gather {
+			if err := check(g.Regex, where+" gather "+g.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, e := range assertion.PreCmds {
+		if err := checkGathers(e, "preCmd"); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range assertion.Cmds {
+		if err := checkGathers(cmd.Exec, "cmd"); err != nil {
+			return err
+		}
+		if err := check(cmd.StdOutRule.Regex, "stdOutRule"); err != nil {
+			return err
+		}
+		if err := check(cmd.StdErrRule.Regex, "stdErrRule"); err != nil {
+			return err
+		}
+	}
+	for _, e := range assertion.PostCmds {
+		if err := checkGathers(e, "postCmd"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkControls(assertion Assertion) error {
+	seen := make(map[ControlRef]bool)
+	for i, c := range assertion.Controls {
+		if strings.TrimSpace(c.Framework) == "" || strings.TrimSpace(c.ID) == "" {
+			return fmt.Errorf("control #%d of assertion %s requires both framework and id", i+1, assertion.Code)
+		}
+		if c.URL != "" {
+			u, err := url.Parse(c.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("control %s %s of assertion %s has invalid url '%s'", c.Framework, c.ID, assertion.Code, c.URL)
+			}
+		}
+		key := ControlRef{Framework: c.Framework, ID: c.ID}
+		if seen[key] {
+			return fmt.Errorf("assertion %s lists control %s %s more than once", assertion.Code, c.Framework, c.ID)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// checkOutputs rejects unknown report output formats.
+func checkOutputs(outputs []OutputFormat) error {
+	seen := make(map[OutputFormat]bool)
+	for _, o := range outputs {
+		switch o {
+		case OutputSARIF, OutputJUnit, OutputHTML, OutputOSCAL, OutputXCCDF, OutputOpenMetrics:
+		default:
+			return fmt.Errorf("unknown report output '%s' (expected sarif, junit, html, oscal, xccdf or openmetrics)", o)
+		}
+		if seen[o] {
+			return fmt.Errorf("report output '%s' is listed more than once", o)
+		}
+		seen[o] = true
+	}
+	return nil
+}
+
+func checkPlatforms(platforms []Platform, owner string) error {
+	for _, p := range platforms {
+		switch p {
+		case PlatformLinux, PlatformMac, PlatformWindows:
+		default:
+			return fmt.Errorf("%s has unknown platform '%s' (expected linux, mac or windows)", owner, p)
+		}
+	}
 	return nil
 }
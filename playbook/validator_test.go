@@ -28,6 +28,16 @@ func TestValidateConfig(t *testing.T) {
 			isAgent:   false,
 			wantError: "",
 		},
+		{
+			name:      "Unknown Report Output",
+			config:    Playbook{Title: "Test", ReportOutputs: []OutputFormat{"pdf"}},
+			wantError: "unknown report output 'pdf'",
+		},
+		{
+			name:      "Duplicate Report Output",
+			config:    Playbook{Title: "Test", ReportOutputs: []OutputFormat{OutputSARIF, OutputSARIF}},
+			wantError: "report output 'sarif' is listed more than once",
+		},
 		{
 			name: "Missing Code",
 			config: Playbook{
@@ -61,6 +71,33 @@ func TestValidateConfig(t *testing.T) {
 			isAgent:   false,
 			wantError: "duplicate code found: DUP",
 		},
+		{
+			name: "Duplicate Code From Include",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{Title: "OS", Assertions: []Assertion{{Code: "DUP", Title: "A1", Source: "libs/os.yaml"}}},
+					{Title: "Team", Assertions: []Assertion{{Code: "DUP", Title: "A2"}}},
+				},
+			},
+			wantError: "duplicate code found: DUP, in section 'OS' (from libs/os.yaml) and section 'Team'",
+		},
+		{
+			name: "Unresolved Section Include",
+			config: Playbook{
+				Title:    "Test",
+				Sections: []Section{{Include: "libs/os.yaml"}},
+			},
+			wantError: "section include of libs/os.yaml was not resolved",
+		},
+		{
+			name: "Unresolved Assertion Include",
+			config: Playbook{
+				Title:    "Test",
+				Sections: []Section{{Title: "S1", Assertions: []Assertion{{Include: "libs/ssh.yaml"}}}},
+			},
+			wantError: "assertion include of libs/ssh.yaml in section 'S1' was not resolved",
+		},
 		{
 			name: "Agent Mode funcFile Error - PreCmd",
 			config: Playbook{
@@ -193,6 +230,105 @@ func TestValidateConfig(t *testing.T) {
 			isAgent:   true,
 			wantError: "contains funcFile in postCmd",
 		},
+		{
+			name: "Agent Mode funcFile Error - Remediation",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{
+								Code:        "R01",
+								Remediation: &Remediation{Description: "Fix it", Exec: Exec{FuncFile: "fix.ts"}},
+							},
+						},
+					},
+				},
+			},
+			isAgent:   true,
+			wantError: "contains funcFile in remediation",
+		},
+		{
+			name: "Remediation Without Description",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "R02", Remediation: &Remediation{Exec: Exec{Script: "chmod 600 /etc/shadow"}}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "remediation of assertion R02 is missing a description",
+		},
+		{
+			name: "Remediation Without Execution",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "R03", Remediation: &Remediation{Description: "Fix it"}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "remediation of assertion R03 has nothing to execute",
+		},
+		{
+			name: "Invalid Rule Regex",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "RX1", Cmds: []Cmd{{Exec: Exec{Script: "cat /etc/os-release"}, StdOutRule: EvaluationRule{Regex: "ID=(ubuntu"}}}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "invalid regex in stdOutRule of assertion RX1",
+		},
+		{
+			name: "Invalid Gather Regex",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "RX2", PreCmds: []Exec{{Script: "id", Gather: []GatherSpec{{Key: "uid", Regex: "uid=[0-9"}}}}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "invalid regex in preCmd gather uid of assertion RX2",
+		},
+		{
+			name: "Uninterpolated Regex Is Not Checked",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "RX3", Cmds: []Cmd{{Exec: Exec{Script: "id"}, StdOutRule: EvaluationRule{Regex: "${vars.user}("}}}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "",
+		},
 		{
 			name: "Agent Mode shellFuncFile Error - PreCmd",
 			config: Playbook{
@@ -256,6 +392,197 @@ func TestValidateConfig(t *testing.T) {
 			isAgent:   true,
 			wantError: "contains shellFuncFile in postCmd",
 		},
+		{
+			name: "Invalid Default Timeout",
+			config: Playbook{
+				Title:          "Test",
+				DefaultTimeout: "soon",
+			},
+			isAgent:   false,
+			wantError: "invalid defaultTimeout 'soon'",
+		},
+		{
+			name: "Non-positive Run Timeout",
+			config: Playbook{
+				Title:      "Test",
+				RunTimeout: "0s",
+			},
+			isAgent:   false,
+			wantError: "invalid runTimeout '0s': must be positive",
+		},
+		{
+			name: "Negative Concurrency",
+			config: Playbook{
+				Title:       "Test",
+				Concurrency: -2,
+			},
+			isAgent:   false,
+			wantError: "invalid concurrency -2: must be positive",
+		},
+		{
+			name: "Invalid Var Name",
+			config: Playbook{
+				Title: "Test",
+				Vars:  map[string]interface{}{"config-path": "/etc"},
+			},
+			wantError: "invalid var name 'config-path': use letters, digits and underscores",
+		},
+		{
+			name: "Invalid Var Default",
+			config: Playbook{
+				Title: "Test",
+				Vars:  map[string]interface{}{"paths": []interface{}{"/etc"}},
+			},
+			wantError: "var 'paths' must default to a string, number or boolean",
+		},
+		{
+			name: "Invalid Cmd Timeout",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{
+								Code: "T01",
+								Cmds: []Cmd{
+									{Exec: Exec{Script: "sleep 1", Timeout: "10"}},
+								},
+							},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "invalid timeout of cmd in assertion T01",
+		},
+		{
+			name: "Unknown Assertion Platform",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "PL01", Platforms: []Platform{PlatformLinux, "darwin"}},
+						},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "assertion PL01 has unknown platform 'darwin'",
+		},
+		{
+			name: "Unknown Section Platform",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{Title: "S1", Platforms: []Platform{"bsd"}},
+				},
+			},
+			isAgent:   false,
+			wantError: "section 'S1' has unknown platform 'bsd'",
+		},
+		{
+			name: "Unknown Severity",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title:      "S1",
+						Assertions: []Assertion{{Code: "SV01", Severity: "severe"}},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "assertion SV01 has unknown severity 'severe'",
+		},
+		{
+			name: "Negative Weight",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title:      "S1",
+						Assertions: []Assertion{{Code: "SV02", Weight: func(i int) *int { return &i }(-1)}},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "assertion SV02 has negative weight -1",
+		},
+		{
+			name: "Control Missing ID",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title:      "S1",
+						Assertions: []Assertion{{Code: "CT01", Controls: []ControlRef{{Framework: "CIS"}}}},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "control #1 of assertion CT01 requires both framework and id",
+		},
+		{
+			name: "Control Invalid URL",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title:      "S1",
+						Assertions: []Assertion{{Code: "CT02", Controls: []ControlRef{{Framework: "NIST 800-53", ID: "AC-6", URL: "nist.gov/ac-6"}}}},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "control NIST 800-53 AC-6 of assertion CT02 has invalid url 'nist.gov/ac-6'",
+		},
+		{
+			name: "Duplicate Control",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{{Code: "CT03", Controls: []ControlRef{
+							{Framework: "CIS", ID: "5.2.10"},
+							{Framework: "CIS", ID: "5.2.10", URL: "https://example.com"},
+						}}},
+					},
+				},
+			},
+			isAgent:   false,
+			wantError: "assertion CT03 lists control CIS 5.2.10 more than once",
+		},
+		{
+			name: "Agent Mode whenFile Error - Assertion",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{
+						Title: "S1",
+						Assertions: []Assertion{
+							{Code: "W01", WhenFile: "when.ts"},
+						},
+					},
+				},
+			},
+			isAgent:   true,
+			wantError: "assertion W01 contains whenFile",
+		},
+		{
+			name: "Agent Mode whenFile Error - Section",
+			config: Playbook{
+				Title: "Test",
+				Sections: []Section{
+					{Title: "S1", WhenFile: "when.ts"},
+				},
+			},
+			isAgent:   true,
+			wantError: "section 'S1' contains whenFile",
+		},
 		{
 			name: "Valid Agent Config",
 			config: Playbook{
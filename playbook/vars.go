@@ -0,0 +1,179 @@
+package playbook
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	varRefPattern  = regexp.MustCompile(`\$\{vars\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// ApplyVars overrides the defaults of the playbook's vars. Every overridden var must be
+// declared in the playbook, and string values (from the command line or the environment)
+// are converted to the type of its default.
+func (p *Playbook) ApplyVars(overrides map[string]interface{}) error {
+	for _, name := range sortedVarNames(overrides) {
+		def, ok := p.Vars[name]
+		if !ok {
+			return fmt.Errorf("unknown var '%s': it is not declared in the playbook's vars", name)
+		}
+		val, err := coerceVar(overrides[name], def)
+		if err != nil {
+			return fmt.Errorf("invalid value for var '%s': %v", name, err)
+		}
+		p.Vars[name] = val
+	}
+	return nil
+}
+
+// coerceVar converts val to the type of def (string, bool or number).
+func coerceVar(val interface{}, def interface{}) (interface{}, error) {
+	s, isString := val.(string)
+	switch def.(type) {
+	case bool:
+		if isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a boolean", s)
+			}
+			return b, nil
+		}
+		if _, ok := val.(bool); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %v", val)
+	case int, int64, float64:
+		if isString {
+			// Whole numbers stay integers unless the default is fractional (as in JSON playbooks)
+			if _, isFloat := def.(float64); !isFloat {
+				if i, err := strconv.Atoi(s); err == nil {
+					return i, nil
+				}
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a number", s)
+			}
+			return f, nil
+		}
+		switch val.(type) {
+		case int, int64, float64:
+			return val, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %v", val)
+	}
+	if isString {
+		return s, nil
+	}
+	return fmt.Sprint(val), nil
+}
+
+// InterpolateVars replaces ${vars.name} in scripts, regexes and descriptions with the
+// value of the var. A reference to an undeclared var is an error.
+func (p *Playbook) InterpolateVars() error {
+	var err error
+	interpolate := func(s *string) {
+		if err == nil && strings.Contains(*s, "${vars.") {
+			*s, err = p.interpolate(*s)
+		}
+	}
+	interpolateExec := func(e *Exec) {
+		interpolate(&e.Script)
+		for i := range e.Gather {
+			interpolate(&e.Gather[i].Regex)
+		}
+	}
+
+	for si := range p.Sections {
+		section := &p.Sections[si]
+		for i := range section.Description {
+			interpolate(&section.Description[i])
+		}
+		if err != nil {
+			return fmt.Errorf("section '%s': %v", section.Title, err)
+		}
+		for ai := range section.Assertions {
+			assertion := &section.Assertions[ai]
+			interpolate(&assertion.Description)
+			interpolate(&assertion.PassDescription)
+			interpolate(&assertion.FailDescription)
+			for i := range assertion.PreCmds {
+				interpolateExec(&assertion.PreCmds[i])
+			}
+			for i := range assertion.Cmds {
+				cmd := &assertion.Cmds[i]
+				interpolateExec(&cmd.Exec)
+				interpolate(&cmd.StdOutRule.Regex)
+				interpolate(&cmd.StdErrRule.Regex)
+			}
+			for i := range assertion.PostCmds {
+				interpolateExec(&assertion.PostCmds[i])
+			}
+			if assertion.Remediation != nil {
+				interpolate(&assertion.Remediation.Description)
+				interpolateExec(&assertion.Remediation.Exec)
+			}
+			if err != nil {
+				return fmt.Errorf("assertion %s: %v", assertion.Code, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Playbook) interpolate(s string) (string, error) {
+	var missing string
+	out := varRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varRefPattern.FindStringSubmatch(ref)[1]
+		val, ok := p.Vars[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return ref
+		}
+		return fmt.Sprint(val)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("reference to undeclared var '%s'", missing)
+	}
+	return out, nil
+}
+
+func checkVars(vars map[string]interface{}) error {
+	for _, name := range sortedVarNames(vars) {
+		val := vars[name]
+		if !varNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid var name '%s': use letters, digits and underscores", name)
+		}
+		switch val.(type) {
+		case string, bool, int, int64, float64:
+		default:
+			return fmt.Errorf("var '%s' must default to a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+func sortedVarNames(vars map[string]interface{}) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatVars lists vars in name order, eg: "kernelRegex=^[6-9]\., strict=true".
+func FormatVars(vars map[string]interface{}) string {
+	parts := make([]string, 0, len(vars))
+	for _, name := range sortedVarNames(vars) {
+		parts = append(parts, fmt.Sprintf("%s=%v", name, vars[name]))
+	}
+	return strings.Join(parts, ", ")
+}
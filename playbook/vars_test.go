@@ -0,0 +1,111 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlaybook_ApplyVars(t *testing.T) {
+	newPlaybook := func() Playbook {
+		return Playbook{Vars: map[string]interface{}{
+			"path":    "/etc/app/config.env",
+			"retries": 3,
+			"ratio":   0.5,
+			"strict":  false,
+		}}
+	}
+
+	p := newPlaybook()
+	err := p.ApplyVars(map[string]interface{}{"path": "/opt/app.env", "retries": "5", "ratio": "1", "strict": "true"})
+	if err != nil {
+		t.Fatalf("ApplyVars failed: %v", err)
+	}
+	if p.Vars["path"] != "/opt/app.env" || p.Vars["retries"] != 5 || p.Vars["ratio"] != 1.0 || p.Vars["strict"] != true {
+		t.Errorf("unexpected vars after overrides: %v", p.Vars)
+	}
+
+	p = newPlaybook()
+	if err := p.ApplyVars(map[string]interface{}{"retries": 7, "strict": true}); err != nil {
+		t.Errorf("expected typed overrides (from a var file) to apply, got %v", err)
+	}
+
+	errorCases := []struct {
+		name      string
+		overrides map[string]interface{}
+		want      string
+	}{
+		{"Unknown var", map[string]interface{}{"missing": "x"}, "unknown var 'missing'"},
+		{"Not a number", map[string]interface{}{"retries": "many"}, "'many' is not a number"},
+		{"Not a boolean", map[string]interface{}{"strict": "maybe"}, "'maybe' is not a boolean"},
+		{"Wrong type from file", map[string]interface{}{"strict": 1}, "expected a boolean"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlaybook()
+			err := p.ApplyVars(tt.overrides)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ApplyVars() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaybook_InterpolateVars(t *testing.T) {
+	p := Playbook{
+		Vars: map[string]interface{}{"kernelRegex": `^[6-9]\.`, "configPath": "/etc/app/config.env", "minVersion": 6},
+		Sections: []Section{{
+			Title:       "S1",
+			Description: []string{"Kernel ${vars.minVersion} or later"},
+			Assertions: []Assertion{{
+				Code:            "KERNEL",
+				Description:     "Reads ${vars.configPath}",
+				PassDescription: "Kernel >= ${vars.minVersion}",
+				Cmds: []Cmd{{
+					Exec:       Exec{Script: "cat ${vars.configPath}", Gather: []GatherSpec{{Key: "v", Regex: "${vars.kernelRegex}"}}},
+					StdOutRule: EvaluationRule{Regex: "${vars.kernelRegex}"},
+				}},
+				Remediation: &Remediation{Description: "Fix ${vars.configPath}", Exec: Exec{Script: "touch ${vars.configPath}"}},
+			}},
+		}},
+	}
+
+	if err := p.InterpolateVars(); err != nil {
+		t.Fatalf("InterpolateVars failed: %v", err)
+	}
+	a := p.Sections[0].Assertions[0]
+	checks := map[string]string{
+		"section description": p.Sections[0].Description[0],
+		"description":         a.Description,
+		"pass description":    a.PassDescription,
+		"script":              a.Cmds[0].Exec.Script,
+		"gather regex":        a.Cmds[0].Exec.Gather[0].Regex,
+		"rule regex":          a.Cmds[0].StdOutRule.Regex,
+		"remediation":         a.Remediation.Description + " | " + a.Remediation.Exec.Script,
+	}
+	expected := map[string]string{
+		"section description": "Kernel 6 or later",
+		"description":         "Reads /etc/app/config.env",
+		"pass description":    "Kernel >= 6",
+		"script":              "cat /etc/app/config.env",
+		"gather regex":        `^[6-9]\.`,
+		"rule regex":          `^[6-9]\.`,
+		"remediation":         "Fix /etc/app/config.env | touch /etc/app/config.env",
+	}
+	for field, got := range checks {
+		if got != expected[field] {
+			t.Errorf("%s = %q, want %q", field, got, expected[field])
+		}
+	}
+
+	p.Sections[0].Assertions[0].FailDescription = "See ${vars.typo}"
+	if err := p.InterpolateVars(); err == nil || !strings.Contains(err.Error(), "assertion KERNEL: reference to undeclared var 'typo'") {
+		t.Errorf("expected an undeclared var error, got %v", err)
+	}
+}
+
+func TestFormatVars(t *testing.T) {
+	got := FormatVars(map[string]interface{}{"strict": true, "path": "/etc", "retries": 3})
+	if got != "path=/etc, retries=3, strict=true" {
+		t.Errorf("FormatVars() = %q", got)
+	}
+}
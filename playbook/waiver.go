@@ -0,0 +1,106 @@
+package playbook
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// WaiverDateFormat is the format of waiver expiry dates.
+const WaiverDateFormat = "2006-01-02"
+
+// WaiverFile lists accepted exceptions. It is kept apart from playbooks, so that exceptions
+// are approved and tracked separately from the checks themselves.
+type WaiverFile struct {
+	Waivers []Waiver `yaml:"waivers" json:"waivers"`
+}
+
+// Waiver accepts the failure of assertions on matching hosts and users until it expires.
+// Waived failures are reported as "waived" instead of "fail" and do not fail the run.
+type Waiver struct {
+	// Codes, Hosts and Users are glob patterns. Empty Hosts or Users match any host or user.
+	Codes         []string `yaml:"codes" json:"codes"`
+	Hosts         []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	Users         []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Justification string   `yaml:"justification" json:"justification"`
+	Approver      string   `yaml:"approver" json:"approver"`
+	// Expires is the last day (YYYY-MM-DD, local time) on which the waiver applies.
+	Expires string `yaml:"expires" json:"expires"`
+}
+
+// Matches reports whether the waiver covers assertion code on host for user. Hosts are
+// compared case-insensitively.
+func (w Waiver) Matches(code string, host string, user string) bool {
+	return matchAny(w.Codes, code) &&
+		(len(w.Hosts) == 0 || matchAny(lowerAll(w.Hosts), strings.ToLower(host))) &&
+		(len(w.Users) == 0 || matchAny(w.Users, user))
+}
+
+// Expired reports whether the last day of the waiver is before now.
+func (w Waiver) Expired(now time.Time) bool {
+	expires, err := time.ParseInLocation(WaiverDateFormat, w.Expires, now.Location())
+	if err != nil {
+		return true
+	}
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// Describe names the waiver for messages, eg: "waiver for KIOSK_* (approved by J. Doe)".
+func (w Waiver) Describe() string {
+	return fmt.Sprintf("waiver for %s (approved by %s)", strings.Join(w.Codes, ", "), w.Approver)
+}
+
+// FindWaiver returns the waiver covering a failed assertion, if any. A matching waiver that
+// is still active wins over expired ones, so that renewing a waiver does not require
+// removing the old entry.
+func FindWaiver(waivers []Waiver, code string, host string, user string, now time.Time) (*Waiver, bool) {
+	var expired *Waiver
+	for i := range waivers {
+		w := &waivers[i]
+		if !w.Matches(code, host, user) {
+			continue
+		}
+		if !w.Expired(now) {
+			return w, false
+		}
+		if expired == nil {
+			expired = w
+		}
+	}
+	return expired, expired != nil
+}
+
+// ValidateWaivers checks that every waiver is complete and can be audited.
+func ValidateWaivers(waivers []Waiver) error {
+	for i, w := range waivers {
+		if len(w.Codes) == 0 {
+			return fmt.Errorf("waiver #%d has no codes", i+1)
+		}
+		for _, patterns := range [][]string{w.Codes, w.Hosts, w.Users} {
+			for _, p := range patterns {
+				if _, err := path.Match(p, ""); err != nil {
+					return fmt.Errorf("waiver #%d has malformed pattern '%s': %v", i+1, p, err)
+				}
+			}
+		}
+		if strings.TrimSpace(w.Justification) == "" {
+			return fmt.Errorf("%s is missing a justification", w.Describe())
+		}
+		if strings.TrimSpace(w.Approver) == "" {
+			return fmt.Errorf("waiver #%d for %s is missing an approver", i+1, strings.Join(w.Codes, ", "))
+		}
+		if _, err := time.Parse(WaiverDateFormat, w.Expires); err != nil {
+			return fmt.Errorf("%s has invalid expires '%s': expected YYYY-MM-DD", w.Describe(), w.Expires)
+		}
+	}
+	return nil
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
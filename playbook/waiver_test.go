@@ -0,0 +1,99 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaiver_Matches(t *testing.T) {
+	w := Waiver{Codes: []string{"DISK_*"}, Hosts: []string{"kiosk-*"}, Users: []string{"root"}}
+
+	tests := []struct {
+		name             string
+		code, host, user string
+		want             bool
+	}{
+		{"All match", "DISK_ENCRYPTION", "kiosk-01", "root", true},
+		{"Host case-insensitive", "DISK_ENCRYPTION", "KIOSK-01", "root", true},
+		{"Code mismatch", "SSH_ROOT", "kiosk-01", "root", false},
+		{"Host mismatch", "DISK_ENCRYPTION", "laptop-01", "root", false},
+		{"User mismatch", "DISK_ENCRYPTION", "kiosk-01", "alice", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.Matches(tt.code, tt.host, tt.user); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	anyHost := Waiver{Codes: []string{"DISK_ENCRYPTION"}}
+	if !anyHost.Matches("DISK_ENCRYPTION", "anything", "anyone") {
+		t.Error("a waiver without hosts or users should match any host and user")
+	}
+}
+
+func TestWaiver_Expired(t *testing.T) {
+	w := Waiver{Expires: "2026-03-31"}
+	lastDay := time.Date(2026, 3, 31, 23, 59, 0, 0, time.Local)
+	dayAfter := time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)
+
+	if w.Expired(lastDay) {
+		t.Error("a waiver applies through its expiry date")
+	}
+	if !w.Expired(dayAfter) {
+		t.Error("a waiver expires the day after its expiry date")
+	}
+	if !(Waiver{Expires: "soon"}).Expired(lastDay) {
+		t.Error("an unparsable expiry is treated as expired")
+	}
+}
+
+func TestFindWaiver(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.Local)
+	waivers := []Waiver{
+		{Codes: []string{"DISK_ENCRYPTION"}, Approver: "old", Expires: "2026-01-31"},
+		{Codes: []string{"DISK_*"}, Approver: "renewed", Expires: "2026-12-31"},
+		{Codes: []string{"SSH_ROOT"}, Approver: "lapsed", Expires: "2026-05-31"},
+	}
+
+	if w, expired := FindWaiver(waivers, "DISK_ENCRYPTION", "h", "u", now); w == nil || expired || w.Approver != "renewed" {
+		t.Errorf("expected the active waiver to win, got %+v (expired %v)", w, expired)
+	}
+	if w, expired := FindWaiver(waivers, "SSH_ROOT", "h", "u", now); w == nil || !expired {
+		t.Errorf("expected the expired waiver, got %+v (expired %v)", w, expired)
+	}
+	if w, _ := FindWaiver(waivers, "FIREWALL", "h", "u", now); w != nil {
+		t.Errorf("expected no waiver, got %+v", w)
+	}
+}
+
+func TestValidateWaivers(t *testing.T) {
+	valid := Waiver{Codes: []string{"DISK_ENCRYPTION"}, Justification: "Kiosk", Approver: "CISO", Expires: "2026-12-31"}
+	if err := ValidateWaivers([]Waiver{valid}); err != nil {
+		t.Errorf("expected a valid waiver, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(w *Waiver)
+		want   string
+	}{
+		{"No codes", func(w *Waiver) { w.Codes = nil }, "waiver #1 has no codes"},
+		{"Malformed pattern", func(w *Waiver) { w.Hosts = []string{"["} }, "malformed pattern '['"},
+		{"No justification", func(w *Waiver) { w.Justification = " " }, "is missing a justification"},
+		{"No approver", func(w *Waiver) { w.Approver = "" }, "is missing an approver"},
+		{"Bad expiry", func(w *Waiver) { w.Expires = "31/12/2026" }, "invalid expires '31/12/2026'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := valid
+			tt.modify(&w)
+			err := ValidateWaivers([]Waiver{w})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateWaivers() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
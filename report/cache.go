@@ -0,0 +1,39 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// CachedCopy records a remote playbook file that was loaded from the local cache because its
+// server could not be reached, and how old it was when the run started.
+type CachedCopy struct {
+	playbook.CachedCopy
+	Age string `json:"age"`
+}
+
+func newCachedCopies(copies []playbook.CachedCopy, start time.Time) []CachedCopy {
+	var cached []CachedCopy
+	for _, c := range copies {
+		cached = append(cached, CachedCopy{CachedCopy: c, Age: start.Sub(c.FetchedAt).Round(time.Second).String()})
+	}
+	return cached
+}
+
+func writeCachedCopiesLog(log *strings.Builder, cached []CachedCopy) {
+	for _, c := range cached {
+		log.WriteString(fmt.Sprintf(">>>>>>>>>> CACHED COPY: %s, fetched %s (%s old) <<<<<<<<<<\n", c.Location, c.FetchedAt.Format(time.RFC3339), c.Age))
+	}
+	log.WriteString("\n")
+}
+
+func writeCachedCopiesMarkdown(md *strings.Builder, cached []CachedCopy) {
+	md.WriteString("> 🗄️ **Offline run:** the server could not be reached, so cached copies were used:\n")
+	for _, c := range cached {
+		md.WriteString(fmt.Sprintf("> - `%s`, fetched %s (%s old)\n", c.Location, c.FetchedAt.Format(time.DateTime), c.Age))
+	}
+	md.WriteString("\n")
+}
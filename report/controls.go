@@ -0,0 +1,122 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// FrameworkSummary rolls assertion verdicts up to the controls of one compliance framework.
+type FrameworkSummary struct {
+	Framework string           `json:"framework"`
+	Controls  []ControlSummary `json:"controls"`
+}
+
+// ControlSummary is the combined outcome of every assertion mapped to a control.
+type ControlSummary struct {
+	ID         string           `json:"id"`
+	URL        string           `json:"url,omitempty"`
+	Verdict    executor.Verdict `json:"verdict"`
+	Assertions []string         `json:"assertions"`
+	Stats      Stats            `json:"stats"`
+}
+
+// collectFrameworks groups assertions by the controls they map to, keeping frameworks and
+// controls in the order they first appear in the playbook.
+func collectFrameworks(trace executor.ExecutionTrace) []FrameworkSummary {
+	var frameworks []FrameworkSummary
+	frameworkIdx := make(map[string]int)
+	controlIdx := make(map[string]map[string]int)
+
+	for _, sectionCtx := range trace.Sections {
+		for _, assCtx := range sectionCtx.Assertions {
+			assertion := assCtx.PlaybookAssertion
+			for _, ref := range assertion.Controls {
+				fi, ok := frameworkIdx[ref.Framework]
+				if !ok {
+					fi = len(frameworks)
+					frameworkIdx[ref.Framework] = fi
+					controlIdx[ref.Framework] = make(map[string]int)
+					frameworks = append(frameworks, FrameworkSummary{Framework: ref.Framework})
+				}
+				framework := &frameworks[fi]
+
+				ci, ok := controlIdx[ref.Framework][ref.ID]
+				if !ok {
+					ci = len(framework.Controls)
+					controlIdx[ref.Framework][ref.ID] = ci
+					framework.Controls = append(framework.Controls, ControlSummary{ID: ref.ID})
+				}
+				control := &framework.Controls[ci]
+				if control.URL == "" {
+					control.URL = ref.URL
+				}
+				control.Assertions = append(control.Assertions, assertion.Code)
+				control.Stats.count(assCtx.Verdict)
+			}
+		}
+	}
+
+	for fi := range frameworks {
+		for ci := range frameworks[fi].Controls {
+			control := &frameworks[fi].Controls[ci]
+			control.Verdict = control.Stats.combinedVerdict()
+		}
+	}
+	return frameworks
+}
+
+// combinedVerdict is the verdict of a group of assertions: any failure fails the group,
+// then any error, then any waived failure; it passes if at least one assertion passed. A
+// group where nothing ran is not applicable, or skipped if all of it was skipped.
+func (s Stats) combinedVerdict() executor.Verdict {
+	switch {
+	case s.Failed > 0:
+		return executor.VerdictFail
+	case s.Errored > 0:
+		return executor.VerdictError
+	case s.Waived > 0:
+		return executor.VerdictWaived
+	case s.Passed > 0:
+		return executor.VerdictPass
+	case s.NotApplicable > 0:
+		return executor.VerdictNotApplicable
+	}
+	return executor.VerdictSkipped
+}
+
+func writeFrameworksMarkdown(md *strings.Builder, frameworks []FrameworkSummary) {
+	md.WriteString("## Framework Coverage\n\n")
+	for _, framework := range frameworks {
+		md.WriteString(fmt.Sprintf("### %s\n\n", framework.Framework))
+		md.WriteString("| Control | Verdict | Assertions |\n")
+		md.WriteString("|---|---|---|\n")
+		for _, control := range framework.Controls {
+			id := control.ID
+			if control.URL != "" {
+				id = fmt.Sprintf("[%s](%s)", control.ID, control.URL)
+			}
+			md.WriteString(fmt.Sprintf("| %s | %s | %s |\n", id, verdictLabel(control.Verdict), strings.Join(control.Assertions, ", ")))
+		}
+		md.WriteString("\n")
+	}
+}
+
+func verdictLabel(v executor.Verdict) string {
+	switch v {
+	case executor.VerdictPass:
+		return "✅ Pass"
+	case executor.VerdictFail:
+		return "❌ Fail"
+	case executor.VerdictError:
+		return "⚠️ Error"
+	case executor.VerdictSkipped:
+		return "⏭️ Skipped"
+	case executor.VerdictNotApplicable:
+		return "➖ N/A"
+	case executor.VerdictWaived:
+		return "📝 Waived"
+	}
+	return string(v)
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_Frameworks(t *testing.T) {
+	cis := func(id string) playbook.ControlRef {
+		return playbook.ControlRef{Framework: "CIS", ID: id, URL: "https://example.com/cis/" + id}
+	}
+	nist := playbook.ControlRef{Framework: "NIST 800-53", ID: "AC-6"}
+	assertion := func(code string, verdict executor.Verdict, controls ...playbook.ControlRef) executor.AssertionContext {
+		return executor.AssertionContext{
+			PlaybookAssertion: playbook.Assertion{Code: code, Title: code, Controls: controls},
+			Verdict:           verdict,
+		}
+	}
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Frameworks"},
+		Sections: []executor.SectionContext{
+			{
+				PlaybookSection: playbook.Section{Title: "S1"},
+				Assertions: []executor.AssertionContext{
+					assertion("SSH_ROOT", executor.VerdictPass, cis("5.2.10"), nist),
+					assertion("SSH_KEYS", executor.VerdictFail, cis("5.2.10")),
+					assertion("SUDO_LOG", executor.VerdictPass, nist),
+					assertion("FIREWALL", executor.VerdictNotApplicable, cis("3.5.1")),
+					assertion("UNMAPPED", executor.VerdictFail),
+				},
+			},
+		},
+	}
+
+	res := GenerateReport(trace)
+
+	frameworks := res.Structured.Frameworks
+	if len(frameworks) != 2 || frameworks[0].Framework != "CIS" || frameworks[1].Framework != "NIST 800-53" {
+		t.Fatalf("unexpected frameworks: %+v", frameworks)
+	}
+
+	tests := []struct {
+		control    ControlSummary
+		id         string
+		verdict    executor.Verdict
+		assertions string
+	}{
+		{frameworks[0].Controls[0], "5.2.10", executor.VerdictFail, "SSH_ROOT,SSH_KEYS"},
+		{frameworks[0].Controls[1], "3.5.1", executor.VerdictNotApplicable, "FIREWALL"},
+		{frameworks[1].Controls[0], "AC-6", executor.VerdictPass, "SSH_ROOT,SUDO_LOG"},
+	}
+	for _, tt := range tests {
+		c := tt.control
+		if c.ID != tt.id || c.Verdict != tt.verdict || strings.Join(c.Assertions, ",") != tt.assertions {
+			t.Errorf("control = %+v; want %s %s covered by %s", c, tt.id, tt.verdict, tt.assertions)
+		}
+	}
+	if c := frameworks[0].Controls[0]; c.Stats.Passed != 1 || c.Stats.Failed != 1 || c.URL != "https://example.com/cis/5.2.10" {
+		t.Errorf("control 5.2.10 = %+v", c)
+	}
+
+	if controls := res.Structured.Assertions["SSH_ROOT"].Controls; len(controls) != 2 {
+		t.Errorf("expected controls to be carried into the JSON report, got %+v", controls)
+	}
+
+	for _, want := range []string{
+		"## Framework Coverage",
+		"### NIST 800-53",
+		"| [5.2.10](https://example.com/cis/5.2.10) | ❌ Fail | SSH_ROOT, SSH_KEYS |",
+		"| AC-6 | ✅ Pass | SSH_ROOT, SUDO_LOG |",
+		"**Controls:** CIS 5.2.10, NIST 800-53 AC-6",
+	} {
+		if !strings.Contains(res.Markdown, want) {
+			t.Errorf("expected %q in markdown", want)
+		}
+	}
+}
+
+func TestStats_CombinedVerdict(t *testing.T) {
+	tests := []struct {
+		stats Stats
+		want  executor.Verdict
+	}{
+		{Stats{Passed: 3, Failed: 1}, executor.VerdictFail},
+		{Stats{Passed: 3, Errored: 1}, executor.VerdictError},
+		{Stats{Passed: 1, NotApplicable: 2}, executor.VerdictPass},
+		{Stats{NotApplicable: 1, Skipped: 1}, executor.VerdictNotApplicable},
+		{Stats{Skipped: 2}, executor.VerdictSkipped},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.combinedVerdict(); got != tt.want {
+			t.Errorf("%+v.combinedVerdict() = %s; want %s", tt.stats, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+type htmlReport struct {
+	Title       string
+	Generated   string
+	Frontmatter []htmlField
+	Facts       []htmlField
+	Notes       []string
+	Stats       Stats
+	Score       string
+	Sections    []htmlSection
+}
+
+type htmlField struct {
+	Name  string
+	Value string
+}
+
+type htmlSection struct {
+	Title       string
+	Description []string
+	Score       string
+	Stats       Stats
+	Assertions  []htmlAssertion
+}
+
+type htmlAssertion struct {
+	Code        string
+	Title       string
+	Description string
+	Verdict     executor.Verdict
+	Severity    string
+	Controls    string
+	Evidence    string
+	// Outcome explains the verdict, like the blockquote of the markdown report.
+	Outcome  string
+	Details  []string
+	Timeouts []Timeout
+	Waiver   *Waiver
+}
+
+// renderHTML renders the report as a single HTML page, readable offline: styles and the
+// verdict filter are inlined, and nothing is loaded from elsewhere.
+func renderHTML(trace executor.ExecutionTrace, res FinalReport) ([]byte, error) {
+	config := trace.Playbook
+	page := htmlReport{
+		Title: config.Title,
+		Stats: res.Stats,
+		Score: res.Stats.scoreLabel(),
+	}
+	if !res.Timestamps.Start.IsZero() {
+		page.Generated = res.Timestamps.Start.Format(time.DateTime)
+	}
+
+	keys := make([]string, 0, len(config.ReportFrontmatter))
+	for k := range config.ReportFrontmatter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		page.Frontmatter = append(page.Frontmatter, htmlField{k, fmt.Sprint(config.ReportFrontmatter[k])})
+	}
+	for _, f := range []htmlField{{"Host", res.Hostname}, {"User", res.Username}, {"OS", res.OS}, {"Arch", res.Arch}} {
+		if f.Value != "" {
+			page.Facts = append(page.Facts, f)
+		}
+	}
+
+	if res.Selection != nil {
+		page.Notes = append(page.Notes, fmt.Sprintf("Partial run: only assertions matching %s were executed; the others are reported as skipped.", res.Selection))
+	}
+	if n := res.Stats.ExpiredWaivers; n > 0 {
+		page.Notes = append(page.Notes, fmt.Sprintf("%d expired waiver(s): the failures they covered count again.", n))
+	}
+	for _, c := range res.CachedCopies {
+		page.Notes = append(page.Notes, fmt.Sprintf("Offline: %s was loaded from the cached copy fetched %s.", c.Location, c.FetchedAt.Format(time.DateTime)))
+	}
+
+	for i, sectionCtx := range trace.Sections {
+		section := htmlSection{
+			Title:       sectionCtx.PlaybookSection.Title,
+			Description: sectionCtx.PlaybookSection.Description,
+		}
+		if i < len(res.Sections) {
+			section.Stats = res.Sections[i].Stats
+			section.Score = section.Stats.scoreLabel()
+		}
+		for _, a := range sectionCtx.Assertions {
+			section.Assertions = append(section.Assertions, newHTMLAssertion(a))
+		}
+		page.Sections = append(page.Sections, section)
+	}
+
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, page); err != nil {
+		return nil, fmt.Errorf("failed to render the HTML report: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+func newHTMLAssertion(a executor.AssertionContext) htmlAssertion {
+	assertion := a.PlaybookAssertion
+	out := htmlAssertion{
+		Code:        assertion.Code,
+		Title:       assertion.Title,
+		Description: assertion.Description,
+		Verdict:     a.Verdict,
+		Severity:    string(assertion.GetSeverity()),
+		Evidence:    assertionEvidence(a),
+		Timeouts:    collectTimeouts(a),
+		Waiver:      newWaiver(a),
+	}
+	var refs []string
+	for _, c := range assertion.Controls {
+		refs = append(refs, c.Framework+" "+c.ID)
+	}
+	out.Controls = strings.Join(refs, ", ")
+
+	switch a.Verdict {
+	case executor.VerdictPass:
+		out.Outcome = "Assertion Passed"
+		if assertion.PassDescription != "" {
+			out.Outcome = "Pass: " + assertion.PassDescription
+		}
+	case executor.VerdictError:
+		out.Outcome = "Error: The assertion could not be evaluated."
+		out.Details = a.Errors
+	case executor.VerdictSkipped:
+		out.Outcome = "Skipped" + reasonSuffix(a.Reason)
+	case executor.VerdictNotApplicable:
+		out.Outcome = "Not Applicable" + reasonSuffix(a.Reason)
+	case executor.VerdictWaived:
+		out.Outcome = "Waived failure: " + assertion.FailDescription
+	default:
+		out.Outcome = "Assertion Failed"
+		if assertion.FailDescription != "" {
+			out.Outcome = "Fail: " + assertion.FailDescription
+		}
+	}
+	return out
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"open": func(v executor.Verdict) bool {
+		return v == executor.VerdictFail || v == executor.VerdictError
+	},
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 0 auto; max-width: 1000px; padding: 1.5em; color: #1f2328; line-height: 1.5; }
+h1 { margin-bottom: 0.2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.7em; text-align: left; }
+th { background: #f6f8fa; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 6px; padding: 0.8em; overflow-x: auto; white-space: pre-wrap; }
+.muted { color: #656d76; }
+.note { border-left: 4px solid #bf8700; background: #fff8c5; padding: 0.5em 1em; margin: 0.5em 0; }
+.cards { display: flex; flex-wrap: wrap; gap: 0.7em; margin: 1em 0; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.5em 1em; min-width: 6em; text-align: center; }
+.card b { display: block; font-size: 1.6em; }
+.filter { margin: 1em 0; }
+.filter button { border: 1px solid #d0d7de; border-radius: 6px; background: #fff; padding: 0.3em 0.8em; cursor: pointer; }
+.filter button.active { background: #0969da; border-color: #0969da; color: #fff; }
+section > details > summary { font-size: 1.3em; font-weight: 600; cursor: pointer; margin: 1em 0 0.5em; }
+.assertion { border: 1px solid #d0d7de; border-left-width: 6px; border-radius: 6px; margin: 0.6em 0; padding: 0 1em; }
+.assertion > summary { cursor: pointer; padding: 0.6em 0; font-weight: 600; }
+.badge { display: inline-block; border-radius: 1em; padding: 0 0.6em; font-size: 0.8em; font-weight: 600; color: #fff; margin-right: 0.5em; text-transform: uppercase; }
+.pass { border-left-color: #1a7f37; } .badge.pass { background: #1a7f37; }
+.fail { border-left-color: #cf222e; } .badge.fail { background: #cf222e; }
+.error { border-left-color: #bc4c00; } .badge.error { background: #bc4c00; }
+.waived { border-left-color: #8250df; } .badge.waived { background: #8250df; }
+.skipped, .not_applicable { border-left-color: #8c959f; } .badge.skipped, .badge.not_applicable { background: #8c959f; }
+body.only-pass .assertion:not(.pass), body.only-fail .assertion:not(.fail):not(.error), body.only-other .assertion.pass, body.only-other .assertion.fail, body.only-other .assertion.error { display: none; }
+@media print { .filter { display: none; } details { display: block; } }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+{{if .Generated}}<p class="muted">Generated on {{.Generated}}</p>{{end}}
+{{if .Frontmatter}}<table class="frontmatter">
+{{range .Frontmatter}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Facts}}<p class="muted">{{range $i, $f := .Facts}}{{if $i}} · {{end}}{{$f.Name}}: <b>{{$f.Value}}</b>{{end}}</p>{{end}}
+{{range .Notes}}<div class="note">{{.}}</div>
+{{end}}</header>
+<h2>Summary</h2>
+<div class="cards">
+<div class="card"><b>{{.Score}}</b>Score</div>
+<div class="card pass"><b>{{.Stats.Passed}}</b>Pass</div>
+<div class="card fail"><b>{{.Stats.Failed}}</b>Fail</div>
+<div class="card error"><b>{{.Stats.Errored}}</b>Error</div>
+<div class="card skipped"><b>{{.Stats.Skipped}}</b>Skipped</div>
+<div class="card not_applicable"><b>{{.Stats.NotApplicable}}</b>N/A</div>
+<div class="card waived"><b>{{.Stats.Waived}}</b>Waived</div>
+</div>
+{{if .Sections}}<table class="summary">
+<tr><th>Section</th><th>Score</th><th>Pass</th><th>Fail</th><th>Error</th><th>Skipped</th><th>N/A</th><th>Waived</th></tr>
+{{range .Sections}}<tr><td>{{.Title}}</td><td>{{.Score}}</td><td>{{.Stats.Passed}}</td><td>{{.Stats.Failed}}</td><td>{{.Stats.Errored}}</td><td>{{.Stats.Skipped}}</td><td>{{.Stats.NotApplicable}}</td><td>{{.Stats.Waived}}</td></tr>
+{{end}}</table>{{end}}
+<div class="filter">Show:
+<button type="button" class="active" data-filter="">All</button>
+<button type="button" data-filter="only-fail">Failed</button>
+<button type="button" data-filter="only-pass">Passed</button>
+<button type="button" data-filter="only-other">Other</button>
+</div>
+{{range .Sections}}<section>
+<details open>
+<summary>{{.Title}}{{if .Score}} <span class="muted">· {{.Score}}</span>{{end}}</summary>
+{{range .Description}}<p>{{.}}</p>
+{{end}}{{range .Assertions}}<details class="assertion {{.Verdict}}" id="{{.Code}}"{{if open .Verdict}} open{{end}}>
+<summary><span class="badge {{.Verdict}}">{{.Verdict}}</span>{{.Title}} <span class="muted">{{.Code}}</span></summary>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<p class="muted">Severity: {{.Severity}}{{if .Controls}} · Controls: {{.Controls}}{{end}}</p>
+<p><b>{{.Outcome}}</b></p>
+{{if .Details}}<ul>{{range .Details}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{range .Timeouts}}<div class="note">Timed out: {{.Stage}} #{{inc .Index}} exceeded its {{.Timeout}} timeout and was terminated.</div>
+{{end}}{{with .Waiver}}<div class="note">{{if .Expired}}Expired waiver: this failure was waived until {{.Expires}} (approved by {{.Approver}}: {{.Justification}}). The waiver no longer applies.{{else}}Waiver: {{.Justification}}. Approved by {{.Approver}}, expires {{.Expires}}.{{end}}</div>
+{{end}}{{if .Evidence}}<p>Evidence:</p>
+<pre>{{.Evidence}}</pre>
+{{end}}</details>
+{{end}}</details>
+</section>
+{{end}}<script>
+document.querySelectorAll(".filter button").forEach(function (button) {
+  button.addEventListener("click", function () {
+    document.querySelectorAll(".filter button").forEach(function (b) { b.classList.remove("active"); });
+    button.classList.add("active");
+    document.body.className = button.dataset.filter;
+  });
+});
+</script>
+</body>
+</html>
+`))
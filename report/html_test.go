@@ -0,0 +1,63 @@
+package report
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_HTML(t *testing.T) {
+	trace := sampleTrace(playbook.OutputHTML)
+	trace.Playbook.ReportFrontmatter = map[string]interface{}{"auditor": "Compliance Team"}
+	trace.Sections[0].Assertions[0].Outputs = []string{"<script>alert(1)</script>"}
+
+	res := GenerateReport(trace)
+	if len(res.Outputs) != 1 || res.Outputs[0].Name() != "report.html" || res.Outputs[0].ContentType != "text/html" {
+		t.Fatalf("expected a report.html output, got %+v", res.Outputs)
+	}
+	page := string(res.Outputs[0].Content)
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>Quarterly Audit</title>",
+		"<th>auditor</th><td>Compliance Team</td>",
+		"<th>date</th><td>2026-10-16</td>",
+		"Host: <b>laptop-42</b>",
+		"Remote access hardening",
+		`<details class="assertion fail" id="SSH_ROOT" open>`,
+		`<details class="assertion pass" id="UPDATES">`,
+		"Fail: PermitRootLogin is enabled",
+		"Controls: NIST 800-53 AC-6 (2), CIS Ubuntu 22.04 5.2.10",
+		"Pass: Up to date",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`data-filter="only-fail"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in the HTML report", want)
+		}
+	}
+	if strings.Contains(page, "<script>alert") {
+		t.Errorf("expected the evidence to be escaped")
+	}
+	for _, external := range []string{"<link", "src=", "@import"} {
+		if strings.Contains(page, external) {
+			t.Errorf("expected no external assets, found %q", external)
+		}
+	}
+}
+
+func TestGenerateReport_HTMLError(t *testing.T) {
+	oldTemplate := htmlTemplate
+	htmlTemplate = template.Must(template.New("report").Parse("{{.Missing}}"))
+	defer func() { htmlTemplate = oldTemplate }()
+
+	res := GenerateReport(sampleTrace(playbook.OutputHTML))
+	if len(res.Outputs) != 0 {
+		t.Errorf("expected no output when the HTML report fails to render, got %+v", res.Outputs)
+	}
+	if !strings.Contains(res.Log, ">>> ERROR: failed to render the HTML report") {
+		t.Errorf("expected the render error in the report log, got:\n%s", res.Log)
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// JUnit XML, in the dialect understood by CI servers (Jenkins, GitLab, Azure DevOps).
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Hostname  string          `xml:"hostname,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// renderJUnit renders the report as JUnit XML, with a testsuite per section and a testcase
+// per assertion. Waived failures are reported as skipped, as they do not fail the run.
+func renderJUnit(trace executor.ExecutionTrace, res FinalReport) []byte {
+	suites := junitTestSuites{Name: trace.Playbook.Title, Time: junitDuration(res.Timestamps.Start, res.Timestamps.End)}
+	for _, sectionCtx := range trace.Sections {
+		suite := junitTestSuite{Name: sectionCtx.PlaybookSection.Title, Hostname: res.Hostname}
+		var duration time.Duration
+		for _, a := range sectionCtx.Assertions {
+			assertion := a.PlaybookAssertion
+			tc := junitTestCase{
+				Name:      assertion.Code + ": " + assertion.Title,
+				ClassName: suite.Name,
+				Time:      junitDuration(a.Timestamps.Start, a.Timestamps.End),
+				SystemOut: assertionEvidence(a),
+			}
+			if !a.Timestamps.Start.IsZero() {
+				if suite.Timestamp == "" {
+					suite.Timestamp = a.Timestamps.Start.Format("2006-01-02T15:04:05")
+				}
+				duration += a.Timestamps.End.Sub(a.Timestamps.Start)
+			}
+			switch a.Verdict {
+			case executor.VerdictFail:
+				message := assertion.FailDescription
+				if message == "" {
+					message = "assertion failed"
+				}
+				tc.Failure = &junitMessage{Message: message, Type: string(assertion.GetSeverity())}
+				if w := newWaiver(a); w != nil {
+					tc.Failure.Text = fmt.Sprintf("Waiver expired on %s (approved by %s: %s)", w.Expires, w.Approver, w.Justification)
+				}
+				suite.Failures++
+			case executor.VerdictError:
+				tc.Error = &junitMessage{Message: "the assertion could not be evaluated", Text: strings.Join(a.Errors, "\n")}
+				suite.Errors++
+			case executor.VerdictWaived, executor.VerdictSkipped, executor.VerdictNotApplicable:
+				message := string(a.Verdict)
+				if w := newWaiver(a); w != nil {
+					message = fmt.Sprintf("waived until %s, approved by %s: %s", w.Expires, w.Approver, w.Justification)
+				} else if a.Reason != "" {
+					message += ": " + a.Reason
+				}
+				tc.Skipped = &junitMessage{Message: message}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = fmt.Sprintf("%.3f", duration.Seconds())
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	out, _ := xml.MarshalIndent(suites, "", "  ")
+	return append([]byte(xml.Header), out...)
+}
+
+// junitDuration returns the seconds between start and end, as JUnit time attributes.
+func junitDuration(start, end time.Time) string {
+	if start.IsZero() || end.Before(start) {
+		return "0.000"
+	}
+	return fmt.Sprintf("%.3f", end.Sub(start).Seconds())
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_JUnit(t *testing.T) {
+	res := GenerateReport(sampleTrace(playbook.OutputJUnit))
+	if len(res.Outputs) != 1 || res.Outputs[0].Name() != "report.junit.xml" {
+		t.Fatalf("expected a report.junit.xml output, got %+v", res.Outputs)
+	}
+	content := res.Outputs[0].Content
+	if !strings.HasPrefix(string(content), "<?xml") {
+		t.Errorf("expected an XML declaration, got %.40q", content)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(content, &suites); err != nil {
+		t.Fatalf("invalid JUnit XML: %v", err)
+	}
+	if suites.Tests != 5 || suites.Failures != 1 || suites.Errors != 1 || suites.Skipped != 2 {
+		t.Errorf("totals = %d tests, %d failures, %d errors, %d skipped", suites.Tests, suites.Failures, suites.Errors, suites.Skipped)
+	}
+	if len(suites.Suites) != 2 || suites.Suites[0].Name != "Access" || suites.Suites[0].Hostname != "laptop-42" {
+		t.Fatalf("expected a testsuite per section, got %+v", suites.Suites)
+	}
+
+	access := suites.Suites[0]
+	if access.Time != "1.500" || access.Timestamp != "2026-10-16T08:00:00" {
+		t.Errorf("Access suite time = %s, timestamp = %s", access.Time, access.Timestamp)
+	}
+	ssh := access.Cases[0]
+	if ssh.Name != "SSH_ROOT: SSH Root Login" || ssh.ClassName != "Access" || ssh.Time != "1.500" {
+		t.Errorf("testcase = %+v", ssh)
+	}
+	if ssh.Failure == nil || ssh.Failure.Message != "PermitRootLogin is enabled" || ssh.Failure.Type != "critical" {
+		t.Errorf("failure = %+v", ssh.Failure)
+	}
+	if ssh.SystemOut != "PermitRootLogin yes" {
+		t.Errorf("expected the evidence in system-out, got %q", ssh.SystemOut)
+	}
+	if firewall := access.Cases[2]; firewall.Error == nil || firewall.Error.Text != "ufw: command not found" {
+		t.Errorf("error = %+v", firewall.Error)
+	}
+
+	if waived := access.Cases[1]; waived.Skipped == nil || !strings.Contains(waived.Skipped.Message, "approved by CISO") {
+		t.Errorf("expected the waived assertion to be skipped, got %+v", waived)
+	}
+
+	platform := suites.Suites[1].Cases
+	if platform[0].Skipped == nil || platform[0].Skipped.Message != "not_applicable: platform linux not in [windows]" {
+		t.Errorf("expected the not applicable assertion to be skipped, got %+v", platform[0].Skipped)
+	}
+	if platform[1].Failure != nil || platform[1].Error != nil || platform[1].Skipped != nil {
+		t.Errorf("expected the passed assertion to have no outcome element, got %+v", platform[1])
+	}
+}
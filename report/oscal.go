@@ -0,0 +1,348 @@
+package report
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// OSCALVersion is the version of the OSCAL assessment-results model rendered.
+const OSCALVersion = "1.1.2"
+
+// oscalNS qualifies the props that are specific to crobe.
+const oscalNS = "https://github.com/benedictjohannes/crobe/ns/oscal"
+
+// OSCAL assessment-results, limited to what crobe reports. See
+// https://pages.nist.gov/OSCAL/reference/latest/assessment-results/json-reference/
+type oscalDocument struct {
+	AssessmentResults oscalAssessmentResults `json:"assessment-results"`
+}
+
+type oscalAssessmentResults struct {
+	UUID     string        `json:"uuid"`
+	Metadata oscalMetadata `json:"metadata"`
+	ImportAP oscalImportAP `json:"import-ap"`
+	Results  []oscalResult `json:"results"`
+}
+
+type oscalMetadata struct {
+	Title        string `json:"title"`
+	LastModified string `json:"last-modified"`
+	Version      string `json:"version"`
+	OSCALVersion string `json:"oscal-version"`
+}
+
+type oscalImportAP struct {
+	Href string `json:"href"`
+}
+
+type oscalResult struct {
+	UUID             string                `json:"uuid"`
+	Title            string                `json:"title"`
+	Description      string                `json:"description"`
+	Start            string                `json:"start"`
+	End              string                `json:"end,omitempty"`
+	Props            []oscalProp           `json:"props,omitempty"`
+	LocalDefinitions oscalLocalDefinitions `json:"local-definitions"`
+	ReviewedControls oscalReviewedControls `json:"reviewed-controls"`
+	Observations     []oscalObservation    `json:"observations,omitempty"`
+	Findings         []oscalFinding        `json:"findings,omitempty"`
+}
+
+type oscalProp struct {
+	Name  string `json:"name"`
+	NS    string `json:"ns,omitempty"`
+	Value string `json:"value"`
+}
+
+type oscalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type oscalLocalDefinitions struct {
+	InventoryItems []oscalInventoryItem `json:"inventory-items"`
+}
+
+type oscalInventoryItem struct {
+	UUID        string      `json:"uuid"`
+	Description string      `json:"description"`
+	Props       []oscalProp `json:"props,omitempty"`
+}
+
+type oscalReviewedControls struct {
+	ControlSelections []oscalControlSelection `json:"control-selections"`
+}
+
+type oscalControlSelection struct {
+	IncludeAll      *struct{}            `json:"include-all,omitempty"`
+	IncludeControls []oscalSelectControl `json:"include-controls,omitempty"`
+}
+
+type oscalSelectControl struct {
+	ControlID string `json:"control-id"`
+}
+
+type oscalObservation struct {
+	UUID             string          `json:"uuid"`
+	Title            string          `json:"title"`
+	Description      string          `json:"description"`
+	Props            []oscalProp     `json:"props"`
+	Links            []oscalLink     `json:"links,omitempty"`
+	Methods          []string        `json:"methods"`
+	Subjects         []oscalSubject  `json:"subjects"`
+	RelevantEvidence []oscalEvidence `json:"relevant-evidence"`
+	Collected        string          `json:"collected"`
+	Remarks          string          `json:"remarks,omitempty"`
+}
+
+type oscalSubject struct {
+	SubjectUUID string `json:"subject-uuid"`
+	Type        string `json:"type"`
+	Title       string `json:"title,omitempty"`
+}
+
+type oscalEvidence struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+}
+
+type oscalFinding struct {
+	UUID                string                    `json:"uuid"`
+	Title               string                    `json:"title"`
+	Description         string                    `json:"description"`
+	Props               []oscalProp               `json:"props,omitempty"`
+	Target              oscalTarget               `json:"target"`
+	RelatedObservations []oscalRelatedObservation `json:"related-observations"`
+	Remarks             string                    `json:"remarks,omitempty"`
+}
+
+type oscalTarget struct {
+	Type     string      `json:"type"`
+	TargetID string      `json:"target-id"`
+	Status   oscalStatus `json:"status"`
+}
+
+type oscalStatus struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type oscalRelatedObservation struct {
+	ObservationUUID string `json:"observation-uuid"`
+}
+
+// renderOSCAL renders the report as an OSCAL assessment-results document: an observation per
+// assertion, and a finding for every failure (per declared control, or for the assertion
+// itself). It only needs the JSON report, so that existing reports can be converted too.
+// UUIDs are derived from the host, the run start and the assertion codes: converting the
+// same report twice gives the same document.
+func renderOSCAL(res FinalReport) []byte {
+	start := utcTime(res.Timestamps.Start)
+	end := utcTime(res.Timestamps.End)
+	if start == "" {
+		start = time.Now().UTC().Format(time.RFC3339)
+	}
+	runID := res.Hostname + "|" + start
+	title := res.Title
+	if title == "" {
+		title = "crobe assessment"
+	}
+
+	subject := oscalInventoryItem{
+		UUID:        oscalUUID(runID, "subject"),
+		Description: fmt.Sprintf("Device %s, assessed as %s", res.Hostname, res.Username),
+	}
+	for _, p := range []oscalProp{
+		{Name: "fqdn", Value: res.Hostname},
+		{Name: "user", NS: oscalNS, Value: res.Username},
+		{Name: "os", NS: oscalNS, Value: res.OS},
+		{Name: "arch", NS: oscalNS, Value: res.Arch},
+	} {
+		if p.Value != "" {
+			subject.Props = append(subject.Props, p)
+		}
+	}
+
+	result := oscalResult{
+		UUID:             oscalUUID(runID, "result"),
+		Title:            title,
+		Description:      "Results of the crobe playbook " + title + " on " + res.Hostname + ".",
+		Start:            start,
+		End:              end,
+		LocalDefinitions: oscalLocalDefinitions{InventoryItems: []oscalInventoryItem{subject}},
+	}
+	if res.Stats.Score != nil {
+		result.Props = append(result.Props,
+			oscalProp{Name: "score", NS: oscalNS, Value: fmt.Sprintf("%.1f", *res.Stats.Score)},
+			oscalProp{Name: "grade", NS: oscalNS, Value: res.Stats.Grade})
+	}
+
+	controls := make(map[string]bool)
+	var controlIDs []string
+	for _, code := range orderedCodes(res) {
+		a := res.Assertions[code]
+		observation := oscalObservation{
+			UUID:        oscalUUID(runID, "observation", code),
+			Title:       assertionTitle(code, a),
+			Description: fmt.Sprintf("Assertion %s ended with verdict %s.", code, a.Verdict),
+			Props: []oscalProp{
+				{Name: "assertion-code", NS: oscalNS, Value: code},
+				{Name: "verdict", NS: oscalNS, Value: string(a.Verdict)},
+			},
+			Methods:  []string{"TEST"},
+			Subjects: []oscalSubject{{SubjectUUID: subject.UUID, Type: "inventory-item", Title: res.Hostname}},
+			RelevantEvidence: []oscalEvidence{
+				{Href: "report.json#/assertions/" + code, Description: "Structured result of the assertion in the crobe JSON report."},
+				{Href: "report.log", Description: "Execution log of the crobe run, with the commands and outputs of the assertion."},
+			},
+			Collected: utcTime(a.Timestamps.End),
+			Remarks:   oscalRemarks(a),
+		}
+		if a.Severity != "" {
+			observation.Props = append(observation.Props, oscalProp{Name: "severity", NS: oscalNS, Value: string(a.Severity)})
+		}
+		if a.Section != "" {
+			observation.Props = append(observation.Props, oscalProp{Name: "section", NS: oscalNS, Value: a.Section})
+		}
+		if observation.Collected == "" {
+			observation.Collected = end
+		}
+		if observation.Collected == "" {
+			observation.Collected = start
+		}
+		for _, c := range a.Controls {
+			observation.Props = append(observation.Props, oscalProp{Name: "control", NS: oscalNS, Value: c.Framework + " " + c.ID})
+			if c.URL != "" {
+				observation.Links = append(observation.Links, oscalLink{Href: c.URL, Rel: "reference", Text: c.Framework + " " + c.ID})
+			}
+			id := oscalControlID(c.ID)
+			if !controls[id] {
+				controls[id] = true
+				controlIDs = append(controlIDs, id)
+			}
+		}
+		result.Observations = append(result.Observations, observation)
+
+		if a.Verdict != executor.VerdictFail && a.Verdict != executor.VerdictWaived {
+			continue
+		}
+		targets := []oscalTarget{{Type: "objective-id", TargetID: code}}
+		if len(a.Controls) > 0 {
+			targets = nil
+			for _, c := range a.Controls {
+				targets = append(targets, oscalTarget{Type: "statement-id", TargetID: oscalControlID(c.ID) + "_smt"})
+			}
+		}
+		for _, target := range targets {
+			target.Status = oscalStatus{State: "not-satisfied"}
+			finding := oscalFinding{
+				UUID:                oscalUUID(runID, "finding", code, target.TargetID),
+				Title:               assertionTitle(code, a),
+				Description:         fmt.Sprintf("Assertion %s failed on %s.", code, res.Hostname),
+				Props:               observation.Props[1:],
+				Target:              target,
+				RelatedObservations: []oscalRelatedObservation{{ObservationUUID: observation.UUID}},
+			}
+			if w := a.Waiver; w != nil && !w.Expired {
+				finding.Remarks = fmt.Sprintf("Risk accepted until %s, approved by %s: %s", w.Expires, w.Approver, w.Justification)
+			}
+			result.Findings = append(result.Findings, finding)
+		}
+	}
+
+	if len(controlIDs) == 0 {
+		result.ReviewedControls.ControlSelections = []oscalControlSelection{{IncludeAll: &struct{}{}}}
+	} else {
+		selection := oscalControlSelection{}
+		for _, id := range controlIDs {
+			selection.IncludeControls = append(selection.IncludeControls, oscalSelectControl{ControlID: id})
+		}
+		result.ReviewedControls.ControlSelections = []oscalControlSelection{selection}
+	}
+
+	lastModified := end
+	if lastModified == "" {
+		lastModified = start
+	}
+	out, _ := json.MarshalIndent(oscalDocument{AssessmentResults: oscalAssessmentResults{
+		UUID: oscalUUID(runID, "assessment-results"),
+		Metadata: oscalMetadata{
+			Title:        title,
+			LastModified: lastModified,
+			Version:      "1.0",
+			OSCALVersion: OSCALVersion,
+		},
+		// crobe has no assessment plan: the playbook plays its part
+		ImportAP: oscalImportAP{Href: "#"},
+		Results:  []oscalResult{result},
+	}}, "", "  ")
+	return out
+}
+
+// orderedCodes returns the assertion codes in the order of their sections, then by code.
+func orderedCodes(res FinalReport) []string {
+	order := make(map[string]int)
+	for i, s := range res.Sections {
+		if _, ok := order[s.Title]; !ok {
+			order[s.Title] = i
+		}
+	}
+	codes := make([]string, 0, len(res.Assertions))
+	for code := range res.Assertions {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		si, sj := order[res.Assertions[codes[i]].Section], order[res.Assertions[codes[j]].Section]
+		if si != sj {
+			return si < sj
+		}
+		return codes[i] < codes[j]
+	})
+	return codes
+}
+
+func assertionTitle(code string, a Assertion) string {
+	if a.Title == "" {
+		return code
+	}
+	return a.Title
+}
+
+func oscalRemarks(a Assertion) string {
+	var remarks []string
+	if a.Reason != "" {
+		remarks = append(remarks, a.Reason)
+	}
+	remarks = append(remarks, a.Errors...)
+	if w := a.Waiver; w != nil {
+		if w.Expired {
+			remarks = append(remarks, fmt.Sprintf("Waiver expired on %s (approved by %s: %s)", w.Expires, w.Approver, w.Justification))
+		} else {
+			remarks = append(remarks, fmt.Sprintf("Waived until %s, approved by %s: %s", w.Expires, w.Approver, w.Justification))
+		}
+	}
+	return strings.Join(remarks, "\n")
+}
+
+// oscalControlID turns a control identifier into the form of OSCAL catalogs, eg: AC-6 (1)
+// into ac-6.1.
+func oscalControlID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	id = strings.NewReplacer(" (", ".", "(", ".", ")", "", " ", "-").Replace(id)
+	return id
+}
+
+// oscalUUID returns a name-based (version 5) UUID for the parts.
+func oscalUUID(parts ...string) string {
+	sum := sha1.Sum([]byte(oscalNS + "|" + strings.Join(parts, "|")))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/benedictjohannes/crobe/playbook"
+	"testing"
+)
+
+func TestGenerateReport_OSCAL(t *testing.T) {
+	res := GenerateReport(sampleTrace(playbook.OutputOSCAL))
+	if len(res.Outputs) != 1 || res.Outputs[0].Name() != "report.oscal.json" {
+		t.Fatalf("expected a report.oscal.json output, got %+v", res.Outputs)
+	}
+	var doc oscalDocument
+	if err := json.Unmarshal(res.Outputs[0].Content, &doc); err != nil {
+		t.Fatalf("invalid OSCAL JSON: %v", err)
+	}
+	ar := doc.AssessmentResults
+	if ar.Metadata.Title != "Quarterly Audit" || ar.Metadata.OSCALVersion != OSCALVersion || len(ar.Results) != 1 {
+		t.Fatalf("assessment-results = %+v", ar)
+	}
+	result := ar.Results[0]
+	if result.Start != "2026-10-16T08:00:00Z" || result.End != "2026-10-16T08:01:00Z" {
+		t.Errorf("result start = %s, end = %s", result.Start, result.End)
+	}
+
+	subject := result.LocalDefinitions.InventoryItems[0]
+	facts := make(map[string]string)
+	for _, p := range subject.Props {
+		facts[p.Name] = p.Value
+	}
+	if facts["fqdn"] != "laptop-42" || facts["user"] != "alice" || facts["os"] != "linux" || facts["arch"] != "amd64" {
+		t.Errorf("subject props = %+v", subject.Props)
+	}
+
+	if len(result.Observations) != 5 {
+		t.Fatalf("expected an observation per assertion, got %d", len(result.Observations))
+	}
+	// Sections keep their order, assertions are sorted by code within them
+	for i, want := range []string{"Disk Encryption", "Firewall", "SSH Root Login", "BitLocker", "Updates"} {
+		if result.Observations[i].Title != want {
+			t.Errorf("observation %d = %q, want %q", i, result.Observations[i].Title, want)
+		}
+	}
+	ssh := result.Observations[2]
+	if ssh.Subjects[0].SubjectUUID != subject.UUID || ssh.RelevantEvidence[0].Href != "report.json#/assertions/SSH_ROOT" {
+		t.Errorf("observation = %+v", ssh)
+	}
+	if len(ssh.Links) != 1 || ssh.Links[0].Href != "https://example.com/ac-6" {
+		t.Errorf("expected the control URL as link, got %+v", ssh.Links)
+	}
+
+	selection := result.ReviewedControls.ControlSelections[0]
+	if len(selection.IncludeControls) != 2 || selection.IncludeControls[0].ControlID != "ac-6.2" || selection.IncludeControls[1].ControlID != "5.2.10" {
+		t.Errorf("reviewed controls = %+v", selection)
+	}
+
+	// A finding per control of the failed assertion, and one for the waived assertion
+	if len(result.Findings) != 3 {
+		t.Fatalf("expected 3 findings, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.Target.Type != "objective-id" || f.Target.TargetID != "DISK_ENCRYPTION" || f.Remarks == "" {
+		t.Errorf("waived finding = %+v", f)
+	}
+	if f := result.Findings[1]; f.Target.TargetID != "ac-6.2_smt" || f.Target.Status.State != "not-satisfied" || f.RelatedObservations[0].ObservationUUID != ssh.UUID {
+		t.Errorf("control finding = %+v", f)
+	}
+
+	// Converting the JSON report gives the same document
+	raw, _ := json.Marshal(res.Structured)
+	var parsed FinalReport
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := ConvertReport(parsed, playbook.OutputOSCAL)
+	if err != nil {
+		t.Fatalf("ConvertReport() error = %v", err)
+	}
+	if !bytes.Equal(converted.Content, res.Outputs[0].Content) {
+		t.Errorf("expected the converted JSON report to match the agent output")
+	}
+	if _, err := ConvertReport(parsed, playbook.OutputSARIF); err == nil {
+		t.Errorf("expected SARIF not to be convertible from a JSON report")
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// Output is the report rendered in one of the playbook's reportOutputs.
+type Output struct {
+	Format playbook.OutputFormat
+	// Ext is the extension of the output file, appended to the report file name.
+	Ext         string
+	ContentType string
+	Content     []byte
+}
+
+// Name returns the file name of the output in submissions, eg: report.sarif.
+func (o Output) Name() string {
+	return "report" + o.Ext
+}
+
+// renderOutputs renders the report in each of the playbook's reportOutputs. Formats are
+// validated with the playbook, so unknown ones are ignored. Outputs that fail to render are
+// left out, with the error written to the report log.
+func renderOutputs(trace executor.ExecutionTrace, res FinalReport, log *strings.Builder) []Output {
+	var outputs []Output
+	for _, format := range trace.Playbook.ReportOutputs {
+		switch format {
+		case playbook.OutputSARIF:
+			outputs = append(outputs, Output{Format: format, Ext: ".sarif", ContentType: "application/sarif+json", Content: renderSARIF(trace, res)})
+		case playbook.OutputJUnit:
+			outputs = append(outputs, Output{Format: format, Ext: ".junit.xml", ContentType: "application/xml", Content: renderJUnit(trace, res)})
+		case playbook.OutputHTML:
+			content, err := renderHTML(trace, res)
+			if err != nil {
+				log.WriteString(fmt.Sprintf(">>> ERROR: %v <<<\n", err))
+				continue
+			}
+			outputs = append(outputs, Output{Format: format, Ext: ".html", ContentType: "text/html", Content: content})
+		case playbook.OutputOSCAL, playbook.OutputXCCDF, playbook.OutputOpenMetrics:
+			out, err := ConvertReport(res, format)
+			if err != nil {
+				log.WriteString(fmt.Sprintf(">>> ERROR: %v <<<\n", err))
+				continue
+			}
+			outputs = append(outputs, out)
+		}
+	}
+	return outputs
+}
+
+// ConvertReport renders an existing JSON report in one of the formats that need nothing
+// else than the report (oscal, xccdf, openmetrics).
+func ConvertReport(res FinalReport, format playbook.OutputFormat) (Output, error) {
+	switch format {
+	case playbook.OutputOSCAL:
+		return Output{Format: format, Ext: ".oscal.json", ContentType: "application/json", Content: renderOSCAL(res)}, nil
+	case playbook.OutputXCCDF:
+		content, err := renderXCCDF(res)
+		if err != nil {
+			return Output{}, err
+		}
+		return Output{Format: format, Ext: ".xccdf.xml", ContentType: "application/xml", Content: content}, nil
+	case playbook.OutputOpenMetrics:
+		return Output{Format: format, Ext: ".om.txt", ContentType: OpenMetricsContentType, Content: OpenMetrics(res)}, nil
+	}
+	return Output{}, fmt.Errorf("report output '%s' cannot be converted from a JSON report (expected oscal, xccdf or openmetrics)", format)
+}
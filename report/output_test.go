@@ -0,0 +1,77 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// sampleTrace returns a run of the playbook "Quarterly Audit" rendered in outputs, with an
+// assertion of every kind the output formats tell apart: SSH_ROOT failed (with controls and
+// evidence), DISK_ENCRYPTION waived and FIREWALL errored in the "Access" section, then
+// BITLOCKER not applicable and UPDATES passed in the "Platform" section.
+func sampleTrace(outputs ...playbook.OutputFormat) executor.ExecutionTrace {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	failed := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{
+			Code:            "SSH_ROOT",
+			Title:           "SSH Root Login",
+			Description:     "Root cannot log in over SSH",
+			FailDescription: "PermitRootLogin is enabled",
+			Severity:        playbook.SeverityCritical,
+			Controls: []playbook.ControlRef{
+				{Framework: "NIST 800-53", ID: "AC-6 (2)", URL: "https://example.com/ac-6"},
+				{Framework: "CIS Ubuntu 22.04", ID: "5.2.10"},
+			},
+		},
+		Verdict: executor.VerdictFail,
+		Score:   -1,
+		Outputs: []string{"PermitRootLogin yes"},
+	}
+	failed.Timestamps.Start, failed.Timestamps.End = start, start.Add(1500*time.Millisecond)
+	waived := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "DISK_ENCRYPTION", Title: "Disk Encryption", FailDescription: "Disk is not encrypted", Severity: playbook.SeverityLow},
+		Verdict:           executor.VerdictWaived,
+		Score:             -1,
+		Waiver:            &executor.WaiverTrace{Waiver: playbook.Waiver{Justification: "Kiosk", Approver: "CISO", Expires: "2999-12-31"}},
+	}
+	errored := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "FIREWALL", Title: "Firewall"},
+		Verdict:           executor.VerdictError,
+		Errors:            []string{"ufw: command not found"},
+	}
+	notApplicable := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "BITLOCKER", Title: "BitLocker"},
+		Verdict:           executor.VerdictNotApplicable,
+		Reason:            "platform linux not in [windows]",
+	}
+	passed := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "UPDATES", Title: "Updates", PassDescription: "Up to date"},
+		Verdict:           executor.VerdictPass,
+		Score:             1,
+	}
+	trace := executor.ExecutionTrace{
+		Playbook:    playbook.Playbook{Title: "Quarterly Audit", ReportOutputs: outputs},
+		Hostname:    "laptop-42",
+		Username:    "alice",
+		OS:          "linux",
+		Arch:        "amd64",
+		TotalPassed: 1,
+		TotalFailed: 1,
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "Access", Description: []string{"Remote access hardening"}}, Assertions: []executor.AssertionContext{failed, waived, errored}},
+			{PlaybookSection: playbook.Section{Title: "Platform"}, Assertions: []executor.AssertionContext{notApplicable, passed}},
+		},
+	}
+	trace.Timestamps.Start = start
+	trace.Timestamps.End = start.Add(time.Minute)
+	return trace
+}
+
+func TestGenerateReport_NoOutputs(t *testing.T) {
+	if outputs := GenerateReport(sampleTrace()).Outputs; len(outputs) != 0 {
+		t.Errorf("expected no outputs without reportOutputs, got %d", len(outputs))
+	}
+}
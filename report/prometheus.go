@@ -0,0 +1,105 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// prometheusVerdicts are the verdicts counted by crobe_assertions, all of them present in
+// every file so that their series do not come and go.
+var prometheusVerdicts = []executor.Verdict{
+	executor.VerdictPass,
+	executor.VerdictFail,
+	executor.VerdictError,
+	executor.VerdictSkipped,
+	executor.VerdictNotApplicable,
+	executor.VerdictWaived,
+}
+
+// PrometheusMetrics renders the report as gauges in the Prometheus text exposition format,
+// for the textfile collector of node_exporter. Every series has a playbook label, so that
+// several playbooks can report on the same host.
+//
+// crobe_assertion_passed and crobe_assertion_score only cover the assertions that count in
+// the score (passed, failed and errored), so that alerting on failures does not fire for
+// skipped, not applicable or waived ones.
+func PrometheusMetrics(res FinalReport) []byte {
+	var b strings.Builder
+	playbookLabel := `playbook="` + escapeLabel(res.Title) + `"`
+
+	writeMetricHeader(&b, "crobe_assertion_passed", "Whether the assertion passed (1) or failed or errored (0).")
+	for _, code := range orderedCodes(res) {
+		a := res.Assertions[code]
+		if !isScored(a.Verdict) {
+			continue
+		}
+		passed := 0
+		if a.Verdict == executor.VerdictPass {
+			passed = 1
+		}
+		fmt.Fprintf(&b, "crobe_assertion_passed{%s,%s} %d\n", playbookLabel, assertionLabels(code, a), passed)
+	}
+
+	writeMetricHeader(&b, "crobe_assertion_score", "Score of the assertion, compared to its minimum score to pass.")
+	for _, code := range orderedCodes(res) {
+		a := res.Assertions[code]
+		if !isScored(a.Verdict) {
+			continue
+		}
+		fmt.Fprintf(&b, "crobe_assertion_score{%s,%s} %d\n", playbookLabel, assertionLabels(code, a), a.Score)
+	}
+
+	writeMetricHeader(&b, "crobe_assertions", "Number of assertions by verdict.")
+	counts := map[executor.Verdict]int{
+		executor.VerdictPass:          res.Stats.Passed,
+		executor.VerdictFail:          res.Stats.Failed,
+		executor.VerdictError:         res.Stats.Errored,
+		executor.VerdictSkipped:       res.Stats.Skipped,
+		executor.VerdictNotApplicable: res.Stats.NotApplicable,
+		executor.VerdictWaived:        res.Stats.Waived,
+	}
+	for _, v := range prometheusVerdicts {
+		fmt.Fprintf(&b, "crobe_assertions{%s,verdict=\"%s\"} %d\n", playbookLabel, v, counts[v])
+	}
+
+	if res.Stats.Score != nil {
+		writeMetricHeader(&b, "crobe_run_score", "Weighted percentage of passed assertions.")
+		fmt.Fprintf(&b, "crobe_run_score{%s} %g\n", playbookLabel, *res.Stats.Score)
+	}
+
+	if !res.Timestamps.End.IsZero() {
+		writeMetricHeader(&b, "crobe_run_duration_seconds", "Duration of the playbook run.")
+		fmt.Fprintf(&b, "crobe_run_duration_seconds{%s} %g\n", playbookLabel, res.Timestamps.End.Sub(res.Timestamps.Start).Seconds())
+		writeMetricHeader(&b, "crobe_run_timestamp", "Unix time the playbook run ended.")
+		fmt.Fprintf(&b, "crobe_run_timestamp{%s} %d\n", playbookLabel, res.Timestamps.End.Unix())
+	}
+	return []byte(b.String())
+}
+
+// OpenMetricsContentType is the media type of the OpenMetrics text format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// OpenMetrics renders the same gauges as PrometheusMetrics in the OpenMetrics text format,
+// which ends with an EOF marker so that scrapers can tell a complete exposition.
+func OpenMetrics(res FinalReport) []byte {
+	return append(PrometheusMetrics(res), "# EOF\n"...)
+}
+
+func isScored(v executor.Verdict) bool {
+	return v == executor.VerdictPass || v == executor.VerdictFail || v == executor.VerdictError
+}
+
+func writeMetricHeader(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func assertionLabels(code string, a Assertion) string {
+	return fmt.Sprintf(`code="%s",section="%s",severity="%s"`, escapeLabel(code), escapeLabel(a.Section), escapeLabel(string(a.Severity)))
+}
+
+// escapeLabel escapes a label value for the text exposition format.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Playbook:           playbook.Playbook{Title: `Baseline "v2"`},
+		TotalPassed:        1,
+		TotalFailed:        1,
+		TotalNotApplicable: 1,
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "Access"}, Assertions: []executor.AssertionContext{
+				{PlaybookAssertion: playbook.Assertion{Code: "SSH_ROOT", Severity: playbook.SeverityHigh}, Verdict: executor.VerdictFail, Score: -1, MinScore: 1},
+				{PlaybookAssertion: playbook.Assertion{Code: "UPDATES"}, Verdict: executor.VerdictPass, Score: 2, MinScore: 1},
+				{PlaybookAssertion: playbook.Assertion{Code: "BITLOCKER"}, Verdict: executor.VerdictNotApplicable},
+			}},
+		},
+	}
+	trace.Timestamps.Start = time.Unix(1792137600, 0)
+	trace.Timestamps.End = trace.Timestamps.Start.Add(90 * time.Second)
+
+	metrics := string(PrometheusMetrics(GenerateReport(trace).Structured))
+	for _, want := range []string{
+		"# TYPE crobe_assertion_passed gauge\n",
+		`crobe_assertion_passed{playbook="Baseline \"v2\"",code="SSH_ROOT",section="Access",severity="high"} 0` + "\n",
+		`crobe_assertion_passed{playbook="Baseline \"v2\"",code="UPDATES",section="Access",severity="medium"} 1` + "\n",
+		`crobe_assertion_score{playbook="Baseline \"v2\"",code="UPDATES",section="Access",severity="medium"} 2` + "\n",
+		`crobe_assertions{playbook="Baseline \"v2\"",verdict="not_applicable"} 1` + "\n",
+		`crobe_assertions{playbook="Baseline \"v2\"",verdict="waived"} 0` + "\n",
+		`crobe_run_score{playbook="Baseline \"v2\""} `,
+		`crobe_run_duration_seconds{playbook="Baseline \"v2\""} 90` + "\n",
+		`crobe_run_timestamp{playbook="Baseline \"v2\""} 1792137690` + "\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected %q in metrics:\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, `code="BITLOCKER"`) {
+		t.Errorf("expected no per-assertion series for not applicable assertions")
+	}
+}
+
+func TestOpenMetrics(t *testing.T) {
+	res := FinalReport{Title: "Baseline"}
+	out, err := ConvertReport(res, playbook.OutputOpenMetrics)
+	if err != nil {
+		t.Fatalf("ConvertReport() error = %v", err)
+	}
+	if out.Name() != "report.om.txt" || !strings.HasPrefix(out.ContentType, "application/openmetrics-text") {
+		t.Errorf("output = %s, %s", out.Name(), out.ContentType)
+	}
+	metrics := string(out.Content)
+	if !strings.HasPrefix(metrics, string(PrometheusMetrics(res))) || !strings.HasSuffix(metrics, "\n# EOF\n") {
+		t.Errorf("expected the Prometheus metrics ending with # EOF, got:\n%s", metrics)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\\b\"c\nd"); got != `a\\b\"c\nd` {
+		t.Errorf("escapeLabel() = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// Remediation records what was done (or, in dry-run, would be done) to fix a failed assertion.
+type Remediation struct {
+	Mode          executor.RemediationMode `json:"mode"`
+	Description   string                   `json:"description"`
+	Script        string                   `json:"script,omitempty"`
+	ExitCode      *int                     `json:"exitCode,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+	VerdictBefore executor.Verdict         `json:"verdictBefore"`
+	VerdictAfter  executor.Verdict         `json:"verdictAfter"`
+	ScoreBefore   int                      `json:"scoreBefore"`
+	ScoreAfter    int                      `json:"scoreAfter"`
+}
+
+func newRemediation(a executor.AssertionContext) *Remediation {
+	rem := a.Remediation
+	if rem == nil {
+		return nil
+	}
+	exec := a.PlaybookAssertion.Remediation.Exec
+	r := &Remediation{
+		Mode:          rem.Mode,
+		Description:   a.PlaybookAssertion.Remediation.Description,
+		Script:        rem.Script,
+		Error:         rem.Error,
+		VerdictBefore: rem.VerdictBefore,
+		VerdictAfter:  a.Verdict,
+		ScoreBefore:   rem.ScoreBefore,
+		ScoreAfter:    a.Score,
+	}
+	if exec.ExcludeFromReport {
+		r.Script = "[REDACTED]"
+	}
+	if rem.Log != nil && rem.Log.Err == nil && !rem.Log.Result.TimedOut {
+		exitCode := rem.Log.Result.ExitCode
+		r.ExitCode = &exitCode
+	}
+	return r
+}
+
+func writeRemediationLog(log *strings.Builder, a executor.AssertionContext) {
+	rem := a.Remediation
+	if rem == nil {
+		return
+	}
+	log.WriteString(fmt.Sprintf(">>>>> REMEDIATION (%s): %s <<<<<\n", rem.Mode, a.PlaybookAssertion.Remediation.Description))
+	if rem.Log != nil {
+		writeExecutionLog(log, rem.Log.Exec, rem.Log.Result, rem.Log.Err)
+	} else if rem.Script != "" && !a.PlaybookAssertion.Remediation.Exec.ExcludeFromReport {
+		log.WriteString(fmt.Sprintf(">>> WOULD RUN <<<\n%s\n>>> END WOULD RUN <<<\n", rem.Script))
+	}
+	if rem.Error != "" {
+		log.WriteString(fmt.Sprintf(">>> REMEDIATION ERROR: %s <<<\n", rem.Error))
+	}
+	if rem.CmdLogsBefore != nil {
+		log.WriteString(fmt.Sprintf(">>> RE-RUNNING CMDS TO VERIFY (verdict before: %s) <<<\n", strings.ToUpper(string(rem.VerdictBefore))))
+	}
+	log.WriteString("\n")
+}
+
+func writeRemediationMarkdown(md *strings.Builder, r *Remediation) {
+	switch {
+	case r.Error != "":
+		md.WriteString(fmt.Sprintf("> 🔧 **Remediation (%s) failed:** %s  \n> %s\n\n", r.Mode, r.Description, r.Error))
+	case r.Mode == executor.RemediateDryRun:
+		md.WriteString(fmt.Sprintf("> 🔧 **Remediation (dry-run):** %s. Would run:\n\n", r.Description))
+	default:
+		md.WriteString(fmt.Sprintf("> 🔧 **Remediation applied:** %s. Verdict before: %s, after: %s.\n\n", r.Description, verdictLabel(r.VerdictBefore), verdictLabel(r.VerdictAfter)))
+	}
+	if r.Script != "" && r.Error == "" {
+		md.WriteString("```\n" + strings.TrimRight(r.Script, "\n") + "\n```\n\n")
+	}
+}
+
+// writeRemediationSummaryMarkdown lists the before/after verdicts of every remediated assertion.
+func writeRemediationSummaryMarkdown(md *strings.Builder, mode executor.RemediationMode, remediations []*Remediation, codes []string) {
+	md.WriteString(fmt.Sprintf("## Remediation (%s)\n\n", mode))
+	if len(remediations) == 0 {
+		md.WriteString("No failed assertion had a remediation.\n\n")
+		return
+	}
+	md.WriteString("| Assertion | Remediation | Before | After |\n")
+	md.WriteString("|---|---|---|---|\n")
+	for i, r := range remediations {
+		after := verdictLabel(r.VerdictAfter)
+		switch {
+		case r.Error != "":
+			after += " (remediation failed)"
+		case r.Mode == executor.RemediateDryRun:
+			after = "not applied"
+		}
+		md.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", codes[i], r.Description, verdictLabel(r.VerdictBefore), after))
+	}
+	md.WriteString("\n")
+}
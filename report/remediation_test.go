@@ -0,0 +1,104 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_Remediation(t *testing.T) {
+	fix := &playbook.Remediation{Description: "Restrict key permissions", Exec: playbook.Exec{Script: "chmod 600 /etc/app.key"}}
+	secretFix := &playbook.Remediation{Description: "Rotate token", Exec: playbook.Exec{Script: "rotate --token s3cret", ExcludeFromReport: true}}
+
+	applied := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "PERMS", Title: "Key Permissions", Remediation: fix},
+		Verdict:           executor.VerdictPass,
+		Score:             1,
+		CmdLogs: []executor.CommandLog{
+			{Exec: playbook.Exec{Script: "stat /etc/app.key"}, Result: executor.ExecutionResult{Stdout: "600"}},
+		},
+		Remediation: &executor.RemediationTrace{
+			Mode:          executor.RemediateApply,
+			Script:        "chmod 600 /etc/app.key",
+			Log:           &executor.CommandLog{Exec: fix.Exec, Result: executor.ExecutionResult{ExitCode: 0}},
+			VerdictBefore: executor.VerdictFail,
+			ScoreBefore:   -1,
+			CmdLogsBefore: []executor.CommandLog{
+				{Exec: playbook.Exec{Script: "stat /etc/app.key"}, Result: executor.ExecutionResult{Stdout: "644", ExitCode: 1}},
+			},
+		},
+	}
+	dryRun := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "TOKEN", Title: "Token Age", Remediation: secretFix},
+		Verdict:           executor.VerdictFail,
+		Remediation: &executor.RemediationTrace{
+			Mode:          executor.RemediateDryRun,
+			Script:        "rotate --token s3cret",
+			VerdictBefore: executor.VerdictFail,
+		},
+	}
+	trace := executor.ExecutionTrace{
+		Playbook:  playbook.Playbook{Title: "Remediation"},
+		Remediate: executor.RemediateApply,
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "S1"}, Assertions: []executor.AssertionContext{applied, dryRun}},
+		},
+	}
+
+	res := GenerateReport(trace)
+
+	rem := res.Structured.Assertions["PERMS"].Remediation
+	if rem == nil || rem.VerdictBefore != executor.VerdictFail || rem.VerdictAfter != executor.VerdictPass || rem.ScoreBefore != -1 || rem.ScoreAfter != 1 {
+		t.Fatalf("unexpected remediation in JSON report: %+v", rem)
+	}
+	if rem.ExitCode == nil || *rem.ExitCode != 0 || rem.Script != "chmod 600 /etc/app.key" {
+		t.Errorf("expected exit code and script of the applied remediation, got %+v", rem)
+	}
+	if got := res.Structured.Assertions["TOKEN"].Remediation.Script; got != "[REDACTED]" {
+		t.Errorf("excluded remediation script = %q; want [REDACTED]", got)
+	}
+	if res.Structured.Remediate != executor.RemediateApply {
+		t.Errorf("remediate = %q; want apply", res.Structured.Remediate)
+	}
+
+	md := res.Markdown
+	for _, want := range []string{
+		"🔧 **Remediation applied:** Restrict key permissions. Verdict before: ❌ Fail, after: ✅ Pass.",
+		"🔧 **Remediation (dry-run):** Rotate token. Would run:",
+		"## Remediation (apply)",
+		"| PERMS | Restrict key permissions | ❌ Fail | ✅ Pass |",
+		"| TOKEN | Rotate token | ❌ Fail | not applied |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in markdown", want)
+		}
+	}
+	if strings.Contains(md, "s3cret") || strings.Contains(res.Log, "s3cret") {
+		t.Errorf("excluded remediation script leaked into the report")
+	}
+
+	// The log shows the failing run, the remediation, then the verifying re-run
+	log := res.Log
+	before := strings.Index(log, "644")
+	remediation := strings.Index(log, ">>>>> REMEDIATION (apply): Restrict key permissions")
+	after := strings.Index(log, "600\n")
+	if before < 0 || remediation < before || after < remediation {
+		t.Errorf("unexpected log order (before %d, remediation %d, after %d):\n%s", before, remediation, after, log)
+	}
+}
+
+func TestGenerateReport_NoRemediationSection(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Plain"},
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "S1"}, Assertions: []executor.AssertionContext{
+				{PlaybookAssertion: playbook.Assertion{Code: "A1", Title: "A1"}, Verdict: executor.VerdictFail},
+			}},
+		},
+	}
+	if res := GenerateReport(trace); strings.Contains(res.Markdown, "## Remediation") {
+		t.Errorf("reports of runs without --remediate should not have a remediation section")
+	}
+}
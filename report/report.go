@@ -16,15 +16,72 @@ type Assertion struct {
 		Start time.Time `json:"start"`
 		End   time.Time `json:"end"`
 	} `json:"timestamps"`
+	Title    string                 `json:"title,omitempty"`
+	Section  string                 `json:"section,omitempty"`
 	Passed   bool                   `json:"passed"`
+	Verdict  executor.Verdict       `json:"verdict"`
+	Severity playbook.Severity      `json:"severity"`
+	Weight   int                    `json:"weight"`
+	Controls []playbook.ControlRef  `json:"controls,omitempty"`
+	Reason   string                 `json:"reason,omitempty"`
+	Errors   []string               `json:"errors,omitempty"`
 	Score    int                    `json:"score"`
 	MinScore int                    `json:"minScore"`
 	Context  map[string]interface{} `json:"context"`
+	Timeouts []Timeout              `json:"timeouts,omitempty"`
+	// Remediation is present when the assertion failed and was remediated (or, in dry-run,
+	// would have been). Verdict and score then hold the outcome after remediation.
+	Remediation *Remediation `json:"remediation,omitempty"`
+	// Waiver is present when a waiver matched the failed assertion, even if it expired.
+	Waiver *Waiver `json:"waiver,omitempty"`
+}
+
+// Timeout records an execution that was terminated for exceeding its timeout.
+type Timeout struct {
+	Stage   string `json:"stage"`
+	Index   int    `json:"index"`
+	Timeout string `json:"timeout"`
 }
 
 type Stats struct {
-	Passed int `json:"passed"`
-	Failed int `json:"failed"`
+	Passed        int `json:"passed"`
+	Failed        int `json:"failed"`
+	Errored       int `json:"errored"`
+	Skipped       int `json:"skipped"`
+	NotApplicable int `json:"notApplicable"`
+	// Waived counts failures accepted by an active waiver, and ExpiredWaivers the failures
+	// whose matching waivers have all expired. Both are omitted in runs without waivers.
+	Waived         int `json:"waived,omitempty"`
+	ExpiredWaivers int `json:"expiredWaivers,omitempty"`
+	// Score is the weighted percentage of passed assertions among those that passed, failed
+	// or errored. It is absent when no weight was scored.
+	Score *float64 `json:"score,omitempty"`
+	Grade string   `json:"grade,omitempty"`
+}
+
+// Summary returns a one-line overview of the counts, omitting verdicts that did not occur
+// apart from PASS and FAIL.
+func (s Stats) Summary() string {
+	summary := fmt.Sprintf("PASS: %d, FAIL: %d", s.Passed, s.Failed)
+	if s.Errored > 0 {
+		summary += fmt.Sprintf(", ERROR: %d", s.Errored)
+	}
+	if s.Skipped > 0 {
+		summary += fmt.Sprintf(", SKIPPED: %d", s.Skipped)
+	}
+	if s.NotApplicable > 0 {
+		summary += fmt.Sprintf(", N/A: %d", s.NotApplicable)
+	}
+	if s.Waived > 0 {
+		summary += fmt.Sprintf(", WAIVED: %d", s.Waived)
+	}
+	if s.ExpiredWaivers > 0 {
+		summary += fmt.Sprintf(", EXPIRED WAIVERS: %d", s.ExpiredWaivers)
+	}
+	if s.Score != nil {
+		summary += ", SCORE: " + s.scoreLabel()
+	}
+	return summary
 }
 
 type FinalReport struct {
@@ -32,17 +89,32 @@ type FinalReport struct {
 		Start time.Time `json:"start"`
 		End   time.Time `json:"end"`
 	} `json:"timestamps"`
+	// Title is the title of the playbook.
+	Title      string               `json:"title,omitempty"`
 	Username   string               `json:"username"`
+	Hostname   string               `json:"hostname,omitempty"`
 	OS         string               `json:"os"`
 	Arch       string               `json:"arch"`
 	Assertions map[string]Assertion `json:"assertions"`
 	Stats      Stats                `json:"stats"`
+	Sections   []SectionSummary     `json:"sections,omitempty"`
+	Frameworks []FrameworkSummary   `json:"frameworks,omitempty"`
+	// Remediate is the remediation mode of the run (dry-run or apply), if any.
+	Remediate executor.RemediationMode `json:"remediate,omitempty"`
+	// Selection is present when the run was restricted to a subset of assertions.
+	Selection *playbook.Selector `json:"selection,omitempty"`
+	// Vars are the effective values of the playbook vars, after overrides.
+	Vars map[string]interface{} `json:"vars,omitempty"`
+	// CachedCopies is present when remote playbook files were loaded from the local cache.
+	CachedCopies []CachedCopy `json:"cachedCopies,omitempty"`
 }
 
 type FinalResult struct {
 	Structured FinalReport
 	Log        string
 	Markdown   string
+	// Outputs are the report rendered in the playbook's reportOutputs, in their order.
+	Outputs []Output
 }
 
 func GenerateReport(trace executor.ExecutionTrace) FinalResult {
@@ -56,6 +128,16 @@ func GenerateReport(trace executor.ExecutionTrace) FinalResult {
 	}
 
 	log.WriteString(fmt.Sprintf(">>>>>>>>>>>> REPORT LOG: %s <<<<<<<<<<<<\n\n", logName))
+	if !trace.Selection.IsEmpty() {
+		log.WriteString(fmt.Sprintf(">>>>>>>>>> SELECTION: %s <<<<<<<<<<\n\n", trace.Selection))
+	}
+	if len(config.Vars) > 0 {
+		log.WriteString(fmt.Sprintf(">>>>>>>>>> VARS: %s <<<<<<<<<<\n\n", playbook.FormatVars(config.Vars)))
+	}
+	cachedCopies := newCachedCopies(config.CachedCopies, trace.Timestamps.Start)
+	if len(cachedCopies) > 0 {
+		writeCachedCopiesLog(&log, cachedCopies)
+	}
 
 	if config.ReportFrontmatter == nil {
 		config.ReportFrontmatter = make(map[string]interface{})
@@ -76,13 +158,67 @@ func GenerateReport(trace executor.ExecutionTrace) FinalResult {
 	md.Write(fmBytes)
 	md.WriteString("---\n\n")
 
-	md.WriteString(fmt.Sprintf("# %s\n\nGenerated on: %s\n\n---\n\n", config.Title, trace.Timestamps.Start.Format(time.DateTime)))
+	md.WriteString(fmt.Sprintf("# %s\n\nGenerated on: %s\n\n", config.Title, trace.Timestamps.Start.Format(time.DateTime)))
+	if !trace.Selection.IsEmpty() {
+		md.WriteString(fmt.Sprintf("> 🔎 **Partial run:** only assertions matching `%s` were executed; the others are reported as skipped.\n\n", trace.Selection))
+	}
+	if len(config.Vars) > 0 {
+		md.WriteString(fmt.Sprintf("> 🔧 **Variables:** `%s`\n\n", playbook.FormatVars(config.Vars)))
+	}
+	if len(cachedCopies) > 0 {
+		writeCachedCopiesMarkdown(&md, cachedCopies)
+	}
+	md.WriteString("---\n\n")
 
 	finalReport := FinalReport{
-		Username:   trace.Username,
-		OS:         trace.OS,
-		Arch:       trace.Arch,
-		Assertions: make(map[string]Assertion),
+		Title:        config.Title,
+		Username:     trace.Username,
+		Hostname:     trace.Hostname,
+		OS:           trace.OS,
+		Arch:         trace.Arch,
+		Assertions:   make(map[string]Assertion),
+		Vars:         config.Vars,
+		CachedCopies: cachedCopies,
+	}
+
+	finalReport.Remediate = trace.Remediate
+	var remediations []*Remediation
+	var remediatedCodes []string
+	var waivers []*Waiver
+	var waivedCodes []string
+
+	var overall scoreTally
+	for _, sectionCtx := range trace.Sections {
+		summary := SectionSummary{Title: sectionCtx.PlaybookSection.Title}
+		var tally scoreTally
+		for _, assCtx := range sectionCtx.Assertions {
+			summary.Stats.count(assCtx.Verdict)
+			if assCtx.Waiver != nil && assCtx.Waiver.Expired {
+				finalReport.Stats.ExpiredWaivers++
+			}
+			tally.add(assCtx)
+			overall.add(assCtx)
+		}
+		tally.apply(&summary.Stats)
+		finalReport.Sections = append(finalReport.Sections, summary)
+	}
+	finalReport.Stats.Passed = trace.TotalPassed
+	finalReport.Stats.Failed = trace.TotalFailed
+	finalReport.Stats.Errored = trace.TotalErrored
+	finalReport.Stats.Skipped = trace.TotalSkipped
+	finalReport.Stats.NotApplicable = trace.TotalNotApplicable
+	finalReport.Stats.Waived = trace.TotalWaived
+	overall.apply(&finalReport.Stats)
+
+	if n := finalReport.Stats.ExpiredWaivers; n > 0 {
+		md.WriteString(fmt.Sprintf("> 🚨 **%d expired waiver(s):** the failures they covered count again. Renew or remove them (see [Waivers](#waivers)).\n\n", n))
+	}
+	if len(finalReport.Sections) > 0 {
+		writeSummaryMarkdown(&md, finalReport.Stats, finalReport.Sections)
+	}
+	if !trace.Selection.IsEmpty() {
+		selection := trace.Selection
+		finalReport.Selection = &selection
 	}
 	finalReport.Timestamps.Start = trace.Timestamps.Start
 	finalReport.Timestamps.End = trace.Timestamps.End
@@ -101,21 +237,56 @@ func GenerateReport(trace executor.ExecutionTrace) FinalResult {
 
 			log.WriteString(fmt.Sprintf(">>>>>>> ASSERTION: %s <<<<<<<\n\n", assertion.Title))
 
-			for _, cmd := range assCtx.CmdLogs {
-				writeExecutionLog(&log, cmd.Exec, cmd.Result, cmd.Err)
-				if cmd.Err != nil {
-					log.WriteString(fmt.Sprintf(">>>>> Error executing command: %v <<<<<\n\n", cmd.Err))
-				}
+			// A verified remediation sits between the original cmds and their re-run
+			rem := assCtx.Remediation
+			verified := rem != nil && rem.CmdLogsBefore != nil
+			if verified {
+				writeCmdLogs(&log, rem.CmdLogsBefore)
+				writeRemediationLog(&log, assCtx)
+			}
+			writeCmdLogs(&log, assCtx.CmdLogs)
+			if rem != nil && !verified {
+				writeRemediationLog(&log, assCtx)
 			}
 
+			log.WriteString(fmt.Sprintf(">>>>> VERDICT: %s <<<<<\n", strings.ToUpper(string(assCtx.Verdict))))
+			if assCtx.Reason != "" {
+				log.WriteString(fmt.Sprintf(">>> REASON: %s <<<\n", assCtx.Reason))
+			}
+			for _, e := range assCtx.Errors {
+				log.WriteString(fmt.Sprintf(">>> ERROR: %s <<<\n", e))
+			}
+			waiver := newWaiver(assCtx)
+			if waiver != nil {
+				writeWaiverLog(&log, waiver)
+				waivers = append(waivers, waiver)
+				waivedCodes = append(waivedCodes, assertion.Code)
+			}
+			log.WriteString("\n")
+
 			report := Assertion{
-				Passed:   assCtx.Passed,
+				Title:    assertion.Title,
+				Section:  section.Title,
+				Passed:   assCtx.Verdict == executor.VerdictPass,
+				Verdict:  assCtx.Verdict,
+				Severity: assertion.GetSeverity(),
+				Weight:   assertion.GetWeight(),
+				Controls: assertion.Controls,
+				Reason:   assCtx.Reason,
+				Errors:   assCtx.Errors,
 				Score:    assCtx.Score,
 				MinScore: assCtx.MinScore,
 				Context:  assCtx.Context,
+				Waiver:   waiver,
+			}
+			report.Remediation = newRemediation(assCtx)
+			if report.Remediation != nil {
+				remediations = append(remediations, report.Remediation)
+				remediatedCodes = append(remediatedCodes, assertion.Code)
 			}
 			report.Timestamps.Start = assCtx.Timestamps.Start
 			report.Timestamps.End = assCtx.Timestamps.End
+			report.Timeouts = collectTimeouts(assCtx)
 			finalReport.Assertions[assertion.Code] = report
 
 			writeAssertionMarkdown(&md, assCtx)
@@ -123,14 +294,59 @@ func GenerateReport(trace executor.ExecutionTrace) FinalResult {
 		md.WriteString("---\n\n")
 	}
 
-	finalReport.Stats.Passed = trace.TotalPassed
-	finalReport.Stats.Failed = trace.TotalFailed
+	finalReport.Frameworks = collectFrameworks(trace)
+	if len(finalReport.Frameworks) > 0 {
+		writeFrameworksMarkdown(&md, finalReport.Frameworks)
+	}
+	if trace.Remediate != executor.RemediateOff {
+		writeRemediationSummaryMarkdown(&md, trace.Remediate, remediations, remediatedCodes)
+	}
+	if len(waivers) > 0 {
+		writeWaiversMarkdown(&md, waivers, waivedCodes)
+	}
 
+	if finalReport.Stats.Score != nil {
+		log.WriteString(fmt.Sprintf(">>>>>>>>>>>> SCORE: %s <<<<<<<<<<<<\n", finalReport.Stats.scoreLabel()))
+	}
+
+	// Outputs render the frontmatter completed above
+	trace.Playbook.ReportFrontmatter = config.ReportFrontmatter
+	outputs := renderOutputs(trace, finalReport, &log)
 	return FinalResult{
 		Structured: finalReport,
 		Markdown:   md.String(),
 		Log:        log.String(),
+		Outputs:    outputs,
+	}
+}
+
+func writeCmdLogs(log *strings.Builder, logs []executor.CommandLog) {
+	for _, cmd := range logs {
+		writeExecutionLog(log, cmd.Exec, cmd.Result, cmd.Err)
+		if cmd.Err != nil {
+			log.WriteString(fmt.Sprintf(">>>>> Error executing command: %v <<<<<\n\n", cmd.Err))
+		}
+	}
+}
+
+func collectTimeouts(a executor.AssertionContext) []Timeout {
+	var timeouts []Timeout
+	stages := []struct {
+		name string
+		logs []executor.CommandLog
+	}{
+		{"preCmd", a.PreCmdLogs},
+		{"cmd", a.CmdLogs},
+		{"postCmd", a.PostCmdLogs},
 	}
+	for _, stage := range stages {
+		for i, l := range stage.logs {
+			if l.Result.TimedOut {
+				timeouts = append(timeouts, Timeout{Stage: stage.name, Index: i, Timeout: l.Exec.Timeout})
+			}
+		}
+	}
+	return timeouts
 }
 
 func isEvidenceMaterial(s string) bool {
@@ -143,6 +359,17 @@ func isEvidenceMaterial(s string) bool {
 	return true
 }
 
+// assertionEvidence returns the outputs of the assertion shown as evidence, or "" when none
+// of them is evidence material. Outputs excluded from the report are already redacted.
+func assertionEvidence(a executor.AssertionContext) string {
+	for _, o := range a.Outputs {
+		if isEvidenceMaterial(o) {
+			return strings.Join(a.Outputs, "\n")
+		}
+	}
+	return ""
+}
+
 func writeExecutionLog(log *strings.Builder, exec playbook.Exec, res executor.ExecutionResult, err error) {
 	exclude := exec.ExcludeFromReport
 	script := strings.TrimSpace(exec.Script)
@@ -166,10 +393,18 @@ func writeExecutionLog(log *strings.Builder, exec playbook.Exec, res executor.Ex
 		log.WriteString("\n")
 	}
 
+	if exec.Timeout != "" {
+		log.WriteString(fmt.Sprintf(">>> TIMEOUT: %s <<<\n", exec.Timeout))
+	}
+
 	if err != nil {
 		log.WriteString(fmt.Sprintf(">>> ERROR: %v <<<\n", err))
 	}
 
+	if res.TimedOut {
+		log.WriteString(fmt.Sprintf(">>> TIMED OUT after %s, process tree terminated <<<\n", exec.Timeout))
+	}
+
 	if res.Stdout != "" {
 		log.WriteString(">>> STDOUT <<<\n")
 		if exclude {
@@ -201,33 +436,67 @@ func writeAssertionMarkdown(md *strings.Builder, a executor.AssertionContext) {
 
 	md.WriteString(fmt.Sprintf("### %s\n\n", assertion.Title))
 	md.WriteString(fmt.Sprintf("%s\n\n", assertion.Description))
-
-	outputs := a.Outputs
-	shouldSkipEvidence := true
-	for _, o := range outputs {
-		if isEvidenceMaterial(o) {
-			shouldSkipEvidence = false
-			break
+	if assertion.Severity != "" || assertion.Weight != nil {
+		md.WriteString(fmt.Sprintf("**Severity:** %s (weight %d)\n\n", assertion.GetSeverity(), assertion.GetWeight()))
+	}
+	if len(assertion.Controls) > 0 {
+		var refs []string
+		for _, c := range assertion.Controls {
+			refs = append(refs, c.Framework+" "+c.ID)
 		}
+		md.WriteString(fmt.Sprintf("**Controls:** %s\n\n", strings.Join(refs, ", ")))
 	}
-	if !shouldSkipEvidence {
+
+	if evidence := assertionEvidence(a); evidence != "" {
 		md.WriteString("**Evidence:**\n")
 		md.WriteString("```\n")
-		md.WriteString(strings.Join(outputs, "\n") + "\n")
+		md.WriteString(evidence + "\n")
 		md.WriteString("```\n\n")
 	}
 
-	if a.Passed {
+	for _, t := range collectTimeouts(a) {
+		md.WriteString(fmt.Sprintf("> ⏱️ **Timed out:** %s #%d exceeded its %s timeout and was terminated.\n\n", t.Stage, t.Index+1, t.Timeout))
+	}
+
+	switch a.Verdict {
+	case executor.VerdictPass:
 		if assertion.PassDescription != "" {
 			md.WriteString(fmt.Sprintf("> ✅ **Pass:** %s\n\n", assertion.PassDescription))
 		} else {
 			md.WriteString("> ✅ **Assertion Passed**")
 		}
-	} else {
+	case executor.VerdictError:
+		md.WriteString("> ⚠️ **Error:** The assertion could not be evaluated.\n")
+		for _, e := range a.Errors {
+			md.WriteString(fmt.Sprintf("> - %s\n", e))
+		}
+		md.WriteString("\n")
+	case executor.VerdictSkipped:
+		md.WriteString("> ⏭️ **Skipped**" + reasonSuffix(a.Reason) + "\n\n")
+	case executor.VerdictNotApplicable:
+		md.WriteString("> ➖ **Not Applicable**" + reasonSuffix(a.Reason) + "\n\n")
+	case executor.VerdictWaived:
+		md.WriteString(fmt.Sprintf("> 📝 **Waived failure:** %s\n\n", assertion.FailDescription))
+	default:
 		if assertion.FailDescription != "" {
 			md.WriteString(fmt.Sprintf("> ❌ **Fail:** %s\n\n", assertion.FailDescription))
 		} else {
 			md.WriteString("> ❌ **Assertion Failed**")
 		}
 	}
+
+	if w := newWaiver(a); w != nil {
+		writeWaiverMarkdown(md, w)
+	}
+
+	if r := newRemediation(a); r != nil {
+		writeRemediationMarkdown(md, r)
+	}
+}
+
+func reasonSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ": " + reason
 }
@@ -36,7 +36,7 @@ func TestGenerateReport_Formatting(t *testing.T) {
 							PassDescription: "It passed!",
 							FailDescription: "It failed!",
 						},
-						Passed:   true,
+						Verdict:  executor.VerdictPass,
 						Score:    2,
 						MinScore: 1,
 						Context: map[string]interface{}{
@@ -60,8 +60,8 @@ func TestGenerateReport_Formatting(t *testing.T) {
 							Title:           "Fail Assertion",
 							FailDescription: "It failed!",
 						},
-						Passed: false,
-						Score:  0,
+						Verdict: executor.VerdictFail,
+						Score:   0,
 						CmdLogs: []executor.CommandLog{
 							{
 								Exec:   playbook.Exec{Script: "failcmd"},
@@ -193,3 +193,208 @@ func TestIsEvidenceMaterial(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateReport_Timeouts(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Timeouts"},
+		Sections: []executor.SectionContext{
+			{
+				PlaybookSection: playbook.Section{Title: "S1"},
+				Assertions: []executor.AssertionContext{
+					{
+						PlaybookAssertion: playbook.Assertion{Code: "T_01", Title: "Hung Check"},
+						CmdLogs: []executor.CommandLog{
+							{
+								Exec:   playbook.Exec{Script: "nslookup example.com", Timeout: "5s"},
+								Result: executor.ExecutionResult{ExitCode: -1, TimedOut: true},
+							},
+						},
+						Outputs: []string{"# --- TIMED OUT after 5s ---"},
+					},
+				},
+			},
+		},
+	}
+
+	res := GenerateReport(trace)
+
+	timeouts := res.Structured.Assertions["T_01"].Timeouts
+	if len(timeouts) != 1 || timeouts[0].Stage != "cmd" || timeouts[0].Index != 0 || timeouts[0].Timeout != "5s" {
+		t.Errorf("unexpected timeouts in JSON report: %+v", timeouts)
+	}
+	if !strings.Contains(res.Log, ">>> TIMEOUT: 5s <<<") || !strings.Contains(res.Log, ">>> TIMED OUT after 5s") {
+		t.Errorf("expected timeout in log, got:\n%s", res.Log)
+	}
+	if !strings.Contains(res.Markdown, "⏱️ **Timed out:** cmd #1 exceeded its 5s timeout") {
+		t.Errorf("expected timeout in markdown, got:\n%s", res.Markdown)
+	}
+}
+
+func TestGenerateReport_Verdicts(t *testing.T) {
+	verdicts := []executor.Verdict{
+		executor.VerdictPass,
+		executor.VerdictFail,
+		executor.VerdictError,
+		executor.VerdictSkipped,
+		executor.VerdictNotApplicable,
+	}
+	var assertions []executor.AssertionContext
+	for _, v := range verdicts {
+		a := executor.AssertionContext{
+			PlaybookAssertion: playbook.Assertion{Code: string(v), Title: "Assertion " + string(v)},
+			Verdict:           v,
+		}
+		if v == executor.VerdictError {
+			a.Errors = []string{"cmd #1: JS error in Exec.Func: boom"}
+		}
+		if v == executor.VerdictNotApplicable {
+			a.Reason = "not applicable on linux (platforms: windows)"
+		}
+		assertions = append(assertions, a)
+	}
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Verdicts"},
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "S1"}, Assertions: assertions},
+		},
+		TotalPassed:        1,
+		TotalFailed:        1,
+		TotalErrored:       1,
+		TotalSkipped:       1,
+		TotalNotApplicable: 1,
+	}
+
+	res := GenerateReport(trace)
+
+	stats := res.Structured.Stats
+	if stats.Passed != 1 || stats.Failed != 1 || stats.Errored != 1 || stats.Skipped != 1 || stats.NotApplicable != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	for _, v := range verdicts {
+		a := res.Structured.Assertions[string(v)]
+		if a.Verdict != v {
+			t.Errorf("assertion %s verdict = %s", v, a.Verdict)
+		}
+		if a.Passed != (v == executor.VerdictPass) {
+			t.Errorf("assertion %s passed = %v", v, a.Passed)
+		}
+	}
+	if reason := res.Structured.Assertions["not_applicable"].Reason; reason != "not applicable on linux (platforms: windows)" {
+		t.Errorf("expected reason to be carried into the JSON report, got %q", reason)
+	}
+	if errs := res.Structured.Assertions["error"].Errors; len(errs) != 1 {
+		t.Errorf("expected errors to be carried into the JSON report, got %v", errs)
+	}
+
+	md := res.Markdown
+	for _, want := range []string{"⚠️ **Error:**", "> - cmd #1: JS error in Exec.Func: boom", "⏭️ **Skipped**", "➖ **Not Applicable**: not applicable on linux (platforms: windows)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in markdown", want)
+		}
+	}
+	if !strings.Contains(res.Log, ">>>>> VERDICT: ERROR <<<<<") {
+		t.Errorf("expected verdict in log")
+	}
+}
+
+func TestGenerateReport_Selection(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Selection"},
+		Sections: []executor.SectionContext{
+			{
+				PlaybookSection: playbook.Section{Title: "S1"},
+				Assertions: []executor.AssertionContext{
+					{
+						PlaybookAssertion: playbook.Assertion{Code: "DNS", Title: "DNS"},
+						Verdict:           executor.VerdictSkipped,
+						Reason:            "code not selected",
+					},
+				},
+			},
+		},
+		TotalSkipped: 1,
+		Selection:    playbook.Selector{Codes: []string{"SSH_*"}},
+	}
+
+	res := GenerateReport(trace)
+
+	if sel := res.Structured.Selection; sel == nil || len(sel.Codes) != 1 || sel.Codes[0] != "SSH_*" {
+		t.Errorf("expected selection in JSON report, got %+v", sel)
+	}
+	if !strings.Contains(res.Markdown, "🔎 **Partial run:** only assertions matching `code=SSH_*`") {
+		t.Errorf("expected selection note in markdown, got:\n%s", res.Markdown)
+	}
+	if !strings.Contains(res.Markdown, "⏭️ **Skipped**: code not selected") {
+		t.Errorf("expected skip reason in markdown")
+	}
+	if !strings.Contains(res.Log, "SELECTION: code=SSH_*") {
+		t.Errorf("expected selection in log")
+	}
+
+	// Full runs carry no selection
+	trace.Selection = playbook.Selector{}
+	if res := GenerateReport(trace); res.Structured.Selection != nil || strings.Contains(res.Markdown, "Partial run") {
+		t.Errorf("full run should not record a selection")
+	}
+}
+
+func TestGenerateReport_Vars(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Vars", Vars: map[string]interface{}{"path": "/opt/app.env", "strict": true}},
+	}
+
+	res := GenerateReport(trace)
+
+	if res.Structured.Vars["path"] != "/opt/app.env" || res.Structured.Vars["strict"] != true {
+		t.Errorf("expected effective vars in JSON report, got %v", res.Structured.Vars)
+	}
+	if !strings.Contains(res.Markdown, "🔧 **Variables:** `path=/opt/app.env, strict=true`") {
+		t.Errorf("expected vars in markdown, got:\n%s", res.Markdown)
+	}
+	if !strings.Contains(res.Log, "VARS: path=/opt/app.env, strict=true") {
+		t.Errorf("expected vars in log")
+	}
+
+	trace.Playbook.Vars = nil
+	if res := GenerateReport(trace); res.Structured.Vars != nil || strings.Contains(res.Markdown, "Variables") {
+		t.Errorf("a playbook without vars should not record any")
+	}
+}
+
+func TestGenerateReport_CachedCopies(t *testing.T) {
+	start := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{
+			Title:        "Cache",
+			CachedCopies: []playbook.CachedCopy{{Location: "https://hub.example.com/p.yaml", FetchedAt: start.Add(-3 * time.Hour)}},
+		},
+	}
+	trace.Timestamps.Start = start
+
+	res := GenerateReport(trace)
+
+	cached := res.Structured.CachedCopies
+	if len(cached) != 1 || cached[0].Location != "https://hub.example.com/p.yaml" || cached[0].Age != "3h0m0s" {
+		t.Errorf("unexpected cached copies in JSON report: %+v", cached)
+	}
+	if !strings.Contains(res.Markdown, "🗄️ **Offline run:**") || !strings.Contains(res.Markdown, "`https://hub.example.com/p.yaml`, fetched 2026-04-10 09:00:00 (3h0m0s old)") {
+		t.Errorf("expected the cached copy in markdown, got:\n%s", res.Markdown)
+	}
+	if !strings.Contains(res.Log, "CACHED COPY: https://hub.example.com/p.yaml, fetched 2026-04-10T09:00:00Z (3h0m0s old)") {
+		t.Errorf("expected the cached copy in log")
+	}
+
+	trace.Playbook.CachedCopies = nil
+	if res := GenerateReport(trace); res.Structured.CachedCopies != nil || strings.Contains(res.Markdown, "Offline run") {
+		t.Errorf("a run with a fresh playbook should not record cached copies")
+	}
+}
+
+func TestStats_Summary(t *testing.T) {
+	if got := (Stats{Passed: 2, Failed: 1}).Summary(); got != "PASS: 2, FAIL: 1" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := (Stats{Passed: 2, Errored: 1, Skipped: 3, NotApplicable: 4}).Summary(); got != "PASS: 2, FAIL: 0, ERROR: 1, SKIPPED: 3, N/A: 4" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
@@ -0,0 +1,235 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// SARIF 2.1.0 log, limited to what crobe reports. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool              `json:"tool"`
+	Invocations []sarifInvocation      `json:"invocations"`
+	Results     []sarifResult          `json:"results"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      sarifMessage           `json:"fullDescription"`
+	Help                 *sarifMessage          `json:"help,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc,omitempty"`
+	EndTimeUTC          string `json:"endTimeUtc,omitempty"`
+	Machine             string `json:"machine,omitempty"`
+	Account             string `json:"account,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID       string                 `json:"ruleId"`
+	RuleIndex    int                    `json:"ruleIndex"`
+	Kind         string                 `json:"kind"`
+	Level        string                 `json:"level"`
+	Message      sarifMessage           `json:"message"`
+	Locations    []sarifLocation        `json:"locations"`
+	Suppressions []sarifSuppression     `json:"suppressions,omitempty"`
+	Properties   map[string]interface{} `json:"properties"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+// sarifLevels maps severities to SARIF levels, and sarifSecuritySeverities to the
+// security-severity scores used by code-scanning UIs to rank alerts.
+var sarifLevels = map[playbook.Severity]string{
+	playbook.SeverityInfo:     "note",
+	playbook.SeverityLow:      "note",
+	playbook.SeverityMedium:   "warning",
+	playbook.SeverityHigh:     "error",
+	playbook.SeverityCritical: "error",
+}
+
+var sarifSecuritySeverities = map[playbook.Severity]string{
+	playbook.SeverityInfo:     "0.0",
+	playbook.SeverityLow:      "3.0",
+	playbook.SeverityMedium:   "5.5",
+	playbook.SeverityHigh:     "8.0",
+	playbook.SeverityCritical: "9.5",
+}
+
+// renderSARIF renders the report as a SARIF log with a rule per assertion. Failed assertions
+// are results of kind fail (suppressed when waived), errored ones results of kind open as
+// they could not be evaluated. Passed, skipped and not applicable assertions have no result.
+func renderSARIF(trace executor.ExecutionTrace, res FinalReport) []byte {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "crobe",
+			InformationURI: "https://github.com/benedictjohannes/crobe",
+			Rules:          []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: true,
+			StartTimeUTC:        utcTime(res.Timestamps.Start),
+			EndTimeUTC:          utcTime(res.Timestamps.End),
+			Machine:             res.Hostname,
+			Account:             res.Username,
+		}},
+		Results: []sarifResult{},
+		Properties: map[string]interface{}{
+			"playbook": trace.Playbook.Title,
+			"os":       res.OS,
+			"arch":     res.Arch,
+			"stats":    res.Stats,
+		},
+	}
+
+	for _, sectionCtx := range trace.Sections {
+		section := sectionCtx.PlaybookSection
+		for _, a := range sectionCtx.Assertions {
+			assertion := a.PlaybookAssertion
+			severity := assertion.GetSeverity()
+			rule := sarifRule{
+				ID:                   assertion.Code,
+				Name:                 assertion.Title,
+				ShortDescription:     sarifMessage{Text: assertion.Title},
+				FullDescription:      sarifMessage{Text: assertion.Description},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevels[severity]},
+				Properties: map[string]interface{}{
+					"severity":          severity,
+					"security-severity": sarifSecuritySeverities[severity],
+					"section":           section.Title,
+				},
+			}
+			if assertion.Remediation != nil {
+				rule.Help = &sarifMessage{Text: assertion.Remediation.Description}
+			}
+			var tags []string
+			tags = append(tags, assertion.Tags...)
+			for _, c := range assertion.Controls {
+				tags = append(tags, c.Framework+" "+c.ID)
+				if rule.HelpURI == "" {
+					rule.HelpURI = c.URL
+				}
+			}
+			if len(tags) > 0 {
+				rule.Properties["tags"] = tags
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+
+			var result sarifResult
+			switch a.Verdict {
+			case executor.VerdictFail, executor.VerdictWaived:
+				result = sarifResult{Kind: "fail", Level: sarifLevels[severity], Message: sarifMessage{Text: sarifFailMessage(assertion)}}
+			case executor.VerdictError:
+				result = sarifResult{Kind: "open", Level: "none", Message: sarifMessage{Text: "The assertion could not be evaluated: " + strings.Join(a.Errors, "; ")}}
+			default:
+				continue
+			}
+			result.RuleID = assertion.Code
+			result.RuleIndex = len(run.Tool.Driver.Rules) - 1
+			result.Locations = []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+				Name:               assertion.Code,
+				FullyQualifiedName: section.Title + "/" + assertion.Code,
+				Kind:               "member",
+			}}}}
+			if w := newWaiver(a); w != nil && !w.Expired {
+				result.Suppressions = []sarifSuppression{{
+					Kind:          "external",
+					Status:        "accepted",
+					Justification: w.Justification + " (approved by " + w.Approver + ", expires " + w.Expires + ")",
+				}}
+			}
+			result.Properties = map[string]interface{}{
+				"verdict":  a.Verdict,
+				"score":    a.Score,
+				"minScore": a.MinScore,
+			}
+			if evidence := assertionEvidence(a); evidence != "" {
+				result.Properties["evidence"] = evidence
+			}
+			if a.Reason != "" {
+				result.Properties["reason"] = a.Reason
+			}
+			if timeouts := collectTimeouts(a); len(timeouts) > 0 {
+				result.Properties["timeouts"] = timeouts
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+
+	out, _ := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	return out
+}
+
+// utcTime formats t in RFC 3339 (UTC), or returns "" for the zero time.
+func utcTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sarifFailMessage describes a failing assertion. SARIF requires a message text, so an
+// assertion without a failDescription falls back to its title.
+func sarifFailMessage(assertion playbook.Assertion) string {
+	if assertion.FailDescription != "" {
+		return assertion.FailDescription
+	}
+	if assertion.Title != "" {
+		return assertion.Title
+	}
+	return "assertion failed"
+}
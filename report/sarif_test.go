@@ -0,0 +1,72 @@
+package report
+
+import (
+	"encoding/json"
+	"github.com/benedictjohannes/crobe/playbook"
+	"testing"
+)
+
+func TestGenerateReport_SARIF(t *testing.T) {
+	res := GenerateReport(sampleTrace(playbook.OutputSARIF))
+	if len(res.Outputs) != 1 || res.Outputs[0].Name() != "report.sarif" {
+		t.Fatalf("expected a report.sarif output, got %+v", res.Outputs)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(res.Outputs[0].Content, &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a SARIF 2.1.0 log with one run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Invocations[0].Machine != "laptop-42" || run.Invocations[0].Account != "alice" {
+		t.Errorf("invocation = %+v", run.Invocations[0])
+	}
+	if len(run.Tool.Driver.Rules) != 5 {
+		t.Fatalf("expected a rule per assertion, got %d", len(run.Tool.Driver.Rules))
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.ID != "SSH_ROOT" || rule.DefaultConfiguration.Level != "error" || rule.Properties["security-severity"] != "9.5" || rule.HelpURI != "https://example.com/ac-6" {
+		t.Errorf("rule = %+v", rule)
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("expected results for the failed, waived and errored assertions, got %d", len(run.Results))
+	}
+	for i, want := range []struct {
+		ruleID, kind, level string
+		suppressed          bool
+	}{
+		{"SSH_ROOT", "fail", "error", false},
+		{"DISK_ENCRYPTION", "fail", "note", true},
+		{"FIREWALL", "open", "none", false},
+	} {
+		got := run.Results[i]
+		if got.RuleID != want.ruleID || got.Kind != want.kind || got.Level != want.level || (len(got.Suppressions) > 0) != want.suppressed {
+			t.Errorf("result %d = %+v; want %+v", i, got, want)
+		}
+	}
+	if run.Results[0].Message.Text != "PermitRootLogin is enabled" {
+		t.Errorf("expected the fail description as message, got %q", run.Results[0].Message.Text)
+	}
+}
+
+func TestGenerateReport_SARIF_MessageFallback(t *testing.T) {
+	trace := sampleTrace(playbook.OutputSARIF)
+	trace.Sections[0].Assertions[0].PlaybookAssertion.FailDescription = ""
+	trace.Sections[0].Assertions[1].PlaybookAssertion.FailDescription = ""
+	trace.Sections[0].Assertions[1].PlaybookAssertion.Title = ""
+
+	var log sarifLog
+	if err := json.Unmarshal(GenerateReport(trace).Outputs[0].Content, &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	results := log.Runs[0].Results
+	if results[0].Message.Text != "SSH Root Login" {
+		t.Errorf("expected the title as message, got %q", results[0].Message.Text)
+	}
+	if results[1].Message.Text != "assertion failed" {
+		t.Errorf("expected a generic message, got %q", results[1].Message.Text)
+	}
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+)
+
+// SectionSummary carries the verdict counts and score of a single section.
+type SectionSummary struct {
+	Title string `json:"title"`
+	Stats Stats  `json:"stats"`
+}
+
+// scoreTally accumulates the weights of scored assertions. Passed assertions earn their
+// weight; failed and errored ones (which could not be shown compliant) only add to the
+// possible total. Skipped, not applicable and waived assertions do not count.
+type scoreTally struct {
+	earned   int
+	possible int
+}
+
+func (t *scoreTally) add(a executor.AssertionContext) {
+	weight := a.PlaybookAssertion.GetWeight()
+	switch a.Verdict {
+	case executor.VerdictPass:
+		t.earned += weight
+		t.possible += weight
+	case executor.VerdictFail, executor.VerdictError:
+		t.possible += weight
+	}
+}
+
+// apply sets the score and grade of s. Nothing is set when no weight was scored.
+func (t scoreTally) apply(s *Stats) {
+	if t.possible == 0 {
+		return
+	}
+	score := math.Round(float64(t.earned)*1000/float64(t.possible)) / 10
+	s.Score = &score
+	s.Grade = gradeFor(score)
+}
+
+// gradeFor maps a percentage score to a letter grade.
+func gradeFor(score float64) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	}
+	return "F"
+}
+
+func (s *Stats) count(v executor.Verdict) {
+	switch v {
+	case executor.VerdictPass:
+		s.Passed++
+	case executor.VerdictFail:
+		s.Failed++
+	case executor.VerdictError:
+		s.Errored++
+	case executor.VerdictSkipped:
+		s.Skipped++
+	case executor.VerdictNotApplicable:
+		s.NotApplicable++
+	case executor.VerdictWaived:
+		s.Waived++
+	}
+}
+
+// scoreLabel formats the score of s, eg: "87.5% (B)", or "-" when nothing was scored.
+func (s Stats) scoreLabel() string {
+	if s.Score == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%s)", *s.Score, s.Grade)
+}
+
+func writeSummaryMarkdown(md *strings.Builder, stats Stats, sections []SectionSummary) {
+	md.WriteString("## Summary\n\n")
+	md.WriteString(fmt.Sprintf("**Overall score:** %s\n\n", stats.scoreLabel()))
+	md.WriteString("| Section | Score | Pass | Fail | Error | Skipped | N/A | Waived |\n")
+	md.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, section := range sections {
+		s := section.Stats
+		md.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %d | %d | %d | %d |\n", section.Title, s.scoreLabel(), s.Passed, s.Failed, s.Errored, s.Skipped, s.NotApplicable, s.Waived))
+	}
+	md.WriteString("\n---\n\n")
+}
@@ -0,0 +1,114 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGradeFor(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{100, "A"},
+		{90, "A"},
+		{89.9, "B"},
+		{80, "B"},
+		{70, "C"},
+		{60, "D"},
+		{59.9, "F"},
+		{0, "F"},
+	}
+	for _, tt := range tests {
+		if got := gradeFor(tt.score); got != tt.want {
+			t.Errorf("gradeFor(%v) = %q; want %q", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateReport_Score(t *testing.T) {
+	assertion := func(code string, severity playbook.Severity, verdict executor.Verdict) executor.AssertionContext {
+		return executor.AssertionContext{
+			PlaybookAssertion: playbook.Assertion{Code: code, Title: code, Severity: severity},
+			Verdict:           verdict,
+		}
+	}
+	trace := executor.ExecutionTrace{
+		Playbook: playbook.Playbook{Title: "Score"},
+		Sections: []executor.SectionContext{
+			{
+				PlaybookSection: playbook.Section{Title: "Access"},
+				Assertions: []executor.AssertionContext{
+					assertion("CRIT_PASS", playbook.SeverityCritical, executor.VerdictPass), // 8/8
+					assertion("HIGH_FAIL", playbook.SeverityHigh, executor.VerdictFail),     // 0/4
+					assertion("INFO_FAIL", playbook.SeverityInfo, executor.VerdictFail),     // 0/0
+				},
+			},
+			{
+				PlaybookSection: playbook.Section{Title: "Network"},
+				Assertions: []executor.AssertionContext{
+					assertion("MED_PASS", "", executor.VerdictPass),                            // 2/2
+					assertion("LOW_ERR", playbook.SeverityLow, executor.VerdictError),          // 0/1
+					assertion("HIGH_NA", playbook.SeverityHigh, executor.VerdictNotApplicable), // not scored
+				},
+			},
+			{
+				PlaybookSection: playbook.Section{Title: "Skipped"},
+				Assertions: []executor.AssertionContext{
+					assertion("SKIP", playbook.SeverityCritical, executor.VerdictSkipped),
+				},
+			},
+		},
+		TotalPassed:        2,
+		TotalFailed:        2,
+		TotalErrored:       1,
+		TotalSkipped:       1,
+		TotalNotApplicable: 1,
+	}
+
+	res := GenerateReport(trace)
+	stats := res.Structured.Stats
+
+	// (8 + 2) / (8 + 4 + 0 + 2 + 1) = 66.7%
+	if stats.Score == nil || *stats.Score != 66.7 || stats.Grade != "D" {
+		t.Errorf("overall score = %v (%s); want 66.7 (D)", stats.Score, stats.Grade)
+	}
+
+	sections := res.Structured.Sections
+	if len(sections) != 3 {
+		t.Fatalf("expected 3 section summaries, got %d", len(sections))
+	}
+	if s := sections[0].Stats; *s.Score != 66.7 || s.Passed != 1 || s.Failed != 2 {
+		t.Errorf("Access stats = %+v", s)
+	}
+	if s := sections[1].Stats; *s.Score != 66.7 || s.Errored != 1 || s.NotApplicable != 1 {
+		t.Errorf("Network stats = %+v", s)
+	}
+	if s := sections[2].Stats; s.Score != nil || s.Grade != "" || s.Skipped != 1 {
+		t.Errorf("Skipped section should not be scored, got %+v", s)
+	}
+
+	if a := res.Structured.Assertions["MED_PASS"]; a.Severity != playbook.SeverityMedium || a.Weight != 2 {
+		t.Errorf("MED_PASS severity/weight = %s/%d; want medium/2", a.Severity, a.Weight)
+	}
+
+	for _, want := range []string{
+		"**Overall score:** 66.7% (D)",
+		"| Access | 66.7% (D) | 1 | 2 | 0 | 0 | 0 |",
+		"| Skipped | - | 0 | 0 | 0 | 1 | 0 |",
+		"**Severity:** critical (weight 8)",
+	} {
+		if !strings.Contains(res.Markdown, want) {
+			t.Errorf("expected %q in markdown", want)
+		}
+	}
+	if strings.Contains(res.Markdown, "**Severity:** medium") {
+		t.Errorf("default severity should not be printed")
+	}
+	if !strings.Contains(stats.Summary(), "SCORE: 66.7% (D)") {
+		t.Errorf("Summary() = %q; want the score", stats.Summary())
+	}
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// Waiver records the waiver matching a failed assertion, for the audit trail. An expired
+// waiver did not apply: the assertion stayed failed.
+type Waiver struct {
+	playbook.Waiver
+	Expired bool `json:"expired"`
+}
+
+func newWaiver(a executor.AssertionContext) *Waiver {
+	if a.Waiver == nil {
+		return nil
+	}
+	return &Waiver{Waiver: a.Waiver.Waiver, Expired: a.Waiver.Expired}
+}
+
+func writeWaiverLog(log *strings.Builder, w *Waiver) {
+	if w.Expired {
+		log.WriteString(fmt.Sprintf(">>> EXPIRED WAIVER: expired on %s, approved by %s: %s <<<\n", w.Expires, w.Approver, w.Justification))
+		return
+	}
+	log.WriteString(fmt.Sprintf(">>> WAIVED until %s, approved by %s: %s <<<\n", w.Expires, w.Approver, w.Justification))
+}
+
+func writeWaiverMarkdown(md *strings.Builder, w *Waiver) {
+	if w.Expired {
+		md.WriteString(fmt.Sprintf("> 🚨 **Expired waiver:** this failure was waived until %s (approved by %s: %s). The waiver no longer applies; renew or remove it.\n\n", w.Expires, w.Approver, w.Justification))
+		return
+	}
+	md.WriteString(fmt.Sprintf("> 📝 **Waiver:** %s  \n> Approved by %s, expires %s.\n\n", w.Justification, w.Approver, w.Expires))
+}
+
+// writeWaiversMarkdown lists every waiver that matched a failed assertion.
+func writeWaiversMarkdown(md *strings.Builder, waivers []*Waiver, codes []string) {
+	md.WriteString("## Waivers\n\n")
+	md.WriteString("| Assertion | Status | Justification | Approver | Expires |\n")
+	md.WriteString("|---|---|---|---|---|\n")
+	for i, w := range waivers {
+		status := "📝 Active"
+		if w.Expired {
+			status = "🚨 Expired"
+		}
+		md.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", codes[i], status, w.Justification, w.Approver, w.Expires))
+	}
+	md.WriteString("\n")
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_Waivers(t *testing.T) {
+	waiver := playbook.Waiver{
+		Codes:         []string{"DISK_ENCRYPTION"},
+		Hosts:         []string{"kiosk-*"},
+		Justification: "Kiosks have no user data",
+		Approver:      "CISO",
+		Expires:       "2999-12-31",
+	}
+	lapsed := playbook.Waiver{Codes: []string{"SSH_ROOT"}, Justification: "Migration", Approver: "Ops lead", Expires: "2000-01-01"}
+
+	waived := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "DISK_ENCRYPTION", Title: "Disk Encryption", FailDescription: "Disk is not encrypted"},
+		Verdict:           executor.VerdictWaived,
+		Score:             -1,
+		Waiver:            &executor.WaiverTrace{Waiver: waiver},
+	}
+	expired := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "SSH_ROOT", Title: "SSH Root Login"},
+		Verdict:           executor.VerdictFail,
+		Score:             -1,
+		Waiver:            &executor.WaiverTrace{Waiver: lapsed, Expired: true},
+	}
+	passed := executor.AssertionContext{
+		PlaybookAssertion: playbook.Assertion{Code: "FIREWALL", Title: "Firewall"},
+		Verdict:           executor.VerdictPass,
+		Score:             1,
+	}
+	trace := executor.ExecutionTrace{
+		Playbook:    playbook.Playbook{Title: "Waivers"},
+		Hostname:    "kiosk-01",
+		TotalPassed: 1,
+		TotalFailed: 1,
+		TotalWaived: 1,
+		Sections: []executor.SectionContext{
+			{PlaybookSection: playbook.Section{Title: "S1"}, Assertions: []executor.AssertionContext{waived, expired, passed}},
+		},
+	}
+
+	res := GenerateReport(trace)
+
+	stats := res.Structured.Stats
+	if stats.Waived != 1 || stats.ExpiredWaivers != 1 || stats.Failed != 1 {
+		t.Errorf("stats = %+v; want 1 waived, 1 expired waiver and 1 failed", stats)
+	}
+	// The waived assertion is left out of the score: 1 passed out of FIREWALL and SSH_ROOT
+	if stats.Score == nil || *stats.Score != 50 {
+		t.Errorf("score = %v; want 50", stats.Score)
+	}
+	if res.Structured.Hostname != "kiosk-01" {
+		t.Errorf("hostname = %q", res.Structured.Hostname)
+	}
+
+	raw, err := json.Marshal(res.Structured.Assertions["DISK_ENCRYPTION"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"verdict":"waived"`, `"justification":"Kiosks have no user data"`, `"approver":"CISO"`, `"expires":"2999-12-31"`, `"hosts":["kiosk-*"]`, `"expired":false`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected %s in JSON assertion, got %s", want, raw)
+		}
+	}
+	if w := res.Structured.Assertions["SSH_ROOT"].Waiver; w == nil || !w.Expired {
+		t.Errorf("expected the expired waiver on SSH_ROOT, got %+v", w)
+	}
+	if res.Structured.Assertions["FIREWALL"].Waiver != nil {
+		t.Errorf("FIREWALL should have no waiver")
+	}
+
+	md := res.Markdown
+	for _, want := range []string{
+		"> 🚨 **1 expired waiver(s):**",
+		"> 📝 **Waived failure:** Disk is not encrypted",
+		"> 📝 **Waiver:** Kiosks have no user data",
+		"> 🚨 **Expired waiver:** this failure was waived until 2000-01-01",
+		"## Waivers",
+		"| DISK_ENCRYPTION | 📝 Active | Kiosks have no user data | CISO | 2999-12-31 |",
+		"| SSH_ROOT | 🚨 Expired | Migration | Ops lead | 2000-01-01 |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in markdown", want)
+		}
+	}
+	for _, want := range []string{
+		">>> WAIVED until 2999-12-31, approved by CISO: Kiosks have no user data <<<",
+		">>> EXPIRED WAIVER: expired on 2000-01-01, approved by Ops lead: Migration <<<",
+	} {
+		if !strings.Contains(res.Log, want) {
+			t.Errorf("expected %q in log", want)
+		}
+	}
+	if !strings.Contains(stats.Summary(), "WAIVED: 1, EXPIRED WAIVERS: 1") {
+		t.Errorf("summary = %q", stats.Summary())
+	}
+}
+
+func TestGenerateReport_NoWaivers(t *testing.T) {
+	trace := executor.ExecutionTrace{
+		Sections: []executor.SectionContext{
+			{Assertions: []executor.AssertionContext{{PlaybookAssertion: playbook.Assertion{Code: "A"}, Verdict: executor.VerdictFail}}},
+		},
+	}
+	res := GenerateReport(trace)
+	if strings.Contains(res.Markdown, "## Waivers") || strings.Contains(res.Markdown, "expired waiver") {
+		t.Errorf("no waiver section expected when nothing was waived")
+	}
+}
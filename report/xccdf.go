@@ -0,0 +1,248 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	"github.com/benedictjohannes/crobe/executor"
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+// xccdfNamespace is the XCCDF 1.2 namespace, and xccdfIDPrefix the reverse DNS name that
+// qualifies the IDs of the elements crobe renders (xccdf_<namespace>_<type>_<name>).
+const (
+	xccdfNamespace = "http://checklists.nist.gov/xccdf/1.2"
+	xccdfIDPrefix  = "xccdf_io.github.benedictjohannes.crobe_"
+)
+
+// XCCDF 1.2 benchmark with its TestResult, limited to what crobe reports. See
+// https://csrc.nist.gov/publications/detail/nistir/7275/rev-4/final
+type xccdfBenchmark struct {
+	XMLName    xml.Name        `xml:"Benchmark"`
+	Xmlns      string          `xml:"xmlns,attr"`
+	ID         string          `xml:"id,attr"`
+	Resolved   bool            `xml:"resolved,attr"`
+	Status     string          `xml:"status"`
+	Title      string          `xml:"title"`
+	Version    string          `xml:"version"`
+	Groups     []xccdfGroup    `xml:"Group"`
+	TestResult xccdfTestResult `xml:"TestResult"`
+}
+
+type xccdfGroup struct {
+	ID    string      `xml:"id,attr"`
+	Title string      `xml:"title"`
+	Rules []xccdfRule `xml:"Rule"`
+}
+
+type xccdfRule struct {
+	ID       string       `xml:"id,attr"`
+	Selected bool         `xml:"selected,attr"`
+	Severity string       `xml:"severity,attr"`
+	Weight   int          `xml:"weight,attr"`
+	Title    string       `xml:"title"`
+	Idents   []xccdfIdent `xml:"ident"`
+}
+
+type xccdfIdent struct {
+	System string `xml:"system,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type xccdfTestResult struct {
+	ID          string            `xml:"id,attr"`
+	StartTime   string            `xml:"start-time,attr,omitempty"`
+	EndTime     string            `xml:"end-time,attr,omitempty"`
+	TestSystem  string            `xml:"test-system,attr"`
+	Title       string            `xml:"title"`
+	Identity    *xccdfIdentity    `xml:"identity"`
+	Target      string            `xml:"target"`
+	TargetFacts []xccdfFact       `xml:"target-facts>fact"`
+	RuleResults []xccdfRuleResult `xml:"rule-result"`
+	Scores      []xccdfScore      `xml:"score"`
+}
+
+type xccdfIdentity struct {
+	Authenticated bool   `xml:"authenticated,attr"`
+	Privileged    bool   `xml:"privileged,attr"`
+	Name          string `xml:",chardata"`
+}
+
+type xccdfFact struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xccdfRuleResult struct {
+	IDRef    string         `xml:"idref,attr"`
+	Time     string         `xml:"time,attr,omitempty"`
+	Severity string         `xml:"severity,attr"`
+	Weight   int            `xml:"weight,attr"`
+	Result   string         `xml:"result"`
+	Override *xccdfOverride `xml:"override"`
+	Idents   []xccdfIdent   `xml:"ident"`
+	Messages []xccdfMessage `xml:"message"`
+}
+
+type xccdfOverride struct {
+	Time      string `xml:"time,attr"`
+	Authority string `xml:"authority,attr"`
+	OldResult string `xml:"old-result"`
+	NewResult string `xml:"new-result"`
+	Remark    string `xml:"remark"`
+}
+
+type xccdfMessage struct {
+	Severity string `xml:"severity,attr"`
+	Text     string `xml:",chardata"`
+}
+
+type xccdfScore struct {
+	System  string `xml:"system,attr"`
+	Maximum string `xml:"maximum,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// xccdfResults maps verdicts to XCCDF rule results. Waived failures are overridden from fail
+// to informational, as they count neither as passed nor as failed.
+var xccdfResults = map[executor.Verdict]string{
+	executor.VerdictPass:          "pass",
+	executor.VerdictFail:          "fail",
+	executor.VerdictError:         "error",
+	executor.VerdictSkipped:       "notselected",
+	executor.VerdictNotApplicable: "notapplicable",
+	executor.VerdictWaived:        "informational",
+}
+
+// xccdfSeverities maps severities to XCCDF, which has no critical.
+var xccdfSeverities = map[playbook.Severity]string{
+	playbook.SeverityInfo:     "info",
+	playbook.SeverityLow:      "low",
+	playbook.SeverityMedium:   "medium",
+	playbook.SeverityHigh:     "high",
+	playbook.SeverityCritical: "high",
+}
+
+var xccdfUnsafeID = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// xccdfIDs hands out the XCCDF 1.2 IDs of a document, mapped to the name they were made of.
+type xccdfIDs map[string]string
+
+// id returns the XCCDF 1.2 ID of the type for name. Names only differing by the characters
+// an ID cannot hold would share it, so the later ones get a short hash of the name appended.
+func (ids xccdfIDs) id(kind, name string) string {
+	if name == "" {
+		name = "default"
+	}
+	id := xccdfIDPrefix + kind + "_" + xccdfUnsafeID.ReplaceAllString(name, "_")
+	if owner, ok := ids[id]; ok && owner != name {
+		sum := sha256.Sum256([]byte(name))
+		id += "_" + hex.EncodeToString(sum[:4])
+	}
+	ids[id] = name
+	return id
+}
+
+// renderXCCDF renders the report as an XCCDF 1.2 benchmark, with a group per section and a
+// rule per assertion, holding a TestResult with a rule-result per assertion. Like OSCAL, it
+// only needs the JSON report.
+func renderXCCDF(res FinalReport) ([]byte, error) {
+	ids := make(xccdfIDs)
+	benchmark := xccdfBenchmark{
+		Xmlns:    xccdfNamespace,
+		ID:       ids.id("benchmark", res.Title),
+		Resolved: true,
+		Status:   "accepted",
+		Title:    res.Title,
+		Version:  "1.0",
+	}
+	result := xccdfTestResult{
+		ID:         ids.id("testresult", res.Title),
+		StartTime:  utcTime(res.Timestamps.Start),
+		EndTime:    utcTime(res.Timestamps.End),
+		TestSystem: "crobe",
+		Title:      "crobe results for " + res.Hostname,
+		Target:     res.Hostname,
+	}
+	if res.Username != "" {
+		result.Identity = &xccdfIdentity{Name: res.Username}
+	}
+	for _, f := range []xccdfFact{
+		{Name: "urn:xccdf:fact:asset:identifier:host_name", Value: res.Hostname},
+		{Name: "urn:xccdf:fact:identifier:os", Value: res.OS},
+		{Name: "urn:xccdf:fact:identifier:arch", Value: res.Arch},
+	} {
+		if f.Value != "" {
+			f.Type = "string"
+			result.TargetFacts = append(result.TargetFacts, f)
+		}
+	}
+
+	groups := make(map[string]int)
+	for _, code := range orderedCodes(res) {
+		a := res.Assertions[code]
+		severity := xccdfSeverities[a.Severity]
+		if severity == "" {
+			severity = "unknown"
+		}
+		var idents []xccdfIdent
+		for _, c := range a.Controls {
+			idents = append(idents, xccdfIdent{System: "urn:crobe:framework:" + xccdfUnsafeID.ReplaceAllString(c.Framework, "_"), Value: c.ID})
+		}
+
+		rule := xccdfRule{ID: ids.id("rule", code), Selected: true, Severity: severity, Weight: a.Weight, Title: assertionTitle(code, a), Idents: idents}
+		i, ok := groups[a.Section]
+		if !ok {
+			i = len(benchmark.Groups)
+			groups[a.Section] = i
+			benchmark.Groups = append(benchmark.Groups, xccdfGroup{ID: ids.id("group", a.Section), Title: a.Section})
+		}
+		benchmark.Groups[i].Rules = append(benchmark.Groups[i].Rules, rule)
+
+		ruleResult := xccdfRuleResult{
+			IDRef:    rule.ID,
+			Time:     utcTime(a.Timestamps.End),
+			Severity: severity,
+			Weight:   a.Weight,
+			Result:   xccdfResults[a.Verdict],
+			Idents:   idents,
+		}
+		if ruleResult.Result == "" {
+			ruleResult.Result = "unknown"
+		}
+		if w := a.Waiver; w != nil && !w.Expired {
+			ruleResult.Override = &xccdfOverride{
+				Time:      utcTime(res.Timestamps.End),
+				Authority: w.Approver,
+				OldResult: "fail",
+				NewResult: ruleResult.Result,
+				Remark:    fmt.Sprintf("Waived until %s: %s", w.Expires, w.Justification),
+			}
+		}
+		if a.Reason != "" {
+			ruleResult.Messages = append(ruleResult.Messages, xccdfMessage{Severity: "info", Text: a.Reason})
+		}
+		for _, e := range a.Errors {
+			ruleResult.Messages = append(ruleResult.Messages, xccdfMessage{Severity: "error", Text: e})
+		}
+		result.RuleResults = append(result.RuleResults, ruleResult)
+	}
+
+	// XCCDF requires a score: runs without scored weight score 0
+	score := 0.0
+	if res.Stats.Score != nil {
+		score = *res.Stats.Score
+	}
+	result.Scores = []xccdfScore{{System: "urn:crobe:scoring:weighted", Maximum: "100", Value: fmt.Sprintf("%.2f", score)}}
+	benchmark.TestResult = result
+
+	out, err := xml.MarshalIndent(benchmark, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the XCCDF report: %w", err)
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/benedictjohannes/crobe/playbook"
+)
+
+func TestGenerateReport_XCCDF(t *testing.T) {
+	res := GenerateReport(sampleTrace(playbook.OutputXCCDF))
+	if len(res.Outputs) != 1 || res.Outputs[0].Name() != "report.xccdf.xml" {
+		t.Fatalf("expected a report.xccdf.xml output, got %+v", res.Outputs)
+	}
+	content := string(res.Outputs[0].Content)
+	if !strings.Contains(content, `<Benchmark xmlns="http://checklists.nist.gov/xccdf/1.2" id="xccdf_io.github.benedictjohannes.crobe_benchmark_Quarterly_Audit"`) {
+		t.Errorf("expected an XCCDF 1.2 benchmark, got %.200s", content)
+	}
+
+	var benchmark xccdfBenchmark
+	if err := xml.Unmarshal(res.Outputs[0].Content, &benchmark); err != nil {
+		t.Fatalf("invalid XCCDF XML: %v", err)
+	}
+	if len(benchmark.Groups) != 2 || benchmark.Groups[0].Title != "Access" || len(benchmark.Groups[0].Rules) != 3 {
+		t.Fatalf("expected a group per section, got %+v", benchmark.Groups)
+	}
+
+	result := benchmark.TestResult
+	if result.Target != "laptop-42" || result.Identity == nil || result.Identity.Name != "alice" || result.StartTime != "2026-10-16T08:00:00Z" {
+		t.Errorf("test result = %+v", result)
+	}
+	if len(result.TargetFacts) != 3 || result.TargetFacts[0].Value != "laptop-42" {
+		t.Errorf("target facts = %+v", result.TargetFacts)
+	}
+	if len(result.Scores) != 1 || result.Scores[0].Value != "16.70" {
+		t.Errorf("scores = %+v", result.Scores)
+	}
+
+	results := make(map[string]xccdfRuleResult)
+	for _, r := range result.RuleResults {
+		results[strings.TrimPrefix(r.IDRef, xccdfIDPrefix+"rule_")] = r
+	}
+	for code, want := range map[string]string{
+		"SSH_ROOT":        "fail",
+		"DISK_ENCRYPTION": "informational",
+		"FIREWALL":        "error",
+		"BITLOCKER":       "notapplicable",
+		"UPDATES":         "pass",
+	} {
+		if got := results[code].Result; got != want {
+			t.Errorf("%s result = %q, want %q", code, got, want)
+		}
+	}
+	ssh := results["SSH_ROOT"]
+	if ssh.Severity != "high" || len(ssh.Idents) != 2 || ssh.Idents[1].System != "urn:crobe:framework:CIS_Ubuntu_22.04" || ssh.Idents[1].Value != "5.2.10" {
+		t.Errorf("SSH_ROOT rule-result = %+v", ssh)
+	}
+	if o := results["DISK_ENCRYPTION"].Override; o == nil || o.OldResult != "fail" || o.Authority != "CISO" {
+		t.Errorf("expected the waiver as override, got %+v", o)
+	}
+	if m := results["FIREWALL"].Messages; len(m) != 1 || m[0].Severity != "error" {
+		t.Errorf("expected the error as message, got %+v", m)
+	}
+}
+
+func TestGenerateReport_XCCDF_IDCollisions(t *testing.T) {
+	trace := sampleTrace(playbook.OutputXCCDF)
+	trace.Sections[0].PlaybookSection.Title = "Access Control"
+	trace.Sections[1].PlaybookSection.Title = "Access/Control"
+
+	var benchmark xccdfBenchmark
+	if err := xml.Unmarshal(GenerateReport(trace).Outputs[0].Content, &benchmark); err != nil {
+		t.Fatalf("invalid XCCDF XML: %v", err)
+	}
+	if len(benchmark.Groups) != 2 {
+		t.Fatalf("expected a group per section, got %+v", benchmark.Groups)
+	}
+	first, second := benchmark.Groups[0].ID, benchmark.Groups[1].ID
+	if first != xccdfIDPrefix+"group_Access_Control" || !strings.HasPrefix(second, first+"_") {
+		t.Errorf("expected the second group ID to be disambiguated, got %q and %q", first, second)
+	}
+
+	ids := make(xccdfIDs)
+	if a, b := ids.id("rule", "A B"), ids.id("rule", "A B"); a != b {
+		t.Errorf("expected the same name to keep its ID, got %q and %q", a, b)
+	}
+}
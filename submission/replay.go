@@ -0,0 +1,82 @@
+package submission
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is how old a submission ReplayGuard accepts by default. Reports that could
+// not be delivered are spooled by the agent and keep their original timestamp, so it matches
+// the default --spool-max-age of the agent: a spooled report is accepted for as long as it is
+// retried.
+const DefaultMaxAge = 30 * 24 * time.Hour
+
+// DefaultMaxSkew is how far in the future a submission timestamp may be, to tolerate clock
+// differences between the device and the server.
+const DefaultMaxSkew = 5 * time.Minute
+
+// ReplayGuard rejects stale submissions and reused nonces. Check a submission only once its
+// signature verified, since the guard trusts the material it is given. It is safe for
+// concurrent use.
+//
+// Nonces are remembered in memory until their submission becomes stale, so servers running
+// several instances should share nonces through their own store instead.
+type ReplayGuard struct {
+	// MaxAge is how old a submission may be.
+	MaxAge time.Duration
+	// MaxSkew is how far in the future a submission may be.
+	MaxSkew time.Duration
+	// RequireNonce rejects submissions without a nonce, for servers handing out a
+	// NonceHeader with every playbook.
+	RequireNonce bool
+
+	mu sync.Mutex
+	// seen holds the accepted nonces, with the time after which they can be forgotten.
+	seen map[string]time.Time
+}
+
+// NewReplayGuard returns a guard accepting submissions up to maxAge old.
+func NewReplayGuard(maxAge time.Duration) *ReplayGuard {
+	return &ReplayGuard{MaxAge: maxAge, MaxSkew: DefaultMaxSkew}
+}
+
+// Check rejects the submission if its timestamp is missing, older than MaxAge or too far in
+// the future, or if its nonce was already accepted. Otherwise, the nonce is recorded as used.
+func (g *ReplayGuard) Check(m Material, now time.Time) error {
+	if m.Timestamp == "" {
+		return fmt.Errorf("the submission has no timestamp")
+	}
+	timestamp, err := time.Parse(time.RFC3339, m.Timestamp)
+	if err != nil {
+		return fmt.Errorf("malformed timestamp %q: expected RFC 3339", m.Timestamp)
+	}
+	if age := now.Sub(timestamp); age > g.MaxAge {
+		return fmt.Errorf("stale submission: submitted %s ago (maximum %s)", age.Round(time.Second), g.MaxAge)
+	} else if -age > g.MaxSkew {
+		return fmt.Errorf("the submission is timestamped %s in the future", (-age).Round(time.Second))
+	}
+	if m.Nonce == "" {
+		if g.RequireNonce {
+			return fmt.Errorf("the submission has no nonce")
+		}
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for nonce, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, nonce)
+		}
+	}
+	if _, ok := g.seen[m.Nonce]; ok {
+		return fmt.Errorf("nonce %q was already used", m.Nonce)
+	}
+	if g.seen == nil {
+		g.seen = make(map[string]time.Time)
+	}
+	// Past this, the timestamp check rejects the submission anyway
+	g.seen[m.Nonce] = timestamp.Add(g.MaxAge)
+	return nil
+}
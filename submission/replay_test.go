@@ -0,0 +1,56 @@
+package submission
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, nonce string) Material {
+		return Material{DeviceID: "laptop-42", Timestamp: now.Add(d).Format(time.RFC3339), Nonce: nonce}
+	}
+	guard := NewReplayGuard(time.Hour)
+
+	tests := []struct {
+		name    string
+		m       Material
+		wantErr string
+	}{
+		{"fresh", at(-time.Minute, "n1"), ""},
+		{"reused nonce", at(-time.Minute, "n1"), "already used"},
+		{"reused nonce with a new timestamp", at(0, "n1"), "already used"},
+		{"other nonce", at(0, "n2"), ""},
+		{"no nonce", at(0, ""), ""},
+		{"stale", at(-2*time.Hour, "n3"), "stale submission"},
+		{"future", at(time.Hour, "n4"), "in the future"},
+		{"small skew", at(time.Minute, "n5"), ""},
+		{"no timestamp", Material{Nonce: "n6"}, "no timestamp"},
+		{"malformed timestamp", Material{Timestamp: "yesterday"}, "malformed timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check(tt.m, now)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Check() error = %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Nonces are forgotten once their submission is stale anyway
+	later := now.Add(2 * time.Hour)
+	if err := guard.Check(Material{Timestamp: later.Format(time.RFC3339), Nonce: "n2"}, later); err != nil {
+		t.Errorf("expected an expired nonce to be forgotten, got %v", err)
+	}
+	if len(guard.seen) != 1 {
+		t.Errorf("expected expired nonces to be pruned, %d remain", len(guard.seen))
+	}
+
+	guard.RequireNonce = true
+	if err := guard.Check(at(0, ""), now); err == nil || !strings.Contains(err.Error(), "no nonce") {
+		t.Errorf("expected a missing nonce to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package submission holds what report servers need to authenticate the reports submitted
+// by crobe agents: the envelope fields, the material signed by enrolled devices, its
+// verification and the freshness checks guarding against replayed submissions.
+package submission
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Names of the submission fields, in the JSON envelope and as multipart form fields.
+const (
+	FieldKeyID             = "keyId"
+	FieldSignature         = "signature"
+	FieldDeviceID          = "deviceId"
+	FieldTimestamp         = "timestamp"
+	FieldNonce             = "nonce"
+	FieldEnvelopeSignature = "envelopeSignature"
+	FieldEncryption        = "encryption"
+)
+
+// EncryptionAge is the encryption field of submissions whose report files are encrypted in
+// the age format (https://age-encryption.org) to the playbook's reportRecipients. The files
+// are then age files, base64 encoded like plain ones.
+const EncryptionAge = "age"
+
+// NonceHeader is the response header of a playbook server handing out a nonce. The agent
+// includes it in the signed material of the report submitted for that playbook, and servers
+// accept each nonce only once (see ReplayGuard).
+const NonceHeader = "X-Crobe-Nonce"
+
+// Device key algorithms.
+const (
+	AlgorithmEd25519   = "ed25519"
+	AlgorithmECDSAP256 = "ecdsa-p256"
+)
+
+// Files are the base64 encoded report files of a submission, exactly as sent in the
+// multipart parts or the JSON envelope.
+type Files struct {
+	JSON     string
+	Markdown string
+	Log      string
+	// Outputs are the additional report outputs (reportOutputs) submitted when
+	// includeOutputs is set, by file name (eg: report.sarif).
+	Outputs map[string]string
+}
+
+// Envelope is the body of a JSON submission (format: json).
+type Envelope struct {
+	JSON          string `json:"json"`
+	JSONSignature string `json:"jsonSignature,omitempty"`
+	MD            string `json:"md"`
+	MDSignature   string `json:"mdSignature,omitempty"`
+	Log           string `json:"log"`
+	LogSignature  string `json:"logSignature,omitempty"`
+	// Files are the additional report outputs, by file name (eg: report.sarif), when the
+	// destination has includeOutputs set.
+	Files map[string]string `json:"files,omitempty"`
+	// Encryption is EncryptionAge when the files are encrypted, empty otherwise.
+	Encryption string `json:"encryption,omitempty"`
+	// DeviceID, Timestamp and Nonce are signed along with the files, so that a captured
+	// submission cannot be replayed as a fresh one.
+	DeviceID  string `json:"deviceId"`
+	Timestamp string `json:"timestamp"`
+	Nonce     string `json:"nonce,omitempty"`
+	// KeyID and Signature are the device signature of the submission.
+	KeyID     string `json:"keyId,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// EnvelopeSignature is the legacy HMAC-SHA256 of the signed material (if signatureSecret
+	// is set), to be checked with VerifyHMAC.
+	EnvelopeSignature string `json:"envelopeSignature,omitempty"`
+}
+
+// Material returns what the device signed for the envelope, to be checked with Verify
+// against its Signature.
+func (e Envelope) Material() Material {
+	return Material{
+		KeyID:     e.KeyID,
+		DeviceID:  e.DeviceID,
+		Timestamp: e.Timestamp,
+		Nonce:     e.Nonce,
+		Files:     Files{JSON: e.JSON, Markdown: e.MD, Log: e.Log, Outputs: e.Files},
+	}
+}
+
+// DecryptFile decodes a base64 encoded report file of a submission whose Encryption is
+// EncryptionAge, and decrypts it with the identity matching one of the reportRecipients.
+// Verify the signatures first: they cover the encrypted files.
+func DecryptFile(content string, identities ...age.Identity) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("malformed file: expected base64")
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+// Material is what a device signs for a submission.
+type Material struct {
+	// KeyID identifies the device key, as returned by KeyID. Empty without a device key.
+	KeyID string
+	// DeviceID is the hostname of the device.
+	DeviceID string
+	// Timestamp is when the report was submitted, in RFC 3339 format (UTC).
+	Timestamp string
+	// Nonce is the NonceHeader sent with the playbook, if any.
+	Nonce string
+	Files Files
+}
+
+// Bytes returns the canonical encoding of the material, which is what gets signed.
+func (m Material) Bytes() []byte {
+	var b strings.Builder
+	b.WriteString("crobe-submission-v1\n")
+	fmt.Fprintf(&b, "keyId: %s\n", m.KeyID)
+	fmt.Fprintf(&b, "deviceId: %s\n", m.DeviceID)
+	fmt.Fprintf(&b, "timestamp: %s\n", m.Timestamp)
+	fmt.Fprintf(&b, "nonce: %s\n", m.Nonce)
+	fmt.Fprintf(&b, "report.json: %s\n", digest(m.Files.JSON))
+	fmt.Fprintf(&b, "report.md: %s\n", digest(m.Files.Markdown))
+	fmt.Fprintf(&b, "report.log: %s\n", digest(m.Files.Log))
+	names := make([]string, 0, len(m.Files.Outputs))
+	for name := range m.Files.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, digest(m.Files.Outputs[name]))
+	}
+	return []byte(b.String())
+}
+
+func digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign signs the material with a device key (ed25519 or ECDSA P-256), and returns the base64
+// encoded signature.
+func Sign(key crypto.Signer, m Material) (string, error) {
+	algorithm, err := Algorithm(key.Public())
+	if err != nil {
+		return "", err
+	}
+	var sig []byte
+	if algorithm == AlgorithmEd25519 {
+		sig, err = key.Sign(rand.Reader, m.Bytes(), crypto.Hash(0))
+	} else {
+		sum := sha256.Sum256(m.Bytes())
+		sig, err = key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks the base64 encoded signature of the material against the public key the
+// server enrolled under m.KeyID.
+func Verify(pub crypto.PublicKey, m Material, signature string) error {
+	if id, err := KeyID(pub); err != nil {
+		return err
+	} else if id != m.KeyID {
+		return fmt.Errorf("the submission is signed with key %s, not %s", m.KeyID, id)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: expected base64")
+	}
+	valid := false
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pub, m.Bytes(), sig)
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(m.Bytes())
+		valid = ecdsa.VerifyASN1(pub, sum[:], sig)
+	}
+	if !valid {
+		return fmt.Errorf("signature does not match the submission")
+	}
+	return nil
+}
+
+// SignHMAC returns the hex encoded HMAC-SHA256 of the material with a shared secret, the
+// legacy alternative to device keys.
+func SignHMAC(secret string, m Material) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(m.Bytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyHMAC checks the hex encoded HMAC-SHA256 of the material, in constant time.
+func VerifyHMAC(secret string, m Material, signature string) error {
+	if !hmac.Equal([]byte(SignHMAC(secret, m)), []byte(signature)) {
+		return fmt.Errorf("envelope signature does not match the submission")
+	}
+	return nil
+}
+
+// KeyID returns the identifier of a device public key: "SHA256:" followed by the unpadded
+// base64 SHA-256 hash of its PKIX encoding.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	if _, err := Algorithm(pub); err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// Algorithm returns the algorithm of a device public key.
+func Algorithm(pub crypto.PublicKey) (string, error) {
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		return AlgorithmEd25519, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P256() {
+			return AlgorithmECDSAP256, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported device key type %T (expected ed25519 or ECDSA P-256)", pub)
+}
+
+// Enrollment is posted by `crobe enroll` to register a device key with a report server.
+type Enrollment struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the PEM encoded PKIX public key.
+	PublicKey string `json:"publicKey"`
+	Hostname  string `json:"hostname"`
+}
+
+// NewEnrollment describes a device public key for enrollment.
+func NewEnrollment(pub crypto.PublicKey, hostname string) (Enrollment, error) {
+	algorithm, err := Algorithm(pub)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{
+		KeyID:     keyID,
+		Algorithm: algorithm,
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		Hostname:  hostname,
+	}, nil
+}
+
+// ParsePublicKey parses the PEM encoded public key of an enrollment, checking that it is
+// the key enrolled as keyID.
+func (e Enrollment) ParsePublicKey() (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(e.PublicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("publicKey is not a PEM encoded public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse publicKey: %w", err)
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != e.KeyID {
+		return nil, fmt.Errorf("publicKey has key ID %s, not %s", keyID, e.KeyID)
+	}
+	return pub, nil
+}
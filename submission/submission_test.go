@@ -0,0 +1,137 @@
+package submission
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestSignVerify(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, key := range []crypto.Signer{edKey, ecKey} {
+		keyID, err := KeyID(key.Public())
+		if err != nil || !strings.HasPrefix(keyID, "SHA256:") {
+			t.Fatalf("KeyID() = %q, %v", keyID, err)
+		}
+		m := Material{KeyID: keyID, Files: Files{JSON: "e30=", Markdown: "IyBU", Log: "bG9n"}}
+		sig, err := Sign(key, m)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		if err := Verify(key.Public(), m, sig); err != nil {
+			t.Errorf("%T: Verify() error = %v", key, err)
+		}
+
+		tampered := m
+		tampered.Files.Log = "ZWRpdGVk"
+		if err := Verify(key.Public(), tampered, sig); err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("%T: expected a tampered log to fail, got %v", key, err)
+		}
+		if err := Verify(otherKey.Public(), m, sig); err == nil || !strings.Contains(err.Error(), "not SHA256:") {
+			t.Errorf("%T: expected another key to fail, got %v", key, err)
+		}
+		if err := Verify(key.Public(), m, "not base64!"); err == nil || !strings.Contains(err.Error(), "malformed") {
+			t.Errorf("%T: expected a malformed signature error, got %v", key, err)
+		}
+	}
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := Sign(p384, Material{}); err == nil {
+		t.Errorf("expected P-384 keys to be refused")
+	}
+}
+
+func TestMaterial_Bytes(t *testing.T) {
+	m := Material{KeyID: "SHA256:abc", DeviceID: "laptop-42", Timestamp: "2026-10-16T08:00:00Z", Nonce: "n1", Files: Files{JSON: "e30=", Markdown: "IyBU"}}
+	want := "crobe-submission-v1\n" +
+		"keyId: SHA256:abc\n" +
+		"deviceId: laptop-42\n" +
+		"timestamp: 2026-10-16T08:00:00Z\n" +
+		"nonce: n1\n" +
+		"report.json: " + digest("e30=") + "\n" +
+		"report.md: " + digest("IyBU") + "\n" +
+		"report.log: e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n"
+	if got := string(m.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+
+	m.Files.Outputs = map[string]string{"report.xml": "PHg+", "report.sarif": "e30K"}
+	want += "report.sarif: " + digest("e30K") + "\n" + "report.xml: " + digest("PHg+") + "\n"
+	if got := string(m.Bytes()); got != want {
+		t.Errorf("Bytes() with outputs = %q, want %q", got, want)
+	}
+}
+
+func TestSignVerifyHMAC(t *testing.T) {
+	m := Material{DeviceID: "laptop-42", Timestamp: "2026-10-16T08:00:00Z", Files: Files{JSON: "e30="}}
+	sig := SignHMAC("secret", m)
+	if err := VerifyHMAC("secret", m, sig); err != nil {
+		t.Errorf("VerifyHMAC() error = %v", err)
+	}
+	if err := VerifyHMAC("other", m, sig); err == nil {
+		t.Errorf("expected another secret to fail")
+	}
+	replayed := m
+	replayed.Timestamp = "2026-10-17T08:00:00Z"
+	if err := VerifyHMAC("secret", replayed, sig); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a changed timestamp to fail, got %v", err)
+	}
+}
+
+func TestDecryptFile(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	var b bytes.Buffer
+	w, _ := age.Encrypt(&b, identity.Recipient())
+	w.Write([]byte("# Secret"))
+	w.Close()
+
+	plaintext, err := DecryptFile(base64.StdEncoding.EncodeToString(b.Bytes()), identity)
+	if err != nil || string(plaintext) != "# Secret" {
+		t.Errorf("DecryptFile() = %q, %v", plaintext, err)
+	}
+	if _, err := DecryptFile("not base64!", identity); err == nil || !strings.Contains(err.Error(), "malformed") {
+		t.Errorf("expected a malformed file error, got %v", err)
+	}
+	stranger, _ := age.GenerateX25519Identity()
+	if _, err := DecryptFile(base64.StdEncoding.EncodeToString(b.Bytes()), stranger); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("expected another identity to fail, got %v", err)
+	}
+}
+
+func TestEnrollment(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	enrollment, err := NewEnrollment(ecKey.Public(), "laptop-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enrollment.Algorithm != AlgorithmECDSAP256 || enrollment.Hostname != "laptop-42" {
+		t.Errorf("unexpected enrollment: %+v", enrollment)
+	}
+	pub, err := enrollment.ParsePublicKey()
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if !ecKey.PublicKey.Equal(pub) {
+		t.Errorf("ParsePublicKey() returned another key")
+	}
+
+	enrollment.KeyID = "SHA256:forged"
+	if _, err := enrollment.ParsePublicKey(); err == nil || !strings.Contains(err.Error(), "not SHA256:forged") {
+		t.Errorf("expected a key ID mismatch, got %v", err)
+	}
+	enrollment.PublicKey = "garbage"
+	if _, err := enrollment.ParsePublicKey(); err == nil {
+		t.Errorf("expected a PEM error")
+	}
+}
@@ -36,14 +36,31 @@ export interface ScriptContext {
    * Current working directory of the agent.
    */
   cwd: string;
+
+  /**
+   * Effective playbook vars, after environment, --var-file and --var overrides.
+   */
+  vars: Vars;
 }
 
+/**
+ * Playbook vars, by name.
+ */
+export type Vars = Record<string, string | number | boolean>;
+
 /**
  * Signature for Exec.Func
  * Generates the shell command or script to be executed.
  */
 export type ScriptGenerator = (context: ScriptContext) => string;
 
+/**
+ * Signature for Assertion.When and Section.When
+ * Decides whether the assertion (or section) applies to this machine.
+ * A falsy result reports it as not applicable; nothing is executed.
+ */
+export type Predicate = (context: ScriptContext) => boolean;
+
 /**
  * Signature for EvaluationRule.Func
  * Evaluates command output to determine a score.
@@ -52,7 +69,8 @@ export type ScriptGenerator = (context: ScriptContext) => string;
 export type Evaluator = (
   stdout: string,
   stderr: string,
-  assertionContext: AssertionContext
+  assertionContext: AssertionContext,
+  vars: Vars
 ) => -1 | 0 | 1;
 
 /**
@@ -62,5 +80,6 @@ export type Evaluator = (
 export type Gatherer = (
   stdout: string,
   stderr: string,
-  assertionContext: AssertionContext
+  assertionContext: AssertionContext,
+  vars: Vars
 ) => string;
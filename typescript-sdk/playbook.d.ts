@@ -62,8 +62,103 @@ export interface Assertion {
    * Minimum 3 characters.
    */
   failDescription: string;
+
+  /**
+   * Fix for a failed assertion.
+   * Only ever executed when the agent runs with --remediate=apply; --remediate=dry-run shows what would run.
+   */
+  remediation?: Remediation;
+
+  /**
+   * Platforms the assertion applies to.
+   * On other platforms it is reported as not applicable without running anything.
+   * Default: all platforms.
+   */
+  platforms?: Platform[];
+
+  /**
+   * Embedded JS predicate deciding whether the assertion applies.
+   * Evaluated before any preCmds; a falsy result reports the assertion as not applicable.
+   * Signature: ({ assertionContext, env, os, arch, user, cwd, vars }) => boolean
+   */
+  when?: string;
+
+  /**
+   * Path to JS/TS file for when (BUILDER ONLY).
+   * Using this in a real playbook will cause an error.
+   */
+  whenFile?: string;
+
+  /**
+   * How bad a failure of this assertion is. Default: 'medium'.
+   * Drives the default weight of the assertion in the overall score.
+   */
+  severity?: Severity;
+
+  /**
+   * Weight of the assertion in the overall score.
+   * Default: derived from severity (info: 0, low: 1, medium: 2, high: 4, critical: 8).
+   * 0 excludes the assertion from scoring.
+   */
+  weight?: number;
+
+  /**
+   * Compliance framework controls this assertion provides evidence for.
+   */
+  controls?: ControlRef[];
+
+  /**
+   * Free-form labels used to select assertions from the command line
+   * (--tags, --exclude-tags).
+   */
+  tags?: string[];
+
+  /**
+   * Run this assertion alone when assertions are executed concurrently:
+   * it waits for running assertions to finish, and nothing else starts until it is done.
+   */
+  serial?: boolean;
+}
+
+/**
+ * Fix for the condition checked by an assertion.
+ * With --remediate=apply it runs after the assertion fails, then the assertion's cmds are re-run to verify the fix.
+ */
+export interface Remediation {
+  /** What the remediation changes on the system. */
+  description: string;
+
+  /**
+   * Execution that applies the fix.
+   * Values gathered by the assertion are available to func and shellFunc.
+   */
+  exec: Exec;
 }
 
+/**
+ * Reference to a control of a compliance framework.
+ */
+export interface ControlRef {
+  /** Name of the framework (e.g., 'CIS Ubuntu 22.04', 'NIST 800-53', 'ISO 27001'). */
+  framework: string;
+
+  /** Control identifier within the framework (e.g., '5.2.10', 'AC-6', 'A.8.2'). */
+  id: string;
+
+  /** Optional link to the control text. */
+  url?: string;
+}
+
+/**
+ * Severity of an assertion failure.
+ */
+export type Severity = 'info' | 'low' | 'medium' | 'high' | 'critical';
+
+/**
+ * Operating systems an assertion or section can be restricted to.
+ */
+export type Platform = 'linux' | 'mac' | 'windows';
+
 /**
  * A single command unit with execution and evaluation rules.
  * 
@@ -125,7 +220,7 @@ export interface Exec {
   /**
    * Embedded JS code that returns the shell to use. 
    * When specified, takes precedence over shell.
-   * Signature: ({ assertionContext, env, os, arch, user, cwd }) => string
+   * Signature: ({ assertionContext, env, os, arch, user, cwd, vars }) => string
    */
   shellFunc?: string;
 
@@ -148,7 +243,7 @@ export interface Exec {
   /**
    * Embedded JS code for dynamic execution logic.
    * When specified, takes precedence over script.
-   * Signature: ({ assertionContext, env, os, arch, user, cwd }) => string
+   * Signature: ({ assertionContext, env, os, arch, user, cwd, vars }) => string
    */
   func?: string;
 
@@ -167,6 +262,13 @@ export interface Exec {
    * If true, hides stdout/stderr results from logs and markdown reports.
    */
   excludeFromReport?: boolean;
+
+  /**
+   * Maximum execution time as a duration (e.g., '30s', '5m').
+   * Defaults to the playbook's defaultTimeout.
+   * When exceeded, the whole process tree is terminated and the cmd is scored with its failScore.
+   */
+  timeout?: string;
 }
 
 /**
@@ -187,7 +289,7 @@ export interface EvaluationRule {
   /**
    * JS function for custom evaluation.
    * When specified, takes precedence over regex.
-   * Signature: (stdout, stderr, assertionContext) => -1 | 0 | 1
+   * Signature: (stdout, stderr, assertionContext, vars) => -1 | 0 | 1
    */
   func?: string;
 
@@ -226,7 +328,7 @@ export interface GatherSpec {
   /**
    * JS function for custom extraction logic.
    * When specified, takes precedence over regex.
-   * Signature: (stdout, stderr, assertionContext) => string
+   * Signature: (stdout, stderr, assertionContext, vars) => string
    */
   func?: string;
 
@@ -265,7 +367,7 @@ export interface ExitCodeRule {
 /**
  * Supported destinations for generating reports.
  */
-export type ReportDestination = 'folder' | 'https';
+export type ReportDestination = 'folder' | 'https' | 'prometheus';
 
 /**
  * A group of assertions with a title and description.
@@ -286,8 +388,81 @@ export interface Section {
   /**
    * List of assertions within this section.
    * At least one assertion is required.
+   * Include entries are replaced by the assertions of a library file.
    */
-  assertions: Assertion[];
+  assertions: (Assertion | Include)[];
+
+  /**
+   * Platforms the whole section applies to.
+   * On other platforms all its assertions are reported as not applicable.
+   * Default: all platforms.
+   */
+  platforms?: Platform[];
+
+  /**
+   * Embedded JS predicate deciding whether the whole section applies.
+   * Evaluated once, before any of its assertions; a falsy result reports them all as not applicable.
+   * Signature: ({ assertionContext, env, os, arch, user, cwd, vars }) => boolean
+   */
+  when?: string;
+
+  /**
+   * Path to JS/TS file for when (BUILDER ONLY).
+   * Using this in a real playbook will cause an error.
+   */
+  whenFile?: string;
+}
+
+/**
+ * An entry replaced, when the playbook is loaded, by the content of a library file:
+ * its sections (in a playbook's sections) or its assertions (in a section's assertions).
+ */
+export interface Include {
+  /**
+   * Path or HTTPS URL of the library file.
+   * Relative paths resolve against the including file. Remote files may only include HTTPS URLs.
+   * Relative funcFile/whenFile paths inside a library resolve against the library.
+   */
+  include: string;
+}
+
+/**
+ * A file of reusable sections and assertions, pulled into playbooks by include entries.
+ * Libraries may include other libraries; include cycles are rejected.
+ */
+export interface Library {
+  sections?: (Section | Include)[];
+  assertions?: (Assertion | Include)[];
+}
+
+/**
+ * A waiver file (--waivers): time-boxed exceptions accepting the failure of assertions.
+ * Kept apart from the playbook so exceptions can be approved without touching the checks.
+ */
+export interface WaiverFile {
+  waivers: Waiver[];
+}
+
+/**
+ * Accepts the failure of matching assertions until it expires. Matching failures are
+ * reported as 'waived' and do not fail the run; once expired, they count again.
+ */
+export interface Waiver {
+  /** Assertion codes covered by the waiver (glob patterns allowed, e.g. 'DISK_*'). */
+  codes: string[];
+  /** Limits the waiver to these hostnames (glob patterns, case-insensitive). Omit for any host. */
+  hosts?: string[];
+  /** Limits the waiver to these usernames (glob patterns). Omit for any user. */
+  users?: string[];
+  /** Why the failure is accepted. */
+  justification: string;
+  /** Who approved the exception. */
+  approver: string;
+  /**
+   * Last day the waiver applies (YYYY-MM-DD, local time).
+   * @example "2026-12-31"
+   */
+  expires: string;
 }
 
 /**
@@ -295,6 +470,22 @@ export interface Section {
  */
 export type ReportFormat = 'multipart' | 'json';
 
+/**
+ * Additional report output formats.
+ * - `sarif`: SARIF 2.1.0 log, failed assertions as code-scanning results.
+ * - `junit`: JUnit XML, a testsuite per section and a testcase per assertion
+ *   (waived and not applicable assertions are skipped).
+ * - `html`: self-contained HTML page, readable offline.
+ * - `oscal`: OSCAL assessment-results JSON (also available for existing
+ *   JSON reports with `crobe convert`).
+ * - `xccdf`: XCCDF 1.2 benchmark and TestResult XML, for SCAP tooling (also
+ *   available with `crobe convert --format xccdf`).
+ * - `openmetrics`: the gauges of the `prometheus` destination in the
+ *   OpenMetrics text format, ending with `# EOF` (submitted as
+ *   `application/openmetrics-text`).
+ */
+export type OutputFormat = 'sarif' | 'junit' | 'html' | 'oscal' | 'xccdf' | 'openmetrics';
+
 /**
  * Configuration for submitting reports to an HTTPS endpoint.
  */
@@ -311,14 +502,47 @@ export interface ReportDestinationConfig {
   format?: ReportFormat;
 
   /**
-   * Secret used for HMAC-SHA256 signing of the payload.
+   * Secret used for HMAC-SHA256 signing of the payload (legacy).
+   * Anyone who can read the playbook can forge signatures with it:
+   * prefer device keys created by 'crobe enroll'.
    */
   signatureSecret?: string;
 
+  /**
+   * Also submit the playbook's reportOutputs (e.g., report.sarif), as
+   * additional multipart parts or in the 'files' field of the JSON envelope.
+   */
+  includeOutputs?: boolean;
+
   /**
    * Custom HTTP headers to include in the submission.
    */
   additionalHeaders?: Record<string, string>;
+
+  /**
+   * PEM file of the CAs trusted to sign the server certificate, replacing the system roots.
+   * Overridden by the --ca-bundle CLI flag.
+   */
+  caBundle?: string;
+
+  /**
+   * PEM file of the client certificate presented for mutual TLS. Requires clientKey.
+   * Overridden by the --client-cert CLI flag.
+   */
+  clientCert?: string;
+
+  /**
+   * PEM file of the private key of clientCert.
+   * Overridden by the --client-key CLI flag.
+   */
+  clientKey?: string;
+
+  /**
+   * Base64 SHA-256 hashes of the SubjectPublicKeyInfo of a certificate in the server's chain
+   * (optionally prefixed with 'sha256//'). Submission is refused unless one of them matches.
+   * Overridden by the --pin CLI flag.
+   */
+  pins?: string[];
 }
 
 /**
@@ -346,8 +570,9 @@ export type Playbook = {
   /**
    * Sections containing assertions.
    * At least one section is required.
+   * Include entries are replaced by the sections of a library file.
    */
-  sections: Section[];
+  sections: (Section | Include)[];
 
   /**
    * Destination type for the report.
@@ -367,4 +592,54 @@ export type Playbook = {
    * Configuration for `reportDestination === 'https'`
    */
   reportDestinationHttps?: ReportDestinationConfig;
+
+  /**
+   * Path of the `.prom` file for `reportDestination === 'prometheus'`,
+   * in the node_exporter textfile collector directory
+   * (e.g., '/var/lib/node_exporter/textfile_collector/crobe.prom').
+   * The file is replaced atomically and is never encrypted.
+   */
+  reportDestinationPrometheus?: string;
+
+  /**
+   * age public keys (age1...) that the report files are encrypted to,
+   * in the reports folder (as `.age` files) and in submissions.
+   * Any of them can decrypt the reports with `crobe decrypt`.
+   * The CLI flag `--recipient` replaces this list.
+   */
+  reportRecipients?: string[];
+
+  /**
+   * Additional formats the report is rendered in, written next to the other
+   * report files (e.g., 260206-033831.report.sarif).
+   * The CLI flag `--output` replaces this list.
+   */
+  reportOutputs?: OutputFormat[];
+
+  /**
+   * Default timeout for every execution that does not specify its own (e.g., '2m').
+   * If not specified, executions have no timeout.
+   */
+  defaultTimeout?: string;
+
+  /**
+   * Deadline for the whole playbook run (e.g., '30m').
+   * Executions still running when it passes are terminated and scored as timed out.
+   */
+  runTimeout?: string;
+
+  /**
+   * Maximum number of assertions executed at the same time.
+   * Default: 1 (sequential). Overridden by the --parallel CLI flag.
+   * Reports always keep the playbook order.
+   */
+  concurrency?: number;
+
+  /**
+   * Variables with their default values.
+   * Referenced as ${vars.name} in scripts, regexes and descriptions, and available to
+   * every JS function as vars. Overridden by CROBE_VAR_<name> environment variables,
+   * then --var-file, then --var name=value.
+   */
+  vars?: Record<string, string | number | boolean>;
 }
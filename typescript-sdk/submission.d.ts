@@ -1,3 +1,16 @@
+import { ControlRef, Severity } from './playbook';
+
+/**
+ * The outcome of an assertion.
+ * - pass: The assertion ran and met its minimum passing score.
+ * - fail: The assertion ran and the control did not hold.
+ * - error: The probe broke (JS error, missing binary, rule error...) and the control could not be evaluated.
+ * - skipped: The assertion was deliberately not run.
+ * - not_applicable: The assertion does not apply to the target system.
+ * - waived: The assertion failed, but an active waiver accepts the failure.
+ */
+export type Verdict = 'pass' | 'fail' | 'error' | 'skipped' | 'not_applicable' | 'waived';
+
 /**
  * Represents a single assertion's execution result in the JSON report.
  * 
@@ -21,9 +34,54 @@ export interface Assertion<T = Record<string, any>> {
     end: string;
   };
 
-  /** Whether the assertion passed the required criteria. */
+  /** Title of the assertion. */
+  title?: string;
+
+  /** Title of the section the assertion belongs to. */
+  section?: string;
+
+  /**
+   * Whether the assertion passed the required criteria.
+   * Equivalent to `verdict === 'pass'`.
+   */
   passed: boolean;
 
+  /** The outcome of the assertion. */
+  verdict: Verdict;
+
+  /** The effective severity of the assertion (defaults to 'medium'). */
+  severity: Severity;
+
+  /** The effective weight of the assertion in the overall score. */
+  weight: number;
+
+  /** Compliance framework controls the assertion provides evidence for. */
+  controls?: ControlRef[];
+
+  /**
+   * Present when the assertion failed and was remediated (or, in dry-run, would have been).
+   * `verdict` and `score` then hold the outcome after remediation.
+   */
+  remediation?: Remediation;
+
+  /**
+   * The waiver matching the failed assertion. Present when verdict is 'waived', or 'fail'
+   * with an expired waiver (`expired: true`).
+   */
+  waiver?: Waiver;
+
+  /**
+   * Why the assertion was not run (e.g. platform filter or a falsy `when` predicate).
+   * Present when verdict is 'not_applicable' or 'skipped'.
+   */
+  reason?: string;
+
+  /**
+   * Reasons the assertion could not be evaluated (JS errors, missing binaries, rule errors...).
+   * Present when verdict is 'error'.
+   */
+  errors?: string[];
+
   /** The actual score achieved by the assertion. */
   score: number;
 
@@ -35,6 +93,24 @@ export interface Assertion<T = Record<string, any>> {
    * This includes any 'gather' results that were not explicitly excluded from the report.
    */
   context: T;
+
+  /**
+   * Executions that were terminated for exceeding their timeout.
+   * Omitted when nothing timed out.
+   */
+  timeouts?: Timeout[];
+}
+
+/**
+ * An execution that was terminated for exceeding its timeout.
+ */
+export interface Timeout {
+  /** The stage the execution belongs to. */
+  stage: 'preCmd' | 'cmd' | 'postCmd';
+  /** Zero-based index of the execution within its stage. */
+  index: number;
+  /** The effective timeout that was exceeded (e.g., "30s"). */
+  timeout: string;
 }
 
 /**
@@ -45,6 +121,96 @@ export interface Stats {
   passed: number;
   /** Total number of assertions that failed. */
   failed: number;
+  /** Total number of assertions that could not be evaluated. */
+  errored: number;
+  /** Total number of assertions that were skipped. */
+  skipped: number;
+  /** Total number of assertions that do not apply to the target system. */
+  notApplicable: number;
+  /** Total number of failed assertions accepted by an active waiver. Omitted when zero. */
+  waived?: number;
+  /** Number of failed assertions whose matching waiver has expired. Omitted when zero. */
+  expiredWaivers?: number;
+  /**
+   * Weighted percentage (0-100, one decimal) of passed assertions among those that passed,
+   * failed or errored. Absent when no weight was scored.
+   */
+  score?: number;
+  /** Letter grade of the score: A (>= 90), B (>= 80), C (>= 70), D (>= 60) or F. */
+  grade?: 'A' | 'B' | 'C' | 'D' | 'F';
+}
+
+/** Remediation mode of a run. */
+export type RemediationMode = 'dry-run' | 'apply';
+
+/**
+ * What was done (or, in dry-run, would be done) to fix a failed assertion.
+ */
+export interface Remediation {
+  mode: RemediationMode;
+  description: string;
+  /** The resolved remediation script, or '[REDACTED]' if excluded from report. */
+  script?: string;
+  /** Exit code of the applied remediation. */
+  exitCode?: number;
+  /** Why the remediation could not be applied. */
+  error?: string;
+  verdictBefore: Verdict;
+  verdictAfter: Verdict;
+  scoreBefore: number;
+  scoreAfter: number;
+}
+
+/**
+ * A waiver from the --waivers file that matched a failed assertion.
+ */
+export interface Waiver {
+  /** Assertion codes (glob patterns) the waiver covers. */
+  codes: string[];
+  /** Hostnames (glob patterns) the waiver is limited to. */
+  hosts?: string[];
+  /** Usernames (glob patterns) the waiver is limited to. */
+  users?: string[];
+  justification: string;
+  approver: string;
+  /**
+   * Last day the waiver applies.
+   * @format date (YYYY-MM-DD)
+   */
+  expires: string;
+  /** The waiver had expired: the assertion stayed failed. */
+  expired: boolean;
+}
+
+/**
+ * Verdict counts and score of a single section.
+ */
+export interface SectionSummary {
+  /** Title of the section. */
+  title: string;
+  stats: Stats;
+}
+
+/**
+ * Controls of one compliance framework, in the order they first appear in the playbook.
+ */
+export interface FrameworkSummary {
+  framework: string;
+  controls: ControlSummary[];
+}
+
+/**
+ * Combined outcome of every assertion mapped to a control.
+ * The verdict is 'fail' if any assertion failed, else 'error' if any errored,
+ * else 'pass' if any passed; otherwise 'not_applicable' or 'skipped'.
+ */
+export interface ControlSummary {
+  id: string;
+  url?: string;
+  verdict: Verdict;
+  /** Codes of the assertions mapped to the control. */
+  assertions: string[];
+  stats: Stats;
 }
 
 /**
@@ -68,9 +234,15 @@ export interface FinalReport {
     end: string;
   };
 
+  /** Title of the playbook. */
+  title?: string;
+
   /** The username of the user who executed the probe. */
   username: string;
 
+  /** The hostname of the machine the probe ran on. */
+  hostname?: string;
+
   /** The operating system platform (e.g., "linux", "mac", "windows"). */
   os: string;
 
@@ -82,8 +254,57 @@ export interface FinalReport {
    */
   assertions: Record<string, Assertion>;
 
-  /** Aggregated pass/fail statistics. */
+  /** Aggregated verdict statistics and the overall score. */
   stats: Stats;
+
+  /** Per-section verdict statistics and scores, in playbook order. */
+  sections?: SectionSummary[];
+
+  /** Assertion verdicts rolled up to the controls of each mapped compliance framework. */
+  frameworks?: FrameworkSummary[];
+
+  /** The remediation mode of the run (--remediate), if any. */
+  remediate?: RemediationMode;
+
+  /**
+   * The assertion filter the run was restricted to (--tags, --exclude-tags, --code, --section).
+   * Absent for full runs. Assertions outside the selection have verdict 'skipped'.
+   */
+  selection?: Selection;
+
+  /** Effective values of the playbook vars, after overrides. Absent if the playbook has none. */
+  vars?: Record<string, string | number | boolean>;
+
+  /**
+   * Remote playbook files that were loaded from the agent's local cache because their server
+   * could not be reached. Absent when everything was fetched (or revalidated) from the server.
+   */
+  cachedCopies?: CachedCopy[];
+}
+
+/**
+ * A remote playbook file loaded from the local cache instead of its server.
+ */
+export interface CachedCopy {
+  /** URL of the file. */
+  location: string;
+  /**
+   * When the server last served or confirmed the cached copy.
+   * @format date-time (ISO 8601)
+   */
+  fetchedAt: string;
+  /** Age of the cached copy when the run started (e.g., "3h12m5s"). */
+  age: string;
+}
+
+/**
+ * Glob patterns restricting a run to a subset of assertions.
+ */
+export interface Selection {
+  tags?: string[];
+  excludeTags?: string[];
+  codes?: string[];
+  sections?: string[];
 }
 
 /**
@@ -114,4 +335,42 @@ export interface RemoteSubmission {
    * HMAC-SHA256 signature of the 'log' Base64 string (if signatureSecret is set). 
    */
   logSignature?: string;
+
+  /**
+   * Base64 encoded reportOutputs by file name (e.g., 'report.sarif'), when
+   * the destination has includeOutputs set. Covered by the signatures.
+   */
+  files?: Record<string, string>;
+
+  /**
+   * 'age' when json, md and log are age files encrypted to the playbook's
+   * reportRecipients (Base64 encoded like plain files). Absent otherwise.
+   */
+  encryption?: 'age';
+
+  /** Hostname of the device, covered by the signatures. */
+  deviceId: string;
+  /** When the report was submitted (RFC 3339, UTC), covered by the signatures. */
+  timestamp: string;
+  /**
+   * Nonce sent by the playbook server in the 'X-Crobe-Nonce' response header
+   * (if any), covered by the signatures. Accept each nonce only once.
+   */
+  nonce?: string;
+
+  /**
+   * ID of the device key that signed the submission (if the device is enrolled):
+   * "SHA256:" followed by the unpadded Base64 SHA-256 hash of its PKIX public key.
+   */
+  keyId?: string;
+  /**
+   * Base64 ed25519 or ECDSA P-256 (ASN.1) signature of the submission by the device key.
+   * Verify it with `submission.Verify` of the crobe Go module.
+   */
+  signature?: string;
+  /**
+   * HMAC-SHA256 (hex) of the signed material, covering deviceId, timestamp and nonce
+   * (if signatureSecret is set). Verify it with `submission.VerifyHMAC`.
+   */
+  envelopeSignature?: string;
 }